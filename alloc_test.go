@@ -0,0 +1,46 @@
+//go:build !race
+
+package decoder
+
+import (
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+// The allocation budgets of the benchmarks, which are documented in the
+// README.  They have some headroom over the baselines, as the allocations
+// made by the standard library vary between versions of go.
+const (
+	allocsSmall = 8
+	allocsLarge = 7000
+	// Pooling saves the slice of pairs gathered for each struct in a map.
+	allocsLargePooled = 6400
+	allocsDeep        = 11
+)
+
+func TestAllocationBudgets(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		d      *Decoder
+		kvs    consulapi.KVPairs
+		newV   func() interface{}
+		budget float64
+	}{
+		{"small", defaultDecoder, bmSmallPairs(), func() interface{} { return &bmSmall{} }, allocsSmall},
+		{"large", defaultDecoder, bmLargePairs(), func() interface{} { return &bmLarge{} }, allocsLarge},
+		{"large pooled", &Decoder{PoolBuffers: true}, bmLargePairs(), func() interface{} { return &bmLarge{} }, allocsLargePooled},
+		{"deep", defaultDecoder, bmDeepPairs(), func() interface{} { return &bmDeep{} }, allocsDeep},
+	} {
+		var err error
+		allocs := testing.AllocsPerRun(10, func() {
+			err = tc.d.Unmarshal(prefix, tc.kvs, tc.newV())
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", tc.name, err)
+		}
+		if allocs > tc.budget {
+			t.Errorf("%s: %.0f allocations exceeds the budget of %.0f", tc.name, allocs, tc.budget)
+		}
+	}
+}
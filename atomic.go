@@ -0,0 +1,87 @@
+package decoder
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// parseAtomic fills in the metadata for a field of one of the sync/atomic
+// types, which are decoded by calling their Store method so that the
+// application can read them without locking while they're being updated.
+func parseAtomic(tfm *tFieldMeta, t reflect.Type) error {
+	store, ok := reflect.PtrTo(t).MethodByName("Store")
+	if !ok {
+		return fmt.Errorf("%s: unsupported atomic type %s", tfm.fieldName, t)
+	}
+	// In(0) is the receiver.
+	at := store.Type.In(1)
+
+	isPointer := strings.HasPrefix(t.Name(), "Pointer[")
+	if isPointer {
+		at = at.Elem()
+	}
+
+	switch {
+	case isTextUnmarshaler(at):
+		if !isPointer {
+			return fmt.Errorf("%s: unsupported atomic type %s", tfm.fieldName, t)
+		}
+		tfm.atomicComputedType = typeTextUnmarshaler
+	case isPointer && tfm.locators[0].isEncoded:
+		tfm.atomicComputedType = typeStruct
+	default:
+		switch at.Kind() {
+		case reflect.String,
+			reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8,
+			reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8,
+			reflect.Uintptr, reflect.Float64, reflect.Float32, reflect.Bool:
+			tfm.atomicComputedType = scalarType(at)
+		default:
+			return fmt.Errorf("%s: unsupported atomic type %s", tfm.fieldName, t)
+		}
+	}
+
+	// The json and msgpack modifiers are handled here rather than by the
+	// usual path, which would replace the atomic rather than storing into it.
+	tfm.locators[0].isEncoded = false
+	tfm.computedType = typeAtomic
+	tfm.atomicType = at
+	tfm.atomicPointer = isPointer
+	return nil
+}
+
+// storeAtomic converts value and stores it in tval, which holds one of the
+// sync/atomic types.
+func (d *Decoder) storeAtomic(tfm *tFieldMeta, tval reflect.Value, value []byte, zero bool) error {
+	var v reflect.Value
+	if zero {
+		v = reflect.New(tfm.atomicType).Elem()
+	} else {
+		switch tfm.atomicComputedType {
+		case typeTextUnmarshaler:
+			v = reflect.New(tfm.atomicType).Elem()
+			if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(value); err != nil {
+				return err
+			}
+		case typeStruct:
+			v = reflect.New(tfm.atomicType).Elem()
+			if err := tfm.unmarshal(value, v.Addr().Interface()); err != nil {
+				return err
+			}
+		default:
+			var err error
+			if v, err = d.handleIntrinsicType(value, tfm.atomicType, tfm.atomicComputedType, tfm.params); err != nil {
+				return err
+			}
+		}
+	}
+
+	if tfm.atomicPointer {
+		// v is addressable, as it was created with reflect.New.
+		v = v.Addr()
+	}
+	tval.Addr().MethodByName("Store").Call([]reflect.Value{v})
+	return nil
+}
@@ -0,0 +1,73 @@
+package decoder
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type (
+	tbAtomic struct {
+		Enabled   atomic.Bool
+		Count     *atomic.Int64
+		Limit     atomic.Uint32
+		Name      atomic.Pointer[string]
+		Timeout   atomic.Pointer[time.Duration]
+		Struct    atomic.Pointer[TestStruct] `decoder:",json"`
+		Parsed    atomic.Pointer[TestTextUnmarshaler]
+		Untouched atomic.Int32
+	}
+
+	tbAtomicInvalid struct {
+		Value atomic.Value
+	}
+
+	tbAtomicSlice struct {
+		Values []atomic.Int64
+	}
+)
+
+func TestUnmarshalAtomic(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/count", Value: []byte("-5")},
+		{Key: prefix + "/enabled", Value: []byte("true")},
+		{Key: prefix + "/limit", Value: []byte("10")},
+		{Key: prefix + "/name", Value: []byte("name")},
+		{Key: prefix + "/struct", Value: []byte(`{"field1":"a"}`)},
+		{Key: prefix + "/timeout", Value: []byte("5s")},
+		{Key: prefix + "/parsed", Value: []byte("b:c")},
+	}
+
+	cfg := &tbAtomic{}
+	cfg.Untouched.Store(7)
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !cfg.Enabled.Load() || cfg.Count.Load() != -5 || cfg.Limit.Load() != 10 || cfg.Untouched.Load() != 7 {
+		t.Errorf("unexpected scalar atomics: %v %v %v", cfg.Enabled.Load(), cfg.Count.Load(), cfg.Limit.Load())
+	}
+	if *cfg.Name.Load() != "name" || *cfg.Timeout.Load() != 5*time.Second {
+		t.Errorf("unexpected pointer atomics: %v %v", *cfg.Name.Load(), *cfg.Timeout.Load())
+	}
+	if cfg.Struct.Load().Field1 != "a" || cfg.Parsed.Load().Field2 != "c" {
+		t.Errorf("unexpected struct atomics: %+v %+v", cfg.Struct.Load(), cfg.Parsed.Load())
+	}
+
+	// Re-decoding stores new values rather than replacing the atomics.
+	count, name := cfg.Count, cfg.Name.Load()
+	kvs[0].Value, kvs[3].Value = []byte("6"), []byte("renamed")
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Count != count || count.Load() != 6 || *cfg.Name.Load() != "renamed" || *name != "name" {
+		t.Errorf("unexpected result of re-decode: %v %v", count.Load(), *cfg.Name.Load())
+	}
+
+	for _, v := range []interface{}{&tbAtomicInvalid{}, &tbAtomicSlice{}} {
+		if err := Unmarshal(prefix, kvs, v); err == nil {
+			t.Errorf("expected %T to be rejected", v)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package decoder
+
+import (
+	"reflect"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// RedactedValue - given to Decoder.OnAssign in place of the values of fields
+// with the secret modifier.
+const RedactedValue = "[redacted]"
+
+// assigned records that v, the value of pair, has been assigned to the field
+// tfm of val, and passes it to OnAssign.
+func (d *Decoder) assigned(state *decodeState, tfm *tFieldMeta, pair *api.KVPair, val, v reflect.Value) {
+	state.report.Stats.Fields++
+	if d.OnAssign == nil {
+		return
+	}
+	var value interface{} = RedactedValue
+	if !tfm.secret && state.secrets == 0 {
+		if tfm.computedType == typeAtomic && v.CanAddr() {
+			// Give the value stored, rather than a copy of the atomic.
+			v = v.Addr().MethodByName("Load").Call(nil)[0]
+		}
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+		if v.CanInterface() {
+			value = v.Interface()
+		}
+	}
+	d.OnAssign(pair.Key, fieldInfo(tfm, tfm.fieldName, val).Name, value)
+}
@@ -0,0 +1,67 @@
+package decoder
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbAuditDB struct {
+	Host     string
+	Password string `decoder:",secret"`
+}
+
+type tbAuditCreds struct {
+	Token string
+}
+
+type tbAudit struct {
+	DB      *tbAuditDB
+	Creds   tbAuditCreds            `decoder:",secret"`
+	Keys    map[string]tbAuditCreds `decoder:",secret"`
+	Tags    []string                `decoder:",csv"`
+	Labels  map[string]*string
+	Timeout atomic.Int64
+	Raw     map[string]int `decoder:",json"`
+}
+
+func TestOnAssign(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/creds/token", Value: []byte("t0ken")},
+		{Key: prefix + "/db/host", Value: []byte("db.local")},
+		{Key: prefix + "/db/password", Value: []byte("hunter2")},
+		{Key: prefix + "/keys/a/token", Value: []byte("k3y")},
+		{Key: prefix + "/labels/env", Value: []byte("prod")},
+		{Key: prefix + "/raw", Value: []byte(`{"a":1}`)},
+		{Key: prefix + "/tags", Value: []byte("a,b")},
+		{Key: prefix + "/timeout", Value: []byte("5")},
+	}
+
+	type assignment struct {
+		key, field string
+		value      interface{}
+	}
+	var got []assignment
+	d := &Decoder{OnAssign: func(key, field string, value interface{}) {
+		got = append(got, assignment{key, field, value})
+	}}
+	if err := d.Unmarshal(prefix, kvs, &tbAudit{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []assignment{
+		{prefix + "/creds/token", "Creds.Token", RedactedValue},
+		{prefix + "/db/host", "DB.Host", "db.local"},
+		{prefix + "/db/password", "DB.Password", RedactedValue},
+		{prefix + "/keys/a/token", "Token", RedactedValue},
+		{prefix + "/labels/env", "Labels", "prod"},
+		{prefix + "/raw", "Raw", map[string]int{"a": 1}},
+		{prefix + "/tags", "Tags", []string{"a", "b"}},
+		{prefix + "/timeout", "Timeout", int64(5)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected assignments:\n got: %v\nwant: %v", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package decoder
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type bmSmall struct {
+	Name    string
+	Port    int
+	Enabled bool
+	Timeout time.Duration
+}
+
+type bmService struct {
+	Host    string
+	Port    int
+	Tags    []string
+	Labels  map[string]string
+	Timeout time.Duration
+}
+
+type bmLarge struct {
+	Name     string
+	Services map[string]bmService
+}
+
+type bmDeep struct {
+	Name  string
+	Level *bmDeep1
+}
+
+type bmDeep1 struct {
+	Name  string
+	Level *bmDeep2
+}
+
+type bmDeep2 struct {
+	Name  string
+	Level *bmDeep3
+}
+
+type bmDeep3 struct {
+	Name  string
+	Level *bmDeep4
+}
+
+type bmDeep4 struct {
+	Name  string
+	Value int
+}
+
+func bmSmallPairs() consulapi.KVPairs {
+	return consulapi.KVPairs{
+		{Key: prefix + "/enabled", Value: []byte("true")},
+		{Key: prefix + "/name", Value: []byte("small")},
+		{Key: prefix + "/port", Value: []byte("8080")},
+		{Key: prefix + "/timeout", Value: []byte("5s")},
+	}
+}
+
+// bmLargePairs returns the pairs of 100 services, each with 10 keys.
+func bmLargePairs() consulapi.KVPairs {
+	kvs := consulapi.KVPairs{{Key: prefix + "/name", Value: []byte("large")}}
+	for i := 0; i < 100; i++ {
+		svc := fmt.Sprintf("%s/services/svc%03d", prefix, i)
+		kvs = append(kvs,
+			&consulapi.KVPair{Key: svc + "/host", Value: []byte("host.local")},
+			&consulapi.KVPair{Key: svc + "/labels/env", Value: []byte("prod")},
+			&consulapi.KVPair{Key: svc + "/labels/team", Value: []byte("core")},
+			&consulapi.KVPair{Key: svc + "/port", Value: []byte("8080")},
+			&consulapi.KVPair{Key: svc + "/tags/0", Value: []byte("a")},
+			&consulapi.KVPair{Key: svc + "/tags/1", Value: []byte("b")},
+			&consulapi.KVPair{Key: svc + "/tags/2", Value: []byte("c")},
+			&consulapi.KVPair{Key: svc + "/tags/3", Value: []byte("d")},
+			&consulapi.KVPair{Key: svc + "/tags/4", Value: []byte("e")},
+			&consulapi.KVPair{Key: svc + "/timeout", Value: []byte("1s")},
+		)
+	}
+	return kvs
+}
+
+func bmDeepPairs() consulapi.KVPairs {
+	return consulapi.KVPairs{
+		{Key: prefix + "/level/level/level/level/name", Value: []byte("deep")},
+		{Key: prefix + "/level/level/level/level/value", Value: []byte("4")},
+		{Key: prefix + "/level/level/level/name", Value: []byte("3")},
+		{Key: prefix + "/level/level/name", Value: []byte("2")},
+		{Key: prefix + "/level/name", Value: []byte("1")},
+		{Key: prefix + "/name", Value: []byte("0")},
+	}
+}
+
+func benchmarkUnmarshal(b *testing.B, kvs consulapi.KVPairs, newV func() interface{}) {
+	benchmarkDecoder(b, defaultDecoder, kvs, newV)
+}
+
+func benchmarkDecoder(b *testing.B, d *Decoder, kvs consulapi.KVPairs, newV func() interface{}) {
+	// Parse the type first, so that only decoding is measured.
+	if err := d.Unmarshal(prefix, kvs, newV()); err != nil {
+		b.Fatalf("unexpected error: %s", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := d.Unmarshal(prefix, kvs, newV()); err != nil {
+			b.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalSmall(b *testing.B) {
+	benchmarkUnmarshal(b, bmSmallPairs(), func() interface{} { return &bmSmall{} })
+}
+
+func BenchmarkUnmarshalLarge(b *testing.B) {
+	benchmarkUnmarshal(b, bmLargePairs(), func() interface{} { return &bmLarge{} })
+}
+
+func BenchmarkUnmarshalLargePooled(b *testing.B) {
+	benchmarkDecoder(b, &Decoder{PoolBuffers: true}, bmLargePairs(), func() interface{} { return &bmLarge{} })
+}
+
+func BenchmarkUnmarshalDeep(b *testing.B) {
+	benchmarkUnmarshal(b, bmDeepPairs(), func() interface{} { return &bmDeep{} })
+}
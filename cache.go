@@ -0,0 +1,232 @@
+package decoder
+
+import (
+	"container/list"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// TypeCache - holds the metadata gathered from the types decoded into, so
+// that each type is only inspected once.  Decoders share a single cache
+// unless given their own, which is useful where many types are generated or
+// loaded at runtime, as a cache may be bounded in size and emptied.  The
+// zero value is an unbounded cache ready for use.  A TypeCache is safe for
+// concurrent use, and may be shared by any number of decoders, whatever
+// their settings.
+type TypeCache struct {
+	lck sync.Mutex
+	max int
+
+	// entries holds the elements of lru, which are *cacheEntry, with the
+	// most recently used at the front.
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+
+	// parsing holds the types currently being parsed, so that we can
+	// detect structs that contain themselves.
+	parsing map[cacheKey]bool
+}
+
+// cacheKey identifies the metadata of a type, as gathered by decoders with
+// particular settings.
+type cacheKey struct {
+	t reflect.Type
+
+	// collection is set for the metadata of a map or slice passed to
+	// Unmarshal in place of a struct.
+	collection bool
+
+	// The settings of the decoder which affect parsing.  owner is only set
+	// for decoders with a NameResolver, or with modifiers or transformers
+	// registered, as those can't be compared, and is the id of the registry
+	// of the decoder, so that the cache doesn't keep the decoder alive.
+	// generation changes whenever a modifier or transformer is registered.
+	// weak and decimalComma change how min and max bounds are parsed.
+	caseSensitive bool
+	weak          bool
+	decimalComma  bool
+	tag           string
+	mapstructure  bool
+	sep           string
+	owner         uint64
+	generation    uint64
+}
+
+type cacheEntry struct {
+	key cacheKey
+	tm  *tMeta
+}
+
+// sharedTypeCache is used by decoders without a cache of their own.
+var sharedTypeCache = &TypeCache{}
+
+// NewTypeCache - returns a cache which holds the metadata of at most max
+// types, evicting those least recently used.  A max of zero means no limit.
+// Note that the fields of nested structs count as types of their own.
+func NewTypeCache(max int) *TypeCache {
+	return &TypeCache{max: max}
+}
+
+// ClearTypeCache - empties the cache shared by decoders which haven't been
+// given one of their own, such as the one used by Unmarshal.  Types will be
+// inspected again the next time they're decoded into.
+func ClearTypeCache() {
+	sharedTypeCache.Clear()
+}
+
+// Clear - empties the cache.
+func (c *TypeCache) Clear() {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	c.entries = nil
+	c.lru = nil
+}
+
+// evict removes the metadata gathered by the decoder whose registry has the
+// id owner.
+func (c *TypeCache) evict(owner uint64) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	for key, el := range c.entries {
+		if key.owner == owner {
+			c.lru.Remove(el)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Len - returns the number of types in the cache.
+func (c *TypeCache) Len() int {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+	return len(c.entries)
+}
+
+// typeCache returns the cache used by the decoder.
+func (d *Decoder) typeCache() *TypeCache {
+	if d.Cache != nil {
+		return d.Cache
+	}
+	return sharedTypeCache
+}
+
+// cacheKey returns the key of the metadata of t, as gathered by d.
+func (d *Decoder) cacheKey(t reflect.Type, collection bool) cacheKey {
+	ck := cacheKey{
+		t:             t,
+		collection:    collection,
+		caseSensitive: d.CaseSensitive,
+		weak:          d.WeaklyTypedInput,
+		decimalComma:  d.DecimalComma,
+		tag:           d.structTag(),
+		mapstructure:  d.Mapstructure,
+		sep:           d.PathSeparator,
+	}
+	reg := d.registry()
+	reg.lck.RLock()
+	custom := len(reg.modifiers) > 0 || len(reg.transformers) > 0
+	generation := reg.generation
+	reg.lck.RUnlock()
+	if custom || d.NameResolver != nil {
+		ck.owner = reg.id
+		ck.generation = generation
+	}
+	return ck
+}
+
+// tMeta returns the metadata for t, parsing it if necessary.  hit is true
+// if the metadata was already cached.  lock is false for the nested calls
+// made while parsing, when the cache is already locked.
+func (c *TypeCache) tMeta(d *Decoder, t reflect.Type, lock bool) (tm *tMeta, hit bool, err error) {
+	return c.meta(d.cacheKey(t, false), lock, func() (*tMeta, error) {
+		return d.parseStruct(t)
+	})
+}
+
+// meta returns the metadata for key, calling parse to gather it if it isn't
+// already cached.
+func (c *TypeCache) meta(key cacheKey, lock bool, parse func() (*tMeta, error)) (*tMeta, bool, error) {
+	// TODO this probably shouldn't lock the world.
+	if lock {
+		c.lck.Lock()
+		defer c.lck.Unlock()
+	}
+	if el, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(el)
+		return el.Value.(*cacheEntry).tm, true, nil
+	}
+	if c.parsing[key] {
+		// Structs get flattened, so one that contains itself, other than
+		// inside of a map or slice, would never finish parsing.
+		name := typeKey(key.t)
+		if name == "" {
+			name = key.t.String()
+		}
+		return nil, false, fmt.Errorf("recursive struct type %s: a struct may only contain itself inside a map or slice", name)
+	}
+	if c.parsing == nil {
+		c.parsing = make(map[cacheKey]bool)
+	}
+	c.parsing[key] = true
+	tm, err := func() (*tMeta, error) {
+		// Deferred, so that a type isn't left marked as being parsed if
+		// parse panics.
+		defer delete(c.parsing, key)
+		return parse()
+	}()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if c.entries == nil {
+		c.entries = make(map[cacheKey]*list.Element)
+		c.lru = list.New()
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, tm: tm})
+	if c.max > 0 && c.lru.Len() > c.max {
+		oldest := c.lru.Remove(c.lru.Back()).(*cacheEntry)
+		delete(c.entries, oldest.key)
+	}
+	return tm, false, nil
+}
+
+// Precompile - uses the default decoder to gather the metadata of the types
+// of vs ahead of time.  See Decoder.Precompile.
+func Precompile(vs ...interface{}) error {
+	return defaultDecoder.Precompile(vs...)
+}
+
+// Precompile - gathers the metadata of the types of vs, which are anything
+// that may be passed to Unmarshal, or the structs, maps or slices they point
+// to, so that the first decode into them needn't.  This moves the cost of
+// inspecting types, including calls to the NameResolver, to startup, and
+// returns any error in their struct tags early.  The metadata is kept in the
+// cache of the decoder, so may be evicted from a bounded cache.
+func (d *Decoder) Precompile(vs ...interface{}) error {
+	for _, v := range vs {
+		t := reflect.TypeOf(v)
+		if t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		var meta *tMeta
+		var err error
+		switch {
+		case t == nil:
+			return InvalidValueErr
+		case t.Kind() == reflect.Struct:
+			meta, _, err = d.typeCache().tMeta(d, t, true)
+		case t.Kind() == reflect.Map || t.Kind() == reflect.Slice:
+			meta, _, err = d.typeCache().collectionMeta(d, t)
+		default:
+			return InvalidValueErr
+		}
+		if err == nil && d.UnknownModifiers == IssueError && len(meta.unknown) > 0 {
+			err = unknownModifierError(meta)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", t, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package decoder
+
+import (
+	"bytes"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbCacheA struct {
+	Name string
+}
+
+type tbCacheB struct {
+	Name string
+}
+
+type tbCacheC struct {
+	Name string
+}
+
+func TestTypeCache(t *testing.T) {
+	kvs := consulapi.KVPairs{{Key: prefix + "/name", Value: []byte("value")}}
+
+	cache := NewTypeCache(2)
+	dec := &Decoder{Cache: cache}
+	for _, v := range []interface{}{&tbCacheA{}, &tbCacheB{}, &tbCacheC{}} {
+		if err := dec.Unmarshal(prefix, kvs, v); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 cached types, got %d", cache.Len())
+	}
+
+	// tbCacheA was evicted, being the least recently used.
+	rep, err := dec.UnmarshalReport(prefix, kvs, &tbCacheA{})
+	if err != nil || rep.Stats.CacheMisses != 1 {
+		t.Errorf("expected a cache miss, got %+v, %v", rep.Stats, err)
+	}
+	rep, err = dec.UnmarshalReport(prefix, kvs, &tbCacheC{})
+	if err != nil || rep.Stats.CacheHits != 1 {
+		t.Errorf("expected a cache hit, got %+v, %v", rep.Stats, err)
+	}
+
+	cache.Clear()
+	if cache.Len() != 0 {
+		t.Errorf("expected empty cache, got %d", cache.Len())
+	}
+
+	if _, err := UnmarshalReport(prefix, kvs, &tbCacheA{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ClearTypeCache()
+	rep, err = UnmarshalReport(prefix, kvs, &tbCacheA{})
+	if err != nil || rep.Stats.CacheMisses != 1 {
+		t.Errorf("expected a cache miss after ClearTypeCache, got %+v, %v", rep.Stats, err)
+	}
+}
+
+type tbCacheSettings struct {
+	Name string
+}
+
+func TestTypeCacheSettings(t *testing.T) {
+	// Decoders with different settings share a cache without seeing each
+	// other's metadata.
+	kvs := consulapi.KVPairs{{Key: prefix + "/name", Value: []byte("value")}}
+
+	cfg := &tbCacheSettings{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil || cfg.Name != "value" {
+		t.Fatalf("unexpected result: %+v, %v", cfg, err)
+	}
+
+	cfg = &tbCacheSettings{}
+	if err := (&Decoder{CaseSensitive: true}).Unmarshal(prefix, kvs, cfg); err != nil || cfg.Name != "" {
+		t.Errorf("expected no match when case sensitive, got %+v, %v", cfg, err)
+	}
+
+	// Settings which change how bounds are parsed key the cache too.
+	shared := NewTypeCache(0)
+	for _, dec := range []*Decoder{{Cache: shared}, {Cache: shared, DecimalComma: true}, {Cache: shared, WeaklyTypedInput: true}} {
+		if err := dec.Unmarshal(prefix, kvs, &tbCacheSettings{}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if n := shared.Len(); n != 3 {
+		t.Errorf("expected metadata for each setting, got %d types", n)
+	}
+
+	cfg = &tbCacheSettings{}
+	dec := &Decoder{NameResolver: func(field, tag string) string { return "other" }}
+	kvs = consulapi.KVPairs{{Key: prefix + "/other", Value: []byte("resolved")}}
+	if err := dec.Unmarshal(prefix, kvs, cfg); err != nil || cfg.Name != "resolved" {
+		t.Errorf("unexpected result with name resolver: %+v, %v", cfg, err)
+	}
+}
+
+type tbCacheRegister struct {
+	Upper string `decoder:",upper"`
+	Lower string `decoder:",lower"`
+}
+
+func TestTypeCacheRegister(t *testing.T) {
+	// Modifiers registered after a decode are used by the next.
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/upper", Value: []byte("Value")},
+		{Key: prefix + "/lower", Value: []byte("Value")},
+	}
+	dec := &Decoder{Cache: NewTypeCache(0)}
+	dec.RegisterTransformer("upper", func(value []byte) ([]byte, error) {
+		return bytes.ToUpper(value), nil
+	})
+	cfg := &tbCacheRegister{}
+	if err := dec.Unmarshal(prefix, kvs, cfg); err != nil || cfg.Upper != "VALUE" || cfg.Lower != "Value" {
+		t.Fatalf("unexpected result: %+v, %v", cfg, err)
+	}
+
+	dec.RegisterTransformer("lower", func(value []byte) ([]byte, error) {
+		return bytes.ToLower(value), nil
+	})
+	cfg = &tbCacheRegister{}
+	if err := dec.Unmarshal(prefix, kvs, cfg); err != nil || cfg.Upper != "VALUE" || cfg.Lower != "value" {
+		t.Errorf("expected the new transformer to be used, got %+v, %v", cfg, err)
+	}
+	if n := dec.Cache.Len(); n != 1 {
+		t.Errorf("expected the stale metadata to be evicted, got %d types", n)
+	}
+}
+
+type tbPrecompile struct {
+	Name  string
+	Inner struct {
+		Name string
+	}
+}
+
+func TestPrecompile(t *testing.T) {
+	kvs := consulapi.KVPairs{{Key: prefix + "/name", Value: []byte("value")}}
+
+	calls := 0
+	dec := &Decoder{
+		Cache: NewTypeCache(0),
+		NameResolver: func(field, tag string) string {
+			calls++
+			return defaultNameResolver(field, tag)
+		},
+	}
+	if err := dec.Precompile(&tbPrecompile{}, map[string]string{}, &[]int{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rep, err := dec.UnmarshalReport(prefix, kvs, &tbPrecompile{})
+	if err != nil || rep.Stats.CacheMisses != 0 {
+		t.Errorf("expected precompiled metadata, got %+v, %v", rep.Stats, err)
+	}
+	m := map[string]string{}
+	rep, err = dec.UnmarshalReport(prefix, kvs, &m)
+	if err != nil || rep.Stats.CacheMisses != 0 || m["name"] != "value" {
+		t.Errorf("expected precompiled collection metadata, got %+v, %v", rep.Stats, err)
+	}
+
+	// Names are resolved again once the types are evicted.
+	resolved := calls
+	dec.Cache.Clear()
+	if err := dec.Unmarshal(prefix, kvs, &tbPrecompile{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if calls == resolved {
+		t.Error("expected the name resolver to be called for evicted types")
+	}
+
+	if err := dec.Precompile(tbPrecompile{}); err != nil {
+		t.Errorf("unexpected error for a struct value: %s", err)
+	}
+	for _, v := range []interface{}{nil, 1, new(string)} {
+		if err := dec.Precompile(v); err != InvalidValueErr {
+			t.Errorf("expected InvalidValueErr for %T, got %v", v, err)
+		}
+	}
+	type tbBadTag struct {
+		Name string `decoder:",base=16"`
+	}
+	if err := Precompile(&tbBadTag{}); err == nil {
+		t.Error("expected an error for an invalid tag")
+	}
+}
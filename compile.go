@@ -0,0 +1,205 @@
+package decoder
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// convertFunc parses data into tval, which must be settable, according to
+// params.  tval is only set if data is valid.
+type convertFunc func(d *Decoder, data []byte, tval reflect.Value, params map[string]string) error
+
+// converters are the convertFuncs of the computed types whose values are
+// parsed by the decoder itself, rather than by code of other packages or
+// of the application.  handleIntrinsicType deals with the other types.
+var converters = map[computedType]convertFunc{
+	typeInt:        (*Decoder).convertInt,
+	typeUint:       (*Decoder).convertUint,
+	typeFloat:      (*Decoder).convertFloat,
+	typeBool:       (*Decoder).convertBool,
+	typeString:     (*Decoder).convertString,
+	typeDuration:   (*Decoder).convertDuration,
+	typeTime:       (*Decoder).convertTime,
+	typeFileMode:   (*Decoder).convertFileMode,
+	typeJSONNumber: (*Decoder).convertJSONNumber,
+}
+
+// fieldAssigner sets the field of val that a tFieldMeta locates to value,
+// allocating any pointers on the way, and returns the field.
+type fieldAssigner func(d *Decoder, val reflect.Value, value []byte) (reflect.Value, error)
+
+// compileAssign returns the fieldAssigner of tfm, which has the converter
+// of its type and the way to its field worked out once, when the struct is
+// parsed, rather than for every value.  It returns nil for the fields which
+// allocAssign must deal with: collections, encoded values, values checked
+// by oneof, min or max, and types without a converter.
+func compileAssign(tfm *tFieldMeta) fieldAssigner {
+	conv, ok := converters[tfm.computedType]
+	if !ok || tfm.isSpecial() || tfm.tlsPart != "" || tfm.oneof != nil || tfm.min.IsValid() || tfm.max.IsValid() {
+		return nil
+	}
+	params := tfm.params
+	pointers := false
+	index := make([]int, 0, len(tfm.locators))
+	for _, loc := range tfm.locators {
+		if loc.isSlice || loc.isMap || loc.isEncoded {
+			return nil
+		}
+		pointers = pointers || loc.ptrCt > 0
+		index = append(index, loc.ind)
+	}
+
+	if !pointers {
+		return func(d *Decoder, val reflect.Value, value []byte) (reflect.Value, error) {
+			fv := val.FieldByIndex(index)
+			return fv, conv(d, value, fv, params)
+		}
+	}
+	locators := tfm.locators
+	return func(d *Decoder, val reflect.Value, value []byte) (reflect.Value, error) {
+		fv := val
+		for _, loc := range locators {
+			fv = fv.Field(loc.ind)
+			for i := uint8(0); i < loc.ptrCt; i++ {
+				if fv.IsNil() {
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+		}
+		return fv, conv(d, value, fv, params)
+	}
+}
+
+func (d *Decoder) convertInt(data []byte, tval reflect.Value, params map[string]string) error {
+	if dval, unit, ok := durationParam(data, params); ok {
+		if dval%unit != 0 {
+			return fmt.Errorf("%s is not a whole number of %s", data, params[paramDuration])
+		}
+		tval.SetInt(int64(dval / unit))
+		return nil
+	}
+	ival, err := strconv.ParseInt(string(data), paramBaseValue(params), 64)
+	if err != nil && d.WeaklyTypedInput {
+		if f, ok := weakFloat(string(data)); ok && f == math.Trunc(f) {
+			ival, err = int64(f), nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	tval.SetInt(ival)
+	return nil
+}
+
+func (d *Decoder) convertUint(data []byte, tval reflect.Value, params map[string]string) error {
+	if dval, unit, ok := durationParam(data, params); ok {
+		if dval < 0 || dval%unit != 0 {
+			return fmt.Errorf("%s is not a whole number of %s", data, params[paramDuration])
+		}
+		tval.SetUint(uint64(dval / unit))
+		return nil
+	}
+	uival, err := strconv.ParseUint(string(data), paramBaseValue(params), 64)
+	if err != nil && d.WeaklyTypedInput {
+		if f, ok := weakFloat(string(data)); ok && f >= 0 && f == math.Trunc(f) {
+			uival, err = uint64(f), nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	tval.SetUint(uival)
+	return nil
+}
+
+func (d *Decoder) convertFloat(data []byte, tval reflect.Value, params map[string]string) error {
+	if dval, unit, ok := durationParam(data, params); ok {
+		tval.SetFloat(float64(dval) / float64(unit))
+		return nil
+	}
+	fval, err := strconv.ParseFloat(string(data), 64)
+	if err != nil && d.DecimalComma {
+		if f, ok := commaFloat(string(data)); ok {
+			fval, err = f, nil
+		}
+	}
+	if err != nil && d.WeaklyTypedInput {
+		if f, ok := weakFloat(string(data)); ok {
+			fval, err = f, nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	tval.SetFloat(fval)
+	return nil
+}
+
+func (d *Decoder) convertBool(data []byte, tval reflect.Value, _ map[string]string) error {
+	bval, err := strconv.ParseBool(string(data))
+	if err != nil && d.WeaklyTypedInput {
+		if b, ok := weakBool(string(data)); ok {
+			bval, err = b, nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	tval.SetBool(bval)
+	return nil
+}
+
+func (d *Decoder) convertString(data []byte, tval reflect.Value, _ map[string]string) error {
+	if err := d.checkString(data); err != nil {
+		return err
+	}
+	tval.SetString(string(data))
+	return nil
+}
+
+func (d *Decoder) convertDuration(data []byte, tval reflect.Value, params map[string]string) error {
+	dval, err := time.ParseDuration(string(data))
+	if unit, ok := paramDurationValue(params); ok && err != nil {
+		// A bare number is a number of the unit.
+		if f, ferr := strconv.ParseFloat(string(data), 64); ferr == nil {
+			dval, err = time.Duration(f*float64(unit)), nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	tval.SetInt(int64(dval))
+	return nil
+}
+
+func (d *Decoder) convertTime(data []byte, tval reflect.Value, params map[string]string) error {
+	tv, err := time.Parse(paramLayoutValue(params), string(data))
+	if err != nil {
+		return err
+	}
+	tval.Set(reflect.ValueOf(tv))
+	return nil
+}
+
+func (d *Decoder) convertFileMode(data []byte, tval reflect.Value, _ map[string]string) error {
+	// File modes are conventionally written in octal, as in 0644.
+	mode, err := strconv.ParseUint(strings.TrimPrefix(string(data), "0o"), 8, 32)
+	if err != nil {
+		return err
+	}
+	tval.SetUint(mode)
+	return nil
+}
+
+func (d *Decoder) convertJSONNumber(data []byte, tval reflect.Value, _ map[string]string) error {
+	if !isJSONNumber(data) {
+		return fmt.Errorf("invalid number %q", data)
+	}
+	tval.SetString(string(data))
+	return nil
+}
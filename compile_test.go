@@ -0,0 +1,85 @@
+package decoder
+
+import (
+	"encoding/json"
+	"io/fs"
+	"reflect"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbCompiled struct {
+	Hex     int           `decoder:",base=16"`
+	Millis  *int64        `decoder:",duration=ms"`
+	Wait    time.Duration `decoder:",duration=s"`
+	Mode    fs.FileMode
+	Number  json.Number
+	Started time.Time `decoder:",layout=2006-01-02"`
+	Inner   **struct {
+		Name string
+	}
+	Port  int      `decoder:",min=1"`
+	Hosts []string `decoder:",csv"`
+}
+
+func TestCompileAssign(t *testing.T) {
+	meta, _, err := defaultDecoder.typeCache().tMeta(defaultDecoder, reflect.TypeOf(tbCompiled{}), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for k, compiled := range map[string]bool{
+		"hex": true, "millis": true, "wait": true, "mode": true, "number": true,
+		"started": true, "inner/name": true, "port": false, "hosts": false,
+	} {
+		if tfm := meta.tFieldsMetaMap[k]; (tfm.assign != nil) != compiled {
+			t.Errorf("%s: expected compiled to be %t", k, compiled)
+		}
+	}
+
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/hex", Value: []byte("ff")},
+		{Key: prefix + "/hosts", Value: []byte("a,b")},
+		{Key: prefix + "/inner/name", Value: []byte("inner")},
+		{Key: prefix + "/millis", Value: []byte("2s")},
+		{Key: prefix + "/mode", Value: []byte("0644")},
+		{Key: prefix + "/number", Value: []byte("1.5e3")},
+		{Key: prefix + "/port", Value: []byte("80")},
+		{Key: prefix + "/started", Value: []byte("2020-03-04")},
+		{Key: prefix + "/wait", Value: []byte("90")},
+	}
+	tc := &tbCompiled{}
+	if err := Unmarshal(prefix, kvs, tc); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	switch {
+	case tc.Hex != 255:
+		t.Errorf("expected hex 255, got %d", tc.Hex)
+	case tc.Millis == nil || *tc.Millis != 2000:
+		t.Errorf("expected 2000 millis, got %v", tc.Millis)
+	case tc.Wait != 90*time.Second:
+		t.Errorf("expected a wait of 90s, got %s", tc.Wait)
+	case tc.Mode != 0644:
+		t.Errorf("expected mode 0644, got %o", tc.Mode)
+	case tc.Number != "1.5e3":
+		t.Errorf("expected number 1.5e3, got %s", tc.Number)
+	case !tc.Started.Equal(time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC)):
+		t.Errorf("unexpected start %s", tc.Started)
+	case tc.Inner == nil || *tc.Inner == nil || (*tc.Inner).Name != "inner":
+		t.Errorf("expected inner to be allocated and set, got %v", tc.Inner)
+	case tc.Port != 80 || !reflect.DeepEqual(tc.Hosts, []string{"a", "b"}):
+		t.Errorf("unexpected port %d and hosts %q", tc.Port, tc.Hosts)
+	}
+
+	// A value which doesn't parse leaves the field as it was when lenient.
+	d := &Decoder{Lenient: true}
+	tc = &tbCompiled{Hex: 1}
+	report, err := d.UnmarshalReport(prefix, consulapi.KVPairs{{Key: prefix + "/hex", Value: []byte("zz")}}, tc)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tc.Hex != 1 || report.Stats.ParseErrors != 1 {
+		t.Errorf("expected hex to be left as 1 with a parse error, got %d and %d", tc.Hex, report.Stats.ParseErrors)
+	}
+}
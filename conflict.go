@@ -0,0 +1,131 @@
+package decoder
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ConflictPolicy - what to do when a key is both a value and a folder, such
+// as when both "db" and "db/host" exist.  Which of the two ends up being
+// decoded is otherwise undefined.
+type ConflictPolicy int
+
+const (
+	// ConflictReport - decode as usual, adding a warning to the Report.
+	ConflictReport ConflictPolicy = iota
+	// ConflictError - fail the decode.
+	ConflictError
+	// ConflictPreferLeaf - decode the value, ignoring the keys inside the
+	// folder.
+	ConflictPreferLeaf
+	// ConflictPreferFolder - decode the keys inside the folder, ignoring
+	// the value.
+	ConflictPreferFolder
+)
+
+// resolveConflicts looks for keys under pathPrefix that are also folders,
+// and handles them according to the decoder's policy, returning the pairs
+// that should be decoded.  An empty pathPrefix is the root of the tree, so
+// every key is under it.
+func (d *Decoder) resolveConflicts(state *decodeState, pathPrefix string, kvps api.KVPairs) (api.KVPairs, error) {
+	relKey := func(kvp *api.KVPair) (string, bool) {
+		if strings.HasSuffix(kvp.Key, "/") {
+			return "", false
+		}
+		k, ok := d.cutPrefix(kvp.Key, pathPrefix)
+		if !d.CaseSensitive {
+			k = strings.ToLower(k)
+		}
+		return k, ok
+	}
+
+	leaves := make(map[string]*api.KVPair)
+	for _, kvp := range kvps {
+		if k, ok := relKey(kvp); ok {
+			leaves[k] = kvp
+		}
+	}
+
+	// leaves that are also folders, and pairs inside of those folders.
+	conflicted := make(map[string]bool)
+	inside := make(map[*api.KVPair]bool)
+	for _, kvp := range kvps {
+		k, ok := relKey(kvp)
+		if !ok {
+			continue
+		}
+		for dir := path.Dir(k); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if _, ok := leaves[dir]; ok {
+				conflicted[dir] = true
+				inside[kvp] = true
+			}
+		}
+	}
+	if len(conflicted) == 0 {
+		return kvps, nil
+	}
+
+	var resolved api.KVPairs
+	for _, kvp := range kvps {
+		k, ok := relKey(kvp)
+		if !ok || !(conflicted[k] || inside[kvp]) {
+			resolved = append(resolved, kvp)
+			continue
+		}
+		if !conflicted[k] {
+			if d.Conflicts != ConflictPreferLeaf {
+				resolved = append(resolved, kvp)
+			}
+			continue
+		}
+		switch d.Conflicts {
+		case ConflictError:
+			return nil, fmt.Errorf("key %s is both a value and a folder", kvp.Key)
+		case ConflictPreferFolder:
+			state.report.warn(kvp.Key, "key is both a value and a folder, ignoring the value")
+		case ConflictPreferLeaf:
+			state.report.warn(kvp.Key, "key is both a value and a folder, ignoring the folder")
+			resolved = append(resolved, kvp)
+		default:
+			state.report.warn(kvp.Key, "key is both a value and a folder")
+			resolved = append(resolved, kvp)
+		}
+	}
+	return resolved, nil
+}
+
+// checkCollisions looks for keys under pathPrefix that only differ by case,
+// which would otherwise silently overwrite each other when decoding case
+// insensitively.  As for resolveConflicts, an empty pathPrefix is the root.
+func (d *Decoder) checkCollisions(state *decodeState, pathPrefix string, kvps api.KVPairs) error {
+	if d.CaseSensitive || d.KeyCollisions == IssueIgnore {
+		return nil
+	}
+	seen := make(map[string]string)
+	for _, kvp := range kvps {
+		if strings.HasSuffix(kvp.Key, "/") {
+			continue
+		}
+		k, ok := d.cutPrefix(kvp.Key, pathPrefix)
+		if !ok {
+			continue
+		}
+		k = strings.ToLower(k)
+		other, ok := seen[k]
+		if !ok {
+			seen[k] = kvp.Key
+			continue
+		}
+		if other == kvp.Key {
+			continue
+		}
+		if d.KeyCollisions == IssueError {
+			return fmt.Errorf("key %s collides with %s", kvp.Key, other)
+		}
+		state.report.warn(kvp.Key, "collides with %s", other)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package decoder
+
+import (
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbConflict struct {
+	Name string
+	DB   TestStruct `decoder:"db"`
+}
+
+func TestConflicts(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/DB", Value: []byte("leaf")},
+		{Key: prefix + "/db/field1", Value: []byte("folder")},
+		{Key: prefix + "/name", Value: []byte("name")},
+	}
+
+	tests := []struct {
+		policy   ConflictPolicy
+		fail     bool
+		field1   string
+		warnings int
+	}{
+		{ConflictReport, false, "folder", 1},
+		{ConflictError, true, "", 0},
+		{ConflictPreferLeaf, false, "", 1},
+		{ConflictPreferFolder, false, "folder", 1},
+	}
+
+	for _, test := range tests {
+		cfg := &tbConflict{}
+		report, err := (&Decoder{Conflicts: test.policy}).UnmarshalReport(prefix, kvs, cfg)
+		if test.fail {
+			if err == nil {
+				t.Errorf("policy %d: expected error", test.policy)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("policy %d: unexpected error: %s", test.policy, err)
+			continue
+		}
+		if cfg.Name != "name" || cfg.DB.Field1 != test.field1 {
+			t.Errorf("policy %d: unexpected result: %+v", test.policy, cfg)
+		}
+		if len(report.Warnings) != test.warnings {
+			t.Errorf("policy %d: expected %d warnings, got %v", test.policy, test.warnings, report.Warnings)
+		} else if test.warnings > 0 && report.Warnings[0].Key != prefix+"/DB" {
+			t.Errorf("policy %d: unexpected warning: %s", test.policy, report.Warnings[0])
+		}
+	}
+
+	// An empty prefix is the root of the tree, where conflicts are found
+	// just the same.
+	root := consulapi.KVPairs{
+		{Key: "DB", Value: []byte("leaf")},
+		{Key: "db/field1", Value: []byte("folder")},
+	}
+	if err := (&Decoder{Conflicts: ConflictError}).Unmarshal("", root, &tbConflict{}); err == nil {
+		t.Error("expected error for a conflict below an empty prefix")
+	}
+}
+
+func TestKeyCollisions(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/Field1", Value: []byte("upper")},
+		{Key: prefix + "/field1", Value: []byte("lower")},
+		{Key: prefix + "/field2", Value: []byte("value2")},
+	}
+
+	report, err := (&Decoder{}).UnmarshalReport(prefix, kvs, &TestStruct{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(report.Warnings) != 1 || report.Warnings[0].Key != prefix+"/field1" {
+		t.Errorf("expected a collision warning, got %v", report.Warnings)
+	}
+
+	if _, err := (&Decoder{KeyCollisions: IssueError}).UnmarshalReport(prefix, kvs, &TestStruct{}); err == nil {
+		t.Error("expected collision error")
+	}
+
+	report, err = (&Decoder{KeyCollisions: IssueIgnore}).UnmarshalReport(prefix, kvs, &TestStruct{})
+	if err != nil || len(report.Warnings) != 0 {
+		t.Errorf("expected collision to be ignored, got %v, %v", report.Warnings, err)
+	}
+
+	report, err = (&Decoder{CaseSensitive: true, KeyCollisions: IssueError}).UnmarshalReport(prefix, kvs, &TestStruct{})
+	if err != nil || len(report.Warnings) != 0 {
+		t.Errorf("expected no collisions when case sensitive, got %v, %v", report.Warnings, err)
+	}
+
+	root := consulapi.KVPairs{
+		{Key: "Field1", Value: []byte("upper")},
+		{Key: "field1", Value: []byte("lower")},
+	}
+	if _, err := (&Decoder{KeyCollisions: IssueError}).UnmarshalReport("", root, &TestStruct{}); err == nil {
+		t.Error("expected collision error below an empty prefix")
+	}
+}
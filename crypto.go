@@ -0,0 +1,214 @@
+package decoder
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// The subkeys of a tls.Certificate folder.
+const (
+	tlsPartCert = "cert"
+	tlsPartKey  = "key"
+)
+
+// isCertChain returns true if t is a slice of x509.Certificate or
+// *x509.Certificate, which are read from a single value holding the PEM
+// encoded chain.
+func isCertChain(t reflect.Type) bool {
+	if t.Kind() != reflect.Slice {
+		return false
+	}
+	t = t.Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	ct, _ := specialType(t)
+	return ct == typeCertificate
+}
+
+// parseCertificates returns the certificates found in the PEM encoded data,
+// in order.  Blocks other than certificates are ignored.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM encoded certificates found")
+	}
+	return certs, nil
+}
+
+// setCertChain sets tval, a slice of x509.Certificate or *x509.Certificate,
+// from the certificates in data.
+func setCertChain(tval reflect.Value, data []byte) error {
+	certs, err := parseCertificates(data)
+	if err != nil {
+		return err
+	}
+	chain := reflect.MakeSlice(tval.Type(), 0, len(certs))
+	for _, cert := range certs {
+		v := reflect.ValueOf(cert)
+		if chain.Type().Elem().Kind() != reflect.Ptr {
+			v = v.Elem()
+		}
+		chain = reflect.Append(chain, v)
+	}
+	tval.Set(chain)
+	return nil
+}
+
+// parsePrivateKey returns the first private key found in the PEM encoded
+// data, which may be in PKCS #1, PKCS #8 or SEC 1 form.
+func parsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no PEM encoded private key found")
+		}
+		if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			continue
+		}
+		if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+			return key, nil
+		}
+		if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+			switch key := key.(type) {
+			case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+				return key, nil
+			default:
+				return nil, fmt.Errorf("unsupported private key type %T", key)
+			}
+		}
+		if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+			return key, nil
+		}
+		return nil, errors.New("unable to parse private key")
+	}
+}
+
+// setTLSCertificate sets part of cert from data.  If part is empty, data
+// holds both the certificate chain and the private key.  Otherwise it holds
+// just the one named by part, and the other is left alone, so that the two
+// subkeys of a folder can be decoded in either order.  If paired is set, the
+// other part was set by the same decode, and the key is checked against the
+// certificate.  Otherwise the other part may be left over from a previous
+// decode, and isn't checked.
+func setTLSCertificate(cert *tls.Certificate, part string, data []byte, paired bool) error {
+	switch part {
+	case "":
+		kp, err := tls.X509KeyPair(data, data)
+		if err != nil {
+			return err
+		}
+		*cert = kp
+		if cert.Leaf == nil {
+			cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		}
+		return err
+	case tlsPartCert:
+		certs, err := parseCertificates(data)
+		if err != nil {
+			return err
+		}
+		cert.Certificate = nil
+		for _, c := range certs {
+			cert.Certificate = append(cert.Certificate, c.Raw)
+		}
+		cert.Leaf = certs[0]
+	case tlsPartKey:
+		key, err := parsePrivateKey(data)
+		if err != nil {
+			return err
+		}
+		cert.PrivateKey = key
+	}
+
+	if !paired || cert.Leaf == nil || cert.PrivateKey == nil {
+		return nil
+	}
+	pub, ok := cert.Leaf.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	signer, sok := cert.PrivateKey.(crypto.Signer)
+	if !ok || !sok || !pub.Equal(signer.Public()) {
+		return errors.New("private key does not match certificate")
+	}
+	return nil
+}
+
+// parsePublicKey returns the public key in data, which may be PEM encoded,
+// or base64 encoded DER.  PKIX and PKCS #1 keys are accepted, as are
+// certificates, in which case the key of the certificate is returned.  A
+// base64 encoded raw ed25519 key is also accepted.
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	var der []byte
+	if block, _ := pem.Decode(data); block != nil {
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			return cert.PublicKey, nil
+		case "RSA PUBLIC KEY":
+			return x509.ParsePKCS1PublicKey(block.Bytes)
+		}
+		der = block.Bytes
+	} else {
+		var err error
+		der, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("public key is neither PEM nor base64 encoded: %s", err)
+		}
+	}
+	if key, err := x509.ParsePKIXPublicKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return key, nil
+	}
+	if len(der) == ed25519.PublicKeySize {
+		return ed25519.PublicKey(der), nil
+	}
+	return nil, errors.New("unable to parse public key")
+}
+
+// setPublicKey sets tval, an rsa.PublicKey, ecdsa.PublicKey or
+// ed25519.PublicKey, from the key in data.  It is an error for the key to be of another type.
+func setPublicKey(tval reflect.Value, data []byte) error {
+	key, err := parsePublicKey(data)
+	if err != nil {
+		return err
+	}
+	kv := reflect.ValueOf(key)
+	if kv.Kind() == reflect.Ptr && tval.Kind() != reflect.Ptr {
+		kv = kv.Elem()
+	}
+	if kv.Type() != tval.Type() {
+		return fmt.Errorf("expected %s public key, got %T", tval.Type(), key)
+	}
+	tval.Set(kv)
+	return nil
+}
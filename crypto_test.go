@@ -0,0 +1,176 @@
+package decoder
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+// testCertificate returns a self signed certificate and its private key,
+// both PEM encoded.
+func testCertificate(t *testing.T, name string) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create certificate: %s", err)
+	}
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unable to marshal key: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder})
+}
+
+type tbCrypto struct {
+	CA       *x509.Certificate
+	Chain    []*x509.Certificate
+	Combined tls.Certificate
+	Split    *tls.Certificate
+	Peers    map[string]*x509.Certificate
+}
+
+func TestCertificates(t *testing.T) {
+	caPEM, _ := testCertificate(t, "ca")
+	leafPEM, leafKey := testCertificate(t, "leaf")
+	otherPEM, otherKey := testCertificate(t, "other")
+
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/ca", Value: caPEM},
+		{Key: prefix + "/chain", Value: append(append([]byte{}, leafPEM...), caPEM...)},
+		{Key: prefix + "/combined", Value: append(append([]byte{}, leafPEM...), leafKey...)},
+		{Key: prefix + "/peers/a", Value: otherPEM},
+		{Key: prefix + "/split/cert", Value: otherPEM},
+		{Key: prefix + "/split/key", Value: otherKey},
+	}
+
+	cfg := &tbCrypto{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.CA == nil || cfg.CA.Subject.CommonName != "ca" {
+		t.Errorf("unexpected ca: %v", cfg.CA)
+	}
+	if len(cfg.Chain) != 2 || cfg.Chain[0].Subject.CommonName != "leaf" || cfg.Chain[1].Subject.CommonName != "ca" {
+		t.Errorf("unexpected chain: %v", cfg.Chain)
+	}
+	if cfg.Combined.PrivateKey == nil || cfg.Combined.Leaf == nil || cfg.Combined.Leaf.Subject.CommonName != "leaf" {
+		t.Errorf("unexpected combined certificate: %+v", cfg.Combined)
+	}
+	if cfg.Split == nil || cfg.Split.PrivateKey == nil || cfg.Split.Leaf == nil || cfg.Split.Leaf.Subject.CommonName != "other" {
+		t.Errorf("unexpected split certificate: %+v", cfg.Split)
+	}
+	if cfg.Peers["a"] == nil || cfg.Peers["a"].Subject.CommonName != "other" {
+		t.Errorf("unexpected peers: %v", cfg.Peers)
+	}
+
+	kvs = consulapi.KVPairs{
+		{Key: prefix + "/split/cert", Value: otherPEM},
+		{Key: prefix + "/split/key", Value: leafKey},
+	}
+	err := Unmarshal(prefix, kvs, &tbCrypto{})
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected mismatch error, got %v", err)
+	}
+
+	// Rotating both parts isn't checked against the parts they replace.
+	kvs = consulapi.KVPairs{
+		{Key: prefix + "/split/cert", Value: leafPEM},
+		{Key: prefix + "/split/key", Value: leafKey},
+	}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error rotating the certificate: %s", err)
+	}
+	if cfg.Split.Leaf.Subject.CommonName != "leaf" {
+		t.Errorf("unexpected rotated certificate: %+v", cfg.Split)
+	}
+
+	kvs = consulapi.KVPairs{{Key: prefix + "/ca", Value: []byte("not a certificate")}}
+	if err := Unmarshal(prefix, kvs, &tbCrypto{}); err == nil {
+		t.Error("expected error for invalid certificate")
+	}
+}
+
+type tbPublicKey struct {
+	RSA     *rsa.PublicKey
+	ECDSA   *ecdsa.PublicKey
+	Ed25519 ed25519.PublicKey
+	Raw     ed25519.PublicKey
+	FromPEM *ecdsa.PublicKey
+}
+
+func TestPublicKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("unable to generate key: %s", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %s", err)
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %s", err)
+	}
+	marshal := func(key interface{}) []byte {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatalf("unable to marshal key: %s", err)
+		}
+		return der
+	}
+	b64 := func(b []byte) []byte {
+		return []byte(base64.StdEncoding.EncodeToString(b))
+	}
+
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/ecdsa", Value: b64(marshal(&ecKey.PublicKey))},
+		{Key: prefix + "/ed25519", Value: b64(marshal(edKey))},
+		{Key: prefix + "/frompem", Value: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: marshal(&ecKey.PublicKey)})},
+		{Key: prefix + "/raw", Value: b64(edKey)},
+		{Key: prefix + "/rsa", Value: pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)})},
+	}
+
+	cfg := &tbPublicKey{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.RSA == nil || !cfg.RSA.Equal(&rsaKey.PublicKey) {
+		t.Errorf("unexpected rsa key: %v", cfg.RSA)
+	}
+	if cfg.ECDSA == nil || !cfg.ECDSA.Equal(&ecKey.PublicKey) || cfg.FromPEM == nil || !cfg.FromPEM.Equal(&ecKey.PublicKey) {
+		t.Errorf("unexpected ecdsa keys: %v, %v", cfg.ECDSA, cfg.FromPEM)
+	}
+	if !cfg.Ed25519.Equal(edKey) || !cfg.Raw.Equal(edKey) {
+		t.Errorf("unexpected ed25519 keys: %v, %v", cfg.Ed25519, cfg.Raw)
+	}
+
+	kvs = consulapi.KVPairs{{Key: prefix + "/rsa", Value: b64(marshal(&ecKey.PublicKey))}}
+	err = Unmarshal(prefix, kvs, &tbPublicKey{})
+	if err == nil || !strings.Contains(err.Error(), "expected rsa.PublicKey") {
+		t.Errorf("expected key type error, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package decoder
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding"
 	"encoding/csv"
 	"encoding/json"
@@ -10,12 +11,15 @@ import (
 	"net"
 	"path"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/hashicorp/consul/api"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 type (
@@ -35,6 +39,19 @@ const (
 	typeNetIP
 	typeNetMask
 	typeTextUnmarshaler
+	typeAtomic
+	typeCertificate
+	typeCertChain
+	typeTLSCertificate
+	typePublicKey
+	typeTCPAddr
+	typeUDPAddr
+	typeHardwareAddr
+	typeFileMode
+	typeTime
+	typeInterface
+	typeJSONNumber
+	typePair
 )
 
 // reset iota
@@ -44,23 +61,117 @@ const (
 	sSSV
 )
 const (
-	tagJSON = "json"
-	tagCSV  = "csv"
-	tagSSV  = "ssv"
-	defTag  = "decoder"
+	tagJSON      = "json"
+	tagMsgpack   = "msgpack"
+	tagCSV       = "csv"
+	tagSSV       = "ssv"
+	tagTrim      = "trim"
+	tagSecret    = "secret"
+	tagService   = "service"
+	tagEncrypted = "encrypted"
+	tagSquash    = "squash"
+	tagRemain    = "remain"
+
+	// These mark fields which describe the decode, rather than being read
+	// from a key.
+	tagDecodedAt = "decodedat"
+	tagLastIndex = "lastindex"
+	tagExists    = "exists"
+	defTag       = "decoder"
+
+	// These take a value, as in oneof=a|b|c
+	tagOneOf = "oneof"
+	tagMin   = "min"
+	tagMax   = "max"
 )
 
-var textUnmarshalerType = reflect.TypeOf(new(encoding.TextUnmarshaler)).Elem()
+// unmarshalFunc decodes an encoded value into v, as json.Unmarshal does.
+type unmarshalFunc func(data []byte, v interface{}) error
+
+// encodings are the modifiers that cause a value to be decoded whole.
+var encodings = map[string]unmarshalFunc{
+	tagJSON:    json.Unmarshal,
+	tagMsgpack: msgpack.Unmarshal,
+}
 
-var typeCache = typeCacheManager{typeNameMetaMap: make(map[string]*tMeta)}
+// numericTypes are the computed types that the min and max modifiers may
+// be used with.
+var numericTypes = map[computedType]bool{
+	typeInt:      true,
+	typeDuration: true,
+	typeUint:     true,
+	typeFloat:    true,
+}
+
+// isReservedModifier returns true if name is used by one of the modifiers
+// or transformers built in to the decoder.
+func isReservedModifier(name string) bool {
+	switch name {
+	case tagJSON, tagMsgpack, tagCSV, tagSSV, tagTrim, tagSecret, tagService, tagEncrypted, tagSquash, tagRemain,
+		tagDecodedAt, tagLastIndex, tagExists, tagOneOf, tagMin, tagMax:
+		return true
+	}
+	_, ok := builtinTransformers[name]
+	return ok
+}
 
-type typeCacheManager struct {
-	lck             sync.RWMutex
-	typeNameMetaMap map[string]*tMeta
+// scalarTypes are the computed types that values can be compared against
+// the values given to the oneof modifier.
+var scalarTypes = map[computedType]bool{
+	typeInt:      true,
+	typeDuration: true,
+	typeUint:     true,
+	typeFloat:    true,
+	typeString:   true,
+	typeBool:     true,
+}
+
+// Flag hints.  When Decoder.UseFlags is set, the Flags field of each KVPair
+// is inspected for these bits, allowing whoever wrote the value to control
+// how it is interpreted, regardless of struct tag modifiers.  When more
+// than one is set, base64 is decoded first, then gzip, and finally the
+// result is handed to json.Unmarshal.
+const (
+	// FlagBase64 - the value is standard base64 encoded.
+	FlagBase64 uint64 = 1 << iota
+	// FlagGzip - the value is gzip compressed.
+	FlagGzip
+	// FlagJSON - the value is json, and will be unmarshaled with
+	// json.Unmarshal into the target.
+	FlagJSON
+)
+
+var textUnmarshalerType = reflect.TypeOf(new(encoding.TextUnmarshaler)).Elem()
+
+// isTextUnmarshaler returns true if t, or a pointer to t, implements
+// encoding.TextUnmarshaler.  Values are always addressable when they are
+// assigned, so UnmarshalText may have a pointer receiver even when the
+// field is not a pointer.
+func isTextUnmarshaler(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		return t.Implements(textUnmarshalerType)
+	}
+	return reflect.PtrTo(t).Implements(textUnmarshalerType)
 }
 
 type tMeta struct {
 	tFieldsMetaMap map[string]*tFieldMeta
+
+	// stamps are the fields with the decodedat or lastindex modifiers,
+	// which aren't read from keys.
+	stamps []*tFieldMeta
+
+	// unknown are the modifiers of the fields which aren't known, including
+	// those of the structs nested in it.
+	unknown []unknownModifier
+
+	// remain is the field with the remain modifier, if there is one, which
+	// holds the values of the keys without a field of their own.
+	remain *tFieldMeta
+
+	// flat is the setter table of a struct of scalars, which are decoded
+	// without walking up their keys.  See flatFields.
+	flat []flatField
 }
 
 type tFieldMeta struct {
@@ -71,6 +182,10 @@ type tFieldMeta struct {
 
 	fieldName string
 
+	// name is fieldName as it was given, before it is lower cased, which
+	// is how keys are shown to people.
+	name string
+
 	// computedType distills the type that the locators refers to,
 	// will be one of the type* constants defined above.
 	computedType computedType
@@ -78,6 +193,60 @@ type tFieldMeta struct {
 	// This is used to capture "special" considerations, currently CSV
 	// and SSV (space separated values).
 	special special
+
+	// trim is set by the trim modifier, to strip surrounding whitespace
+	// from values before they're parsed.
+	trim bool
+
+	// secret is set by the secret modifier, so that values of the field,
+	// or of the fields of a struct, are redacted before they're shown.
+	secret bool
+
+	// service is set by the service modifier, so that values are the names
+	// of services, which are resolved to addresses.
+	service bool
+
+	// stamp is the decodedat or lastindex modifier, if the field has one.
+	stamp string
+
+	// squash and remain are set by the modifiers of the same names.  See
+	// Decoder.Mapstructure.
+	squash, remain bool
+
+	// transforms are applied to values, in order, before they are
+	// interpreted.
+	transforms []namedTransform
+
+	// tlsPart is set for the metadata of the cert and key subkeys of a
+	// tls.Certificate field, and is empty for the field itself.
+	tlsPart string
+
+	// unmarshal decodes values for fields with the json or msgpack
+	// modifiers, or a modifier registered with RegisterModifier.
+	unmarshal unmarshalFunc
+
+	// params holds any other key=value modifiers, such as layout and base,
+	// which affect how values are parsed.
+	params map[string]string
+
+	// oneof holds the values permitted by the oneof modifier, if any.
+	oneof []string
+
+	// The values given to the min and max modifiers, if any.  These are
+	// parsed into min and max once the type of the field is known.
+	minStr, maxStr string
+	min, max       reflect.Value
+
+	// For sync/atomic types, the type passed to their Store method, and how
+	// to compute a value of that type.  For atomic.Pointer[T], atomicType is
+	// T rather than *T, and atomicPointer is set.
+	atomicType         reflect.Type
+	atomicComputedType computedType
+	atomicPointer      bool
+
+	// assign sets the field from a value, for the fields compileAssign
+	// can deal with, and is nil for the others.
+	assign fieldAssigner
 }
 
 func (tfm *tFieldMeta) isCSV() bool {
@@ -96,12 +265,50 @@ func (tfm *tFieldMeta) isSpecial() bool {
 	return !tfm.isNotSpecial()
 }
 
+// nestsStruct returns true if values for this field are decoded into
+// the fields of structs inside of a map or slice.
+func (tfm *tFieldMeta) nestsStruct() bool {
+	return tfm.computedType == typeStruct && !tfm.locators[len(tfm.locators)-1].isEncoded
+}
+
+// EmptyValueMode - how values that are empty are treated.
+type EmptyValueMode int
+
+const (
+	// EmptyDefault - the historical behavior, which depends on the type.
+	// Empty strings and byte slices are assigned, IPs and masks are left
+	// alone, and most other types fail to parse.
+	EmptyDefault EmptyValueMode = iota
+	// EmptyAsMissing - empty values are skipped, as if the key didn't exist.
+	EmptyAsMissing
+	// EmptyAsZero - empty values set the field to its zero value.
+	EmptyAsZero
+	// EmptyError - empty values fail the decode.
+	EmptyError
+)
+
 // NameResolverFunc - this allows us to define a custom
-// name resolution to override the default.
+// name resolution to override the default.  It must be pure, returning the
+// same key whenever it is given the same field and tag, as the keys it
+// returns are kept in the type cache, and it must be safe for concurrent
+// use.  It is called while the type cache is locked, so expensive resolvers
+// are best paired with Decoder.Precompile.
 type NameResolverFunc func(field, tag string) (key string)
 
 // Decoder - define one of these if you want to override
 // default behavior.  Otherwise just use Unmarshal()
+//
+// A Decoder is safe for concurrent use by multiple goroutines, decoding into
+// the same or different types, so long as its fields aren't changed once it
+// is in use.  The NameResolver and Metrics given to it must be safe for
+// concurrent use too, as must any modifiers and transformers registered with
+// it.  Decoding into the same value from more than one goroutine at a time
+// is not safe, as with encoding/json.
+//
+// A Decoder must not be copied, as go vet reports, since what is registered
+// with it and what it remembers between decodes would be shared by the copy
+// once it was in use, but not before.  Build a new one with the same
+// settings instead.
 type Decoder struct {
 	// If true, then field names must match key exactly.
 	CaseSensitive bool
@@ -109,6 +316,169 @@ type Decoder struct {
 	NameResolver NameResolverFunc
 	// The struct tag to parse.  defaults to "decoder"
 	Tag string
+	// If true, struct tags are read as github.com/mitchellh/mapstructure
+	// reads them, so that structs tagged for it, as for viper, needn't be
+	// tagged again.  The tag defaults to "mapstructure", and its omitempty
+	// modifier, which only matters when encoding, is ignored.  The squash
+	// and remain modifiers are understood either way.
+	Mapstructure bool
+	// If set, the separator of the folders in keys below the prefix, and in
+	// the names given in struct tags, in place of "/", for trees migrated
+	// from stores such as etcd which use "." or ":".  "/" still separates
+	// folders too.  Keys are given with "/" in reports and errors.
+	PathSeparator string
+	// If true, values that fail strict parsing are coerced where that can be
+	// done sensibly: "yes", "on", "" and numbers into bools, "true" and
+	// "false" into numbers, "1.0" into integers, and so on.  Useful for
+	// messy legacy trees.
+	WeaklyTypedInput bool
+	// If true, floats may be written with a comma as the decimal separator,
+	// as in "1,5" or "1.234,5", where "." and spaces group the thousands.
+	// Values without a comma are parsed as usual, so "1.234" is still
+	// 1.234.  csv slices of floats need another sep.
+	DecimalComma bool
+	// If true, values decoded into interface{} fields, or into maps and
+	// slices of interface{}, are given the type they look like: a bool, an
+	// int64, a float64 or, failing those, a string.  Otherwise they are
+	// always strings.
+	InferTypes bool
+	// If true, surrounding whitespace is trimmed from all values before
+	// they're parsed, as if every field had the trim modifier.
+	TrimSpace bool
+	// If true, values may hold the {{ }} actions of consul-template, as in
+	// {{ env "PORT" | default "8080" }}, which are expanded before they're
+	// trimmed and parsed.  Only the env, envOrDefault and default functions
+	// are supported, so that a tree written for consul-template can be read
+	// without values being able to do anything more.
+	Templates bool
+	// How empty values are treated.  Defaults to EmptyDefault.
+	EmptyValues EmptyValueMode
+	// If true, a value that cannot be converted to its field's type is
+	// skipped, leaving the field as it was, and a warning is added to the
+	// Report rather than failing the decode.
+	Lenient bool
+	// What to do when a key is both a value and a folder, such as "db" and
+	// "db/host".  Defaults to ConflictReport.
+	Conflicts ConflictPolicy
+	// What to do when CaseSensitive is false and keys differ only by case,
+	// such as "Timeout" and "timeout".  Defaults to IssueWarn.
+	KeyCollisions IssuePolicy
+	// What to do with keys passed to Unmarshal which aren't under the
+	// prefix, and so are skipped.  Defaults to IssueWarn.
+	OutsidePrefix IssuePolicy
+	// What to do with modifiers in struct tags which aren't known, such as a
+	// misspelt ",jsno", which would otherwise be silently ignored.  Warnings
+	// are given once per type per decode, and with IssueError, Precompile
+	// fails too.  Defaults to IssueWarn.
+	UnknownModifiers IssuePolicy
+	// If set, keys and folders qualified by this environment, as in
+	// "timeout@staging" or "db@staging/host", are decoded in place of the
+	// unqualified ones, so that one tree can serve several environments
+	// with only their differences duplicated.  Keys qualified by other
+	// environments are skipped, so "@" can't otherwise be used in keys
+	// while this is set.  Keys are resolved before they are filtered.
+	Environment string
+	// Further qualifiers, such as an instance ID, availability zone and
+	// region, most specific first, so that "timeout@us-east-1" overrides
+	// "timeout", and "timeout@i-0abc" overrides both.  These are preferred
+	// over Environment, and otherwise work the same way.
+	Qualifiers []string
+	// Patterns, as understood by path.Match, selecting the keys below the
+	// prefix to decode, so that a service sharing a prefix with others can
+	// ignore parts of it.  A pattern selects a key if it matches the key or
+	// any of the folders it is in, so "*/internal" selects everything in
+	// the internal folder of any folder.  If IncludeKeys is given, only the
+	// keys it selects are decoded, and the keys ExcludeKeys selects are
+	// never decoded.  A pattern beginning with "**/" matches at any depth,
+	// so "**/.lock" selects every key named .lock.  Keys are filtered before
+	// they are matched to fields.
+	IncludeKeys []string
+	ExcludeKeys []string
+	// If true, keys below the prefix which are hidden, in that they or one
+	// of the folders they are in begin with ".", are skipped, as the
+	// bookkeeping keys other tools write beside the configuration often
+	// are.
+	IgnoreHidden bool
+	// What to do with keys held by a session, such as the lock and leader
+	// keys written under the prefix by other processes, which would
+	// otherwise turn up in maps and slices.  Defaults to SessionDecode.
+	Sessions SessionMode
+	// If set, this is informed of the outcome of every call to Unmarshal.
+	Metrics Metrics
+	// If set, this is called whenever a value is assigned, with its key, the
+	// name of its field, with the names of the structs it is nested in, and
+	// the value, so that configuration can be audited as it is applied.
+	// For maps and slices, it is called with each element.  Values of
+	// fields with the secret modifier, and of the fields of structs with
+	// it, are given as RedactedValue.
+	OnAssign func(key string, field string, value interface{})
+	// Limits on the tree being decoded, which protect against pathological
+	// trees fetched with an overly broad prefix.  MaxDepth is the number of
+	// levels below the prefix, MaxKeys the number of keys under the prefix,
+	// and MaxValueSize the length in bytes of any one value, both as it is
+	// and once decoded, decompressed or otherwise transformed.  Zero means
+	// no limit.
+	MaxDepth     int
+	MaxKeys      int
+	MaxValueSize int
+	// Guards on the values of string fields, including the elements of maps
+	// and slices of strings and interface{} values, against junk written by
+	// buggy tooling.  MaxStringSize is the length in bytes of any one
+	// string, with zero meaning no limit, and RequireUTF8 rejects strings
+	// which aren't valid UTF-8.  Values which fail them are treated as
+	// values that can't be parsed.
+	MaxStringSize int
+	RequireUTF8   bool
+	// If true, the slices that the pairs of each struct inside of a map or
+	// slice are gathered into are pooled and reused, cutting the garbage
+	// made by services which decode large trees every few seconds.  Off by
+	// default, as the pool holds on to its memory between decodes.
+	PoolBuffers bool
+	// If true, the Flags field of each KVPair is treated as a set of
+	// Flag* encoding hints.  Off by default, as Flags may be used by
+	// applications for any purpose.
+	UseFlags bool
+	// The cache of the metadata gathered from the types decoded into.
+	// Defaults to a cache shared by all decoders, which can be emptied
+	// with ClearTypeCache.
+	Cache *TypeCache
+	// What happens to maps and slices which already hold elements when
+	// they are decoded into again.  Defaults to MergeAppend.
+	Merge MergeMode
+	// If set, this is called with each pair before it is decoded, and may
+	// replace its value or skip it.  Pairs decoded into the fields of
+	// structs inside of maps and slices are passed to it once they reach
+	// their field.
+	PairHook PairHookFunc
+	// How keys nested more than one level below a map of values, as
+	// opposed to a map of structs, are treated.  Defaults to MapDepthFirst.
+	MapDepth MapDepthMode
+	// How the keys below a slice place its elements.  Defaults to
+	// SliceIndexOrder, appending them in the order of their keys.  The
+	// other modes take the keys as indices, as in "list/0" and "list/10",
+	// which under MergeAppend replace the elements already at those
+	// indices.
+	SliceIndex SliceIndexMode
+	// If set, this resolves the values of fields with the service modifier,
+	// which are the names of services, to the address of a healthy instance,
+	// as "host:port".  Values of any field of the form
+	// consul-service://name?tag=primary are resolved too, while it is set.
+	// See HealthServiceResolver and ServiceQuery.
+	ServiceResolver ServiceResolverFunc
+	// If set, this decrypts the values of fields with the encrypted
+	// modifier.  See AESGCM.
+	Decryptor Decryptor
+	// If true, the structs already held by maps, and the values already
+	// held by fields with the json or msgpack modifiers, are decoded into
+	// rather than replaced by new ones, so that state they hold, such as
+	// mutexes and caches derived from the configuration, survives a
+	// decode, and only their decoded fields are overwritten.  Pointers to
+	// structs outside of maps and slices are always reused.  The elements
+	// of slices are always new.
+	ReuseExisting bool
+
+	// reg holds what is registered with the decoder, see registry.
+	reg atomic.Pointer[registry]
 }
 
 func defaultNameResolver(field, tag string) string {
@@ -133,34 +503,16 @@ type tFieldLocator struct {
 
 	isSlice bool
 	isMap   bool
-	isJSON  bool
+
+	// isEncoded is set when the value is decoded whole, by the unmarshal
+	// function of the field, such as with the json modifier.
+	isEncoded bool
 
 	// The actual type of the thing, after all pointers
 	// are derefed.
 	ttype reflect.Type
 }
 
-func (tcm *typeCacheManager) tMeta(d *Decoder, t reflect.Type, lock bool) (*tMeta, error) {
-	// TODO this probably shouldn't lock the world.
-	if lock {
-		tcm.lck.Lock()
-		defer tcm.lck.Unlock()
-	}
-	tk := typeKey(t)
-	if tk == "" {
-		return nil, fmt.Errorf("type cannot be determined")
-	}
-	if tm, ok := tcm.typeNameMetaMap[tk]; ok {
-		return tm, nil
-	}
-	tm, err := d.parseStruct(t)
-	if err != nil {
-		return nil, err
-	}
-	tcm.typeNameMetaMap[tk] = tm
-	return tm, nil
-}
-
 func typeKey(t reflect.Type) string {
 	pp := t.PkgPath()
 	pn := t.Name()
@@ -181,13 +533,15 @@ func (d *Decoder) parseStruct(st reflect.Type) (*tMeta, error) {
 	var tagBits []string
 	var tagLen int
 
-	tagLabel := defTag
-	if d.Tag != "" {
-		tagLabel = d.Tag
-	}
+	tagLabel := d.structTag()
 
 	tm := &tMeta{tFieldsMetaMap: make(map[string]*tFieldMeta)}
 
+	// promote holds the unexported embedded structs, and the structs with
+	// the squash modifier, whose fields are promoted once the struct's own
+	// fields, which take precedence, are known.
+	var promote []int
+
 fieldLoop:
 	for i := 0; i < st.NumField(); i++ {
 		f := st.Field(i)
@@ -199,6 +553,24 @@ fieldLoop:
 			continue
 		}
 
+		// Embedded interfaces can't be decoded into.
+		if f.Anonymous && f.Type.Kind() == reflect.Interface {
+			continue
+		}
+
+		// The exported fields of unexported embedded structs are promoted,
+		// as they are by encoding/json, unless the struct is given a name
+		// in its tag, in which case it is a folder like any other.
+		if f.Anonymous && f.PkgPath != "" && strings.Split(f.Tag.Get(tagLabel), ",")[0] == "" {
+			switch {
+			case f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct:
+				return nil, fmt.Errorf("%s: cannot decode into embedded pointer to unexported struct", f.Name)
+			case f.Type.Kind() == reflect.Struct:
+				promote = append(promote, i)
+			}
+			continue
+		}
+
 		tfm := &tFieldMeta{
 			locators: []tFieldLocator{{ind: i}},
 		}
@@ -227,17 +599,18 @@ fieldLoop:
 		if tfm.fieldName == "-" || tfm.fieldName == "" {
 			continue fieldLoop
 		}
+		if d.PathSeparator != "" {
+			tfm.fieldName = strings.ReplaceAll(tfm.fieldName, d.PathSeparator, "/")
+		}
+		tfm.name = tfm.fieldName
 
 		if tagLen > 1 {
-			for _, tv := range tagBits[1:] {
-				switch tv {
-				case tagJSON:
-					topLoc.isJSON = true
-				case tagCSV:
-					tfm.special = sCSV
-				case tagSSV:
-					tfm.special = sSSV
-				}
+			unknown, err := d.parseModifiers(tfm, tagBits[1:])
+			if err != nil {
+				return nil, err
+			}
+			for _, modifier := range unknown {
+				tm.unknown = append(tm.unknown, unknownModifier{key: tfm.name, modifier: modifier})
 			}
 		}
 
@@ -245,6 +618,31 @@ fieldLoop:
 			tfm.fieldName = strings.ToLower(tfm.fieldName)
 		}
 
+		if tfm.stamp != "" {
+			if err := parseStamp(tfm, f.Type); err != nil {
+				return nil, err
+			}
+			tm.stamps = append(tm.stamps, tfm)
+			continue fieldLoop
+		}
+		if tfm.squash {
+			if err := checkSquash(tfm, f.Type); err != nil {
+				return nil, err
+			}
+			promote = append(promote, i)
+			continue fieldLoop
+		}
+		if tfm.remain {
+			if tm.remain != nil {
+				return nil, fmt.Errorf("%s: only one field may have remain", tfm.fieldName)
+			}
+			if err := parseRemain(tfm, f.Type); err != nil {
+				return nil, err
+			}
+			tm.remain = tfm
+			continue fieldLoop
+		}
+
 		// Initialize t with the field type.
 		t := f.Type
 
@@ -253,7 +651,7 @@ fieldLoop:
 			// Reset ttype with each iteration of the loop.
 			// Will change for pointers, slice types, map types
 			topLoc.ttype = t
-			if t.Implements(textUnmarshalerType) {
+			if isTextUnmarshaler(t) {
 				tfm.computedType = typeTextUnmarshaler
 			}
 			switch t.Kind() {
@@ -275,29 +673,40 @@ fieldLoop:
 			case reflect.Array, reflect.Slice:
 				if isByteSlice(t) {
 
-					switch typeKey(t) {
-					case "net.IP":
-						tfm.computedType = typeNetIP
-					case "net.IPMask":
-						tfm.computedType = typeNetMask
-					default:
+					if ct, ok := specialType(t); ok {
+						tfm.computedType = ct
+					} else if tfm.computedType != typeTextUnmarshaler {
 						tfm.computedType = typeByteSlice
 					}
 
 					tm.tFieldsMetaMap[tfm.fieldName] = tfm
 					break Outer
 				}
+				if tfm.computedType == typeTextUnmarshaler {
+					// Array and slice types implementing TextUnmarshaler,
+					// such as the [16]byte UUID types, are single values.
+					tm.tFieldsMetaMap[tfm.fieldName] = tfm
+					break Outer
+				}
+				if !topLoc.isEncoded && isCertChain(t) {
+					tfm.computedType = typeCertChain
+					tm.tFieldsMetaMap[tfm.fieldName] = tfm
+					break Outer
+				}
 				if topLoc.isSlice {
 					return nil, fmt.Errorf("slices of slices not supported, except [][]byte")
 				}
+				if topLoc.isMap && (!tfm.isSpecial() || topLoc.collPtrCt > 0) {
+					return nil, fmt.Errorf("%s: maps of slices are only supported as map[string][]T, with csv or ssv", tfm.fieldName)
+				}
 				topLoc.isSlice = true
-				if topLoc.isJSON {
+				if topLoc.isEncoded {
 					tm.tFieldsMetaMap[tfm.fieldName] = tfm
 					break Outer
 				}
 				t = t.Elem()
 			case reflect.Map:
-				if topLoc.isJSON {
+				if topLoc.isEncoded {
 					tm.tFieldsMetaMap[tfm.fieldName] = tfm
 					break Outer
 				}
@@ -316,13 +725,49 @@ fieldLoop:
 				t = t.Elem()
 
 			case reflect.Struct:
-				if tfm.isCSV() || tfm.isSSV() {
-					return nil, fmt.Errorf("cannot use a struct type with isSSV or isCSV")
+				if t.PkgPath() == "sync/atomic" {
+					if topLoc.isMap || topLoc.isSlice || tfm.isSpecial() {
+						return nil, fmt.Errorf("%s: atomic types cannot be used in maps, slices, or with csv or ssv", tfm.fieldName)
+					}
+					if err := parseAtomic(tfm, t); err != nil {
+						return nil, err
+					}
+					tm.tFieldsMetaMap[tfm.fieldName] = tfm
+					break Outer
+				}
+				if t == kvPairType && !topLoc.isEncoded {
+					if err := parsePair(tfm); err != nil {
+						return nil, err
+					}
+					tm.tFieldsMetaMap[tfm.fieldName] = tfm
+					break Outer
+				}
+				if ct, ok := specialType(t); ok && !topLoc.isEncoded {
+					// time.Time is only special when given a layout, and is
+					// otherwise left to its UnmarshalText method.
+					if _, layout := tfm.params[paramLayout]; ct != typeTime || layout {
+						tfm.computedType = ct
+						tm.tFieldsMetaMap[tfm.fieldName] = tfm
+						if ct == typeTLSCertificate && !topLoc.isMap && !topLoc.isSlice {
+							// Outside of collections, the certificate and key
+							// may also be given separately as subkeys.
+							for _, part := range []string{tlsPartCert, tlsPartKey} {
+								ptfm := &tFieldMeta{}
+								*ptfm = *tfm
+								ptfm.tlsPart = part
+								tm.tFieldsMetaMap[path.Join(tfm.fieldName, part)] = ptfm
+							}
+						}
+						break Outer
+					}
+				}
+				if (tfm.isCSV() || tfm.isSSV()) && tfm.computedType != typeTextUnmarshaler {
+					return nil, fmt.Errorf("cannot use a struct type with isSSV or isCSV, unless it implements TextUnmarshaler")
 				}
 				if tfm.computedType != typeTextUnmarshaler {
 					tfm.computedType = typeStruct
 				}
-				if topLoc.isMap || topLoc.isSlice || topLoc.isJSON || tfm.computedType == typeTextUnmarshaler {
+				if topLoc.isMap || topLoc.isSlice || topLoc.isEncoded || tfm.computedType == typeTextUnmarshaler {
 					// no need to dive on these.  for maps and slices of structs,
 					// they are handled later in the unmarshal phase.  For JSON or TextUnmarshalers,
 					// we handle those with JSON and UnmarshalText() method calls respectively.
@@ -332,7 +777,7 @@ fieldLoop:
 
 				// If we fall through here, recursively inspect the struct and
 				// pull in its locators into our own, flattening the structure.
-				embedded, err := typeCache.tMeta(d, t, false)
+				embedded, _, err := d.typeCache().tMeta(d, t, false)
 				if err != nil {
 					return nil, err
 				}
@@ -344,11 +789,19 @@ fieldLoop:
 					etfmcp := &tFieldMeta{}
 					*etfmcp = *etfm
 
-					// fix up copy's locators.
+					// fix up copy's locators, and its name, which maps and
+					// slices use to find their keys.
 					etfmcp.locators = append(tfm.locators, etfm.locators...)
+					etfmcp.fieldName = nk
+					etfmcp.name = path.Join(tfm.name, etfm.name)
+					etfmcp.secret = etfm.secret || tfm.secret
 
 					tm.tFieldsMetaMap[nk] = etfmcp
 				}
+				tm.stamps = append(tm.stamps, nestedStamps(tfm, tfm.locators, embedded.stamps)...)
+				for _, um := range embedded.unknown {
+					tm.unknown = append(tm.unknown, unknownModifier{key: path.Join(tfm.name, um.key), modifier: um.modifier})
+				}
 
 				break Outer
 			case reflect.String,
@@ -360,27 +813,24 @@ fieldLoop:
 					if (tfm.isCSV() || tfm.isSSV()) && !topLoc.isSlice {
 						return nil, fmt.Errorf("must use a slice of strings, ints, uints, floats or bools with isCSV or isSSV")
 					}
-					var cType computedType
-					switch t.Kind() {
-					case reflect.String:
-						cType = typeString
-					case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
-						if typeKey(t) == "time.Duration" {
-							cType = typeDuration
-						} else {
-							cType = typeInt
-						}
-					case reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
-						cType = typeUint
-					case reflect.Float64, reflect.Float32:
-						cType = typeFloat
-					case reflect.Bool:
-						cType = typeBool
-					}
-					tfm.computedType = cType
+					tfm.computedType = scalarType(t)
 				}
 				tm.tFieldsMetaMap[tfm.fieldName] = tfm
 
+				break Outer
+			case reflect.Interface:
+				// Only empty interfaces can hold the values we decode,
+				// unless the value is encoded, in which case it's up to
+				// the encoding.
+				if topLoc.isEncoded {
+					tm.tFieldsMetaMap[tfm.fieldName] = tfm
+				} else if t.NumMethod() == 0 {
+					if (tfm.isCSV() || tfm.isSSV()) && !topLoc.isSlice {
+						return nil, fmt.Errorf("must use a slice of strings, ints, uints, floats or bools with isCSV or isSSV")
+					}
+					tfm.computedType = typeInterface
+					tm.tFieldsMetaMap[tfm.fieldName] = tfm
+				}
 				break Outer
 			default:
 				if tfm.computedType == typeTextUnmarshaler {
@@ -389,17 +839,130 @@ fieldLoop:
 				break Outer
 			}
 		}
+
+		if err := checkParams(tfm); err != nil {
+			return nil, err
+		}
+		if tfm.service && !serviceTypes[tfm.computedType] {
+			return nil, fmt.Errorf("%s: service may only be used with strings, addresses and TextUnmarshalers", tfm.fieldName)
+		}
+		if tfm.oneof != nil && !scalarTypes[tfm.computedType] {
+			return nil, fmt.Errorf("%s: oneof may only be used with strings, bools, numbers and durations", tfm.fieldName)
+		}
+		if tfm.minStr != "" || tfm.maxStr != "" {
+			if !numericTypes[tfm.computedType] {
+				return nil, fmt.Errorf("%s: min and max may only be used with numbers and durations", tfm.fieldName)
+			}
+			var err error
+			if tfm.minStr != "" {
+				if tfm.min, err = d.handleIntrinsicType([]byte(tfm.minStr), topLoc.ttype, tfm.computedType, tfm.params); err != nil {
+					return nil, fmt.Errorf("%s: invalid min: %s", tfm.fieldName, err)
+				}
+			}
+			if tfm.maxStr != "" {
+				if tfm.max, err = d.handleIntrinsicType([]byte(tfm.maxStr), topLoc.ttype, tfm.computedType, tfm.params); err != nil {
+					return nil, fmt.Errorf("%s: invalid max: %s", tfm.fieldName, err)
+				}
+			}
+		}
+	}
+
+	for _, i := range promote {
+		// Squashed structs may be pointed to.
+		loc := tFieldLocator{ind: i, ttype: st.Field(i).Type}
+		if loc.ttype.Kind() == reflect.Ptr {
+			loc.ptrCt, loc.ttype = 1, loc.ttype.Elem()
+		}
+		embedded, _, err := d.typeCache().tMeta(d, loc.ttype, false)
+		if err != nil {
+			return nil, err
+		}
+		for k, etfm := range embedded.tFieldsMetaMap {
+			if _, ok := tm.tFieldsMetaMap[k]; ok {
+				continue
+			}
+			etfmcp := &tFieldMeta{}
+			*etfmcp = *etfm
+			etfmcp.locators = append([]tFieldLocator{loc}, etfm.locators...)
+			tm.tFieldsMetaMap[k] = etfmcp
+		}
+		if embedded.remain != nil && tm.remain == nil {
+			remain := &tFieldMeta{}
+			*remain = *embedded.remain
+			remain.locators = append([]tFieldLocator{loc}, embedded.remain.locators...)
+			tm.remain = remain
+		}
+		tm.stamps = append(tm.stamps, nestedStamps(nil, []tFieldLocator{loc}, embedded.stamps)...)
+		tm.unknown = append(tm.unknown, embedded.unknown...)
+	}
+	// Fields promoted from embedded structs are copies, with locators of
+	// their own, so they are compiled here rather than in their structs.
+	for _, tfm := range tm.tFieldsMetaMap {
+		tfm.assign = compileAssign(tfm)
 	}
+	tm.flat = flatFields(tm)
 
 	return tm, nil
 }
 
+// scalarType returns the computed type for t, which must be of one of the
+// string, numeric or bool kinds.
+func scalarType(t reflect.Type) computedType {
+	switch t.Kind() {
+	case reflect.String:
+		if ct, ok := specialType(t); ok {
+			return ct
+		}
+		return typeString
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		if ct, ok := specialType(t); ok {
+			return ct
+		}
+		return typeInt
+	case reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8, reflect.Uintptr:
+		if ct, ok := specialType(t); ok {
+			return ct
+		}
+		return typeUint
+	case reflect.Float64, reflect.Float32:
+		return typeFloat
+	default:
+		return typeBool
+	}
+}
+
 // InvalidValueErr - this is returned if we don't pass an appropriate
 // type to Decode() or Unmarshal()
-var InvalidValueErr = errors.New("invalid value passed: must be a non-nil pointer to a struct")
+var InvalidValueErr = errors.New("invalid value passed: must be a non-nil pointer to a struct, map or slice")
+
+// ReservedNameErr - returned, wrapped with the name, by RegisterTransformer
+// and RegisterModifier when given the name of a built in modifier.
+var ReservedNameErr = errors.New("name is reserved by a built in modifier")
+
+// LimitExceededErr - this is returned, wrapped with the details, when one of
+// the MaxDepth, MaxKeys, MaxValueSize or MaxStringSize limits of a Decoder is
+// exceeded.
+var LimitExceededErr = errors.New("decoder limit exceeded")
+
+// InvalidUTF8Err - this is returned when a Decoder with RequireUTF8 set is
+// given a string which isn't valid UTF-8.
+var InvalidUTF8Err = errors.New("value is not valid UTF-8")
+
+// checkString returns an error if data breaks the MaxStringSize or
+// RequireUTF8 guards of the decoder.
+func (d *Decoder) checkString(data []byte) error {
+	if d.MaxStringSize > 0 && len(data) > d.MaxStringSize {
+		return fmt.Errorf("%w: string is %d bytes, maximum is %d", LimitExceededErr, len(data), d.MaxStringSize)
+	}
+	if d.RequireUTF8 && !utf8.Valid(data) {
+		return InvalidUTF8Err
+	}
+	return nil
+}
 
 // Unmarshal - uses the default decoder with default settings to decode
-// the values from kvps at pathPrefix into v.
+// the values from kvps at pathPrefix into v.  An empty pathPrefix is the root
+// of the tree, so that every key is decoded, where it used to match none.
 func Unmarshal(pathPrefix string, kvps api.KVPairs, v interface{}) error {
 	return defaultDecoder.Unmarshal(pathPrefix, kvps, v)
 }
@@ -407,28 +970,183 @@ func Unmarshal(pathPrefix string, kvps api.KVPairs, v interface{}) error {
 // Unmarshal - this is the Unmarshal method on a custom decoder.  Same as above
 // otherwise.
 func (d *Decoder) Unmarshal(pathPrefix string, kvps api.KVPairs, v interface{}) error {
-	valp := reflect.ValueOf(v)
-	if valp.Kind() != reflect.Ptr {
-		return InvalidValueErr
+	_, err := d.UnmarshalReport(pathPrefix, kvps, v)
+	return err
+}
+
+// UnmarshalReport - uses the default decoder with default settings to decode
+// the values from kvps at pathPrefix into v, returning a Report of the decode.
+func UnmarshalReport(pathPrefix string, kvps api.KVPairs, v interface{}) (*Report, error) {
+	return defaultDecoder.UnmarshalReport(pathPrefix, kvps, v)
+}
+
+// UnmarshalReport - same as Unmarshal, but also returns a Report describing
+// the decode.  The report is returned even if there is an error.
+func (d *Decoder) UnmarshalReport(pathPrefix string, kvps api.KVPairs, v interface{}) (*Report, error) {
+	fn := d.chain()
+	if fn == nil {
+		return d.unmarshalReport(pathPrefix, kvps, v)
 	}
-	if valp.IsNil() {
-		return InvalidValueErr
+	report, err := fn(pathPrefix, kvps, v)
+	if report == nil {
+		report = &Report{}
 	}
+	return report, err
+}
 
-	val := valp.Elem()
-	if val.Kind() != reflect.Struct {
-		return InvalidValueErr
+// unmarshalReport is UnmarshalReport, without the decoder's middleware.
+func (d *Decoder) unmarshalReport(pathPrefix string, kvps api.KVPairs, v interface{}) (*Report, error) {
+	start := time.Now()
+	state := &decodeState{start: start}
+	err := d.safeUnmarshal(state, pathPrefix, kvps, v)
+	if d.Metrics != nil {
+		d.Metrics.Observe(state.report.Stats, time.Since(start), err)
+	}
+	return &state.report, err
+}
+
+// decodeState holds anything that needs to be tracked across a single
+// call to Unmarshal, including the nested calls made for structs inside
+// of maps and slices.
+type decodeState struct {
+	report Report
+
+	// depth is the number of nested unmarshal calls we're inside of.
+	depth int
+
+	// touched holds the maps and slices seen so far, for MergeMode.
+	touched map[touchedKey]bool
+
+	// start is when the decode started, for decodedat fields.
+	start time.Time
+
+	// secrets is the number of nested unmarshal calls we're inside of which
+	// are for the elements of fields with the secret modifier.
+	secrets int
+
+	// warned holds the types whose unknown modifiers have been warned about.
+	warned map[*tMeta]bool
+
+	// indexed holds the slices set by index, for SliceIndexStrict.
+	indexed map[touchedKey]*indexedSlice
+
+	// tlsParts holds the part of each split tls.Certificate set so far.
+	tlsParts map[*tls.Certificate]string
+}
+
+// tlsPaired records that part of cert is being set, and returns whether
+// its other part was set earlier in the decode.
+func (state *decodeState) tlsPaired(cert *tls.Certificate, part string) bool {
+	if prev, ok := state.tlsParts[cert]; ok {
+		return prev != part
+	}
+	if state.tlsParts == nil {
+		state.tlsParts = make(map[*tls.Certificate]string)
+	}
+	state.tlsParts[cert] = part
+	return false
+}
+
+// countKey records that pair, found at relative key k under the prefix
+// passed to Unmarshal, is being processed, and enforces the decoder's limits.
+func (d *Decoder) countKey(state *decodeState, k string, pair *api.KVPair) error {
+	state.report.Stats.Keys++
+	if d.MaxKeys > 0 && state.report.Stats.Keys > d.MaxKeys {
+		return fmt.Errorf("%w: more than %d keys", LimitExceededErr, d.MaxKeys)
+	}
+	if d.MaxDepth > 0 && strings.Count(k, "/")+1 > d.MaxDepth {
+		return fmt.Errorf("%w: key %s is nested more than %d levels deep", LimitExceededErr, pair.Key, d.MaxDepth)
+	}
+	return d.checkValueSize(pair, pair.Value)
+}
+
+// checkValueSize enforces the MaxValueSize of the decoder on value, which is
+// the value of pair, as it is or as decoded so far.
+func (d *Decoder) checkValueSize(pair *api.KVPair, value []byte) error {
+	if d.MaxValueSize > 0 && len(value) > d.MaxValueSize {
+		return fmt.Errorf("%w: value of %s is %d bytes, maximum is %d", LimitExceededErr, pair.Key, len(value), d.MaxValueSize)
+	}
+	return nil
+}
+
+// parseError records that the value of pair could not be converted.  The
+// error is returned for the decode to fail with, unless the decoder is
+// lenient, in which case it is added to the report and nil is returned.
+func (d *Decoder) parseError(state *decodeState, pair *api.KVPair, err error) error {
+	state.report.Stats.ParseErrors++
+	if d.Lenient {
+		state.report.warn(pair.Key, "skipped: %s", err)
+		return nil
+	}
+	return err
+}
+
+// safeUnmarshal calls unmarshal, then checks the indices of slices, returning
+// a *PanicError should either panic.
+func (d *Decoder) safeUnmarshal(state *decodeState, pathPrefix string, kvps api.KVPairs, v interface{}) (err error) {
+	defer recoverPanic(v, &err)
+	if err = d.unmarshal(state, pathPrefix, kvps, v); err != nil {
+		return err
 	}
+	return d.checkIndices(state)
+}
 
-	meta, err := typeCache.tMeta(d, val.Type(), true)
+func (d *Decoder) unmarshal(state *decodeState, pathPrefix string, kvps api.KVPairs, v interface{}) error {
+	val, err := structValue(v)
 	if err != nil {
+		if target, ok := collectionValue(v); ok && state.depth == 0 {
+			return d.unmarshalCollection(state, pathPrefix, kvps, target)
+		}
 		return err
 	}
 
-	if !strings.HasSuffix(pathPrefix, "/") {
+	meta, hit, err := d.typeCache().tMeta(d, val.Type(), true)
+	if err != nil {
+		return err
+	}
+	if hit {
+		state.report.Stats.CacheHits++
+	} else {
+		state.report.Stats.CacheMisses++
+	}
+	if err := d.checkModifiers(state, meta); err != nil {
+		return err
+	}
+
+	return d.decodePairs(state, pathPrefix, kvps, val, meta)
+}
+
+// decodePairs decodes kvps into val, a struct described by meta.
+func (d *Decoder) decodePairs(state *decodeState, pathPrefix string, kvps api.KVPairs, val reflect.Value, meta *tMeta) error {
+	var err error
+	if state.depth == 0 {
+		pathPrefix, kvps = d.separatedPaths(pathPrefix, kvps)
+	}
+	if pathPrefix != "" && !strings.HasSuffix(pathPrefix, "/") {
 		pathPrefix += "/"
 	}
 
+	if state.depth == 0 {
+		kvps = d.resolveQualified(pathPrefix, kvps)
+		kvps, err = d.filterPairs(pathPrefix, kvps)
+		if err != nil {
+			return err
+		}
+		kvps = d.sessionPairs(state, pathPrefix, kvps)
+		kvps = sortPairs(kvps)
+		if err = d.checkCollisions(state, pathPrefix, kvps); err != nil {
+			return err
+		}
+		kvps, err = d.resolveConflicts(state, pathPrefix, kvps)
+		if err != nil {
+			return err
+		}
+	}
+	if len(meta.stamps) > 0 {
+		d.setStamps(state, pathPrefix, kvps, val, meta.stamps)
+	}
+	flat := d.flatTable(meta)
+
 	for {
 		if len(kvps) == 0 {
 			break
@@ -449,12 +1167,36 @@ func (d *Decoder) Unmarshal(pathPrefix string, kvps api.KVPairs, v interface{})
 
 		k := strings.TrimPrefix(key, pathPrefix)
 		if pathPrefix != "" && k == key {
-			continue // doesn't match what we're supposed to.  perhaps error?
+			// doesn't match what we're supposed to.  This can only happen
+			// with the pairs passed to Unmarshal, as those of nested structs
+			// are picked by prefix.
+			switch {
+			case state.depth > 0 || d.OutsidePrefix == IssueIgnore:
+			case d.OutsidePrefix == IssueError:
+				return fmt.Errorf("key %s is outside of prefix %s", kvp.Key, pathPrefix)
+			default:
+				state.report.warn(kvp.Key, "skipped, as it is outside of prefix %s", pathPrefix)
+			}
+			continue
+		}
+		if state.depth == 0 {
+			if err = d.countKey(state, k, kvp); err != nil {
+				return err
+			}
+		}
+
+		if flat != nil {
+			if ff := findFlat(flat, k); ff != nil {
+				if err = d.assignFlat(state, ff, kvp, val); err != nil {
+					return err
+				}
+				continue
+			}
 		}
 
 		for {
 			if tfm, ok := meta.tFieldsMetaMap[k]; ok {
-				err = d.allocAssign(tfm, kvp, &kvps, val, pathPrefix)
+				err = d.assignPair(state, tfm, k, kvp, &kvps, val, pathPrefix)
 				if err != nil {
 					return err
 				}
@@ -462,9 +1204,20 @@ func (d *Decoder) Unmarshal(pathPrefix string, kvps api.KVPairs, v interface{})
 			}
 
 			// Look for maps and slices
+			if k == "" {
+				if meta.remain != nil {
+					rest, _ := d.cutPrefix(kvp.Key, pathPrefix)
+					if err = d.assignRemain(state, meta.remain, rest, kvp, val); err != nil {
+						return err
+					}
+				}
+				break
+			}
 			k = path.Dir(k)
 			if k == "." || k == "/" {
-				break
+				// The map or slice passed to Unmarshal in place of a
+				// struct is found at the prefix itself.
+				k = ""
 			}
 		}
 	}
@@ -472,6 +1225,44 @@ func (d *Decoder) Unmarshal(pathPrefix string, kvps api.KVPairs, v interface{})
 	return nil
 }
 
+// structValue returns the struct v points to, or InvalidValueErr if v is
+// not a non-nil pointer to a struct.
+func structValue(v interface{}) (reflect.Value, error) {
+	valp := reflect.ValueOf(v)
+	if valp.Kind() != reflect.Ptr {
+		return reflect.Value{}, InvalidValueErr
+	}
+	if valp.IsNil() {
+		return reflect.Value{}, InvalidValueErr
+	}
+
+	val := valp.Elem()
+	if val.Kind() != reflect.Struct {
+		return reflect.Value{}, InvalidValueErr
+	}
+	return val, nil
+}
+
+// lookupField follows the locators of tfm from val without allocating
+// anything, returning false if a nil pointer is encountered on the way.
+func lookupField(tfm *tFieldMeta, val reflect.Value) (reflect.Value, bool) {
+	tval := val
+	for _, loc := range tfm.locators {
+		fv := tval.Field(loc.ind)
+		if loc.isSlice || loc.isMap || loc.isEncoded {
+			return fv, true
+		}
+		for i := uint8(0); i < loc.ptrCt; i++ {
+			if fv.IsNil() {
+				return fv, false
+			}
+			fv = fv.Elem()
+		}
+		tval = fv
+	}
+	return tval, true
+}
+
 func isByteSlice(t reflect.Type) bool {
 	k := t.Kind()
 	if k != reflect.Slice {
@@ -481,18 +1272,150 @@ func isByteSlice(t reflect.Type) bool {
 	return t.Kind() == reflect.Uint8
 }
 
-func (d *Decoder) allocAssign(tfm *tFieldMeta, thisPair *api.KVPair, rest *api.KVPairs, val reflect.Value, prefix string) error {
+// pairValue returns the value of pair, decoded according to any flag hints
+// if the decoder is configured to honor them, then passed through the
+// transformers and trimming of the field, and resolved if it refers to a
+// service.
+func (d *Decoder) pairValue(tfm *tFieldMeta, pair *api.KVPair) ([]byte, error) {
+	value := pair.Value
+	if d.UseFlags && pair.Flags&FlagBase64 != 0 {
+		b, err := decodeBase64(value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to base64 decode %s: %s", pair.Key, err)
+		}
+		value = b
+	}
+	if d.UseFlags && pair.Flags&FlagGzip != 0 {
+		b, err := d.gunzip(value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to gunzip %s: %w", pair.Key, err)
+		}
+		value = b
+	}
+	for _, nt := range tfm.transforms {
+		fn := nt.fn
+		switch {
+		case fn != nil:
+		case nt.name == tagEncrypted:
+			fn = d.decrypt
+		default:
+			fn = d.gunzip
+		}
+		b, err := fn(value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to apply %s to %s: %w", nt.name, pair.Key, err)
+		}
+		if err = d.checkValueSize(pair, b); err != nil {
+			return nil, err
+		}
+		value = b
+	}
+	if d.Templates {
+		b, err := expandTemplate(value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to expand %s: %w", pair.Key, err)
+		}
+		if err = d.checkValueSize(pair, b); err != nil {
+			return nil, err
+		}
+		value = b
+	}
+	if d.TrimSpace || tfm.trim {
+		value = bytes.TrimSpace(value)
+	}
+	if (tfm.service && len(value) > 0) || (d.ServiceResolver != nil && !tfm.nestsStruct() && isServiceURL(value)) {
+		return d.resolveService(pair.Key, value)
+	}
+	return value, nil
+}
+
+// hintJSON returns true if the pair has been flagged as containing json.
+func (d *Decoder) hintJSON(pair *api.KVPair) bool {
+	return d.UseFlags && pair.Flags&FlagJSON != 0
+}
+
+// isFieldKey returns true if key refers to the field itself, rather than
+// something nested inside of it.
+func (d *Decoder) isFieldKey(tfm *tFieldMeta, key, prefix string) bool {
+	if !d.CaseSensitive {
+		key = strings.ToLower(key)
+		prefix = strings.ToLower(prefix)
+	}
+	return strings.TrimPrefix(key, prefix) == tfm.fieldName
+}
+
+func (d *Decoder) allocAssign(state *decodeState, tfm *tFieldMeta, thisPair *api.KVPair, rest *api.KVPairs, val reflect.Value, prefix string) error {
 	tval := val
 
+	if tfm.computedType == typePair {
+		d.capturePair(state, tfm, thisPair, val)
+		return nil
+	}
+
+	value, err := d.pairValue(tfm, thisPair)
+	if err != nil {
+		return d.parseError(state, thisPair, err)
+	}
+
+	// Empty values for structs in maps and slices are left to the nested
+	// decode to deal with.
+	zero := false
+	if len(value) == 0 && d.EmptyValues != EmptyDefault && !tfm.nestsStruct() {
+		switch d.EmptyValues {
+		case EmptyAsMissing:
+			return nil
+		case EmptyError:
+			return d.parseError(state, thisPair, errors.New("empty value"))
+		case EmptyAsZero:
+			zero = true
+		}
+	}
+
+	if tfm.assign != nil && !zero && !d.hintJSON(thisPair) {
+		fv, err := tfm.assign(d, val, value)
+		if err != nil {
+			return d.parseError(state, thisPair, err)
+		}
+		d.assigned(state, tfm, thisPair, val, fv)
+		return nil
+	}
+
 	for _, loc := range tfm.locators {
-		tk := typeKey(loc.ttype)
-		_ = tk
 		fv := tval.Field(loc.ind)
-		if loc.isSlice || loc.isMap || loc.isJSON {
+		if (loc.isSlice || loc.isMap) && d.hintJSON(thisPair) && d.isFieldKey(tfm, thisPair.Key, prefix) {
+			// The whole collection is flagged as json, rather than one of
+			// its elements.  json.Unmarshal takes care of allocating
+			// any pointers.
+			if fv.Kind() == reflect.Map || fv.Kind() == reflect.Slice {
+				if err := d.mergeCollection(state, tfm, fv); err != nil {
+					return err
+				}
+			}
+			if err := json.Unmarshal(value, fv.Addr().Interface()); err != nil {
+				return d.parseError(state, thisPair, err)
+			}
+			d.assigned(state, tfm, thisPair, val, fv)
+			return nil
+		}
+		if loc.isSlice || loc.isMap || loc.isEncoded {
+			var mapKey string
+			if loc.isMap {
+				var skip bool
+				var err error
+				if mapKey, skip, err = d.mapKey(state, tfm, thisPair, prefix); err != nil || skip {
+					return err
+				}
+			}
 			var st reflect.Value // st will hold a reference to loc.ttype
-			if tfm.computedType == typeStruct || tfm.isSpecial() {
+			nested := false
+			if tfm.computedType == typeStruct || tfm.isSpecial() || loc.isEncoded {
 
 				st = reflect.New(loc.ttype)
+				if d.ReuseExisting && !zero && tfm.isNotSpecial() {
+					if existing := existingElem(loc, fv, mapKey); existing.IsValid() {
+						st = existing
+					}
+				}
 				newprefix := prefix
 				if loc.isSlice || loc.isMap {
 					newprefix = path.Join(prefix, tfm.fieldName) + "/"
@@ -505,10 +1428,16 @@ func (d *Decoder) allocAssign(tfm *tFieldMeta, thisPair *api.KVPair, rest *api.K
 				ind := strings.TrimPrefix(key, newprefix)
 				pathparts := strings.Split(ind, "/")
 				newprefix = path.Join(newprefix, pathparts[0]) + "/"
-				if loc.isJSON {
-					err := json.Unmarshal(thisPair.Value, st.Interface())
+				if zero {
+					// st is already the zero value.
+				} else if loc.isEncoded || (d.hintJSON(thisPair) && pathparts[0] == ind) {
+					unmarshal := json.Unmarshal
+					if loc.isEncoded {
+						unmarshal = tfm.unmarshal
+					}
+					err := unmarshal(value, st.Interface())
 					if err != nil {
-						return err
+						return d.parseError(state, thisPair, err)
 					}
 				} else if tfm.isCSV() || tfm.isSSV() {
 					t := loc.ttype
@@ -518,38 +1447,50 @@ func (d *Decoder) allocAssign(tfm *tFieldMeta, thisPair *api.KVPair, rest *api.K
 					st = reflect.New(reflect.SliceOf(t))
 				} else {
 					// Process all the pairs related to this prefix.
-					curatedPairs := api.KVPairs{thisPair}
-					for i := 0; i < len(*rest); i++ {
-						key := (*rest)[0].Key
-						if !d.CaseSensitive {
-							key = strings.ToLower(key)
-							newprefix = strings.ToLower(newprefix)
-						}
-						if strings.HasPrefix(key, newprefix) {
-							curatedPairs = append(curatedPairs, (*rest)[0])
-							*rest = (*rest)[1:]
-						} else {
-							break
-						}
+					buf := d.getPairs()
+					curatedPairs, err := d.curatePairs(state, prefix, newprefix, thisPair, rest, buf.slice())
+					if err != nil {
+						return err
+					}
+					nested = true
+					state.depth++
+					if tfm.secret {
+						state.secrets++
 					}
-					err := d.Unmarshal(newprefix, curatedPairs, st.Interface())
+					err = d.unmarshal(state, newprefix, curatedPairs, st.Interface())
+					d.putPairs(buf, curatedPairs)
+					if tfm.secret {
+						state.secrets--
+					}
+					state.depth--
 					if err != nil {
 						return err
 					}
 				}
 
+			} else if zero {
+				st = reflect.New(loc.ttype)
+			} else if d.hintJSON(thisPair) {
+				st = reflect.New(loc.ttype)
+				err := json.Unmarshal(value, st.Interface())
+				if err != nil {
+					return d.parseError(state, thisPair, err)
+				}
 			} else {
 				var err error
-				st, err = handleIntrinsicType(thisPair.Value, loc.ttype, tfm.computedType)
+				st, err = d.handleIntrinsicType(value, loc.ttype, tfm.computedType, tfm.params)
+				if err == nil {
+					err = d.checkValue(tfm, st)
+				}
 				if err != nil {
-					return err
+					return d.parseError(state, thisPair, err)
 				}
 				st = st.Addr()
 			}
 
 			// once here, st represents a pointer to a loc.ttype
 
-			if loc.collPtrCt == 0 && !loc.isJSON && tfm.isNotSpecial() {
+			if loc.collPtrCt == 0 && !loc.isEncoded && tfm.isNotSpecial() {
 				// st is a pointer to stype, so we need to deref it.
 				st = st.Elem()
 			} else {
@@ -559,13 +1500,13 @@ func (d *Decoder) allocAssign(tfm *tFieldMeta, thisPair *api.KVPair, rest *api.K
 				for i := uint8(1); i < loc.collPtrCt; i++ {
 					// st starts out a pointer, so st.Type() is *Type
 					nst := reflect.New(st.Type())
-					nst.Set(st.Addr())
+					nst.Elem().Set(st)
 					st = nst
 				}
 			}
 
 			sfield := fv
-			if loc.isJSON {
+			if loc.isEncoded {
 				if loc.ptrCt == 0 {
 					st = st.Elem()
 				}
@@ -573,10 +1514,11 @@ func (d *Decoder) allocAssign(tfm *tFieldMeta, thisPair *api.KVPair, rest *api.K
 				// if ptrCt > 1, process those.
 				for i := uint8(1); i < loc.ptrCt; i++ {
 					nst := reflect.New(st.Type())
-					nst.Set(st.Addr())
+					nst.Elem().Set(st)
 					st = nst
 				}
 				sfield.Set(st)
+				d.assigned(state, tfm, thisPair, val, st)
 				return nil
 			}
 
@@ -592,63 +1534,68 @@ func (d *Decoder) allocAssign(tfm *tFieldMeta, thisPair *api.KVPair, rest *api.K
 				}
 				sfield = sfield.Elem()
 			}
-			if loc.isMap {
-				if sfield.IsNil() {
-					sfield.Set(reflect.MakeMap(sfield.Type()))
-				}
-				trimpath := path.Join(prefix, tfm.fieldName) + "/"
-				key := thisPair.Key
-				if !d.CaseSensitive {
-					key = strings.ToLower(key)
-					trimpath = strings.ToLower(trimpath)
-				}
-
-				key = strings.TrimPrefix(key, trimpath)
-
-				splitKey := strings.Split(key, "/")
-
-				sfield.SetMapIndex(reflect.ValueOf(splitKey[0]), st)
-			} else { // slice
-				handleFields := func(fields []string, loc tFieldLocator, tfm *tFieldMeta) ([]reflect.Value, error) {
-					var vals []reflect.Value
-					for _, field := range fields {
-						v, err := handleIntrinsicType([]byte(field), loc.ttype, tfm.computedType)
-						if err != nil {
-							return nil, err
-						}
-						for i := uint8(0); i < loc.collPtrCt; i++ {
-							vp := reflect.New(v.Type())
-							vp.Elem().Set(v)
-							v = vp
-						}
-						vals = append(vals, v)
+			var vals []reflect.Value
+			if tfm.isSpecial() && !zero {
+				var fields []string
+				if tfm.isCSV() {
+					var err error
+					r := csv.NewReader(bytes.NewReader(value))
+					if sep, ok := tfm.params[paramSep]; ok {
+						r.Comma, _ = utf8.DecodeRuneInString(sep)
 					}
-					return vals, nil
-				}
-				var (
-					vals []reflect.Value
-				)
-				switch tfm.special {
-				case sCSV:
-					fields, err := csv.NewReader(bytes.NewReader(thisPair.Value)).Read()
+					fields, err = r.Read()
 					if err != nil {
-						return err
+						return d.parseError(state, thisPair, err)
 					}
-					vals, err = handleFields(fields, loc, tfm)
-					if err != nil {
-						return err
+				} else {
+					fields = strings.Fields(string(value))
+				}
+				for _, field := range fields {
+					v, err := d.handleIntrinsicType([]byte(field), loc.ttype, tfm.computedType, tfm.params)
+					if err == nil {
+						err = d.checkValue(tfm, v)
 					}
-				case sSSV:
-					fields := strings.Fields(string(thisPair.Value))
-					var err error
-					vals, err = handleFields(fields, loc, tfm)
 					if err != nil {
-						return err
+						return d.parseError(state, thisPair, err)
+					}
+					for i := uint8(0); i < loc.collPtrCt; i++ {
+						vp := reflect.New(v.Type())
+						vp.Elem().Set(v)
+						v = vp
 					}
-				default:
-					vals = []reflect.Value{st}
+					vals = append(vals, v)
 				}
+			}
+			if err := d.mergeCollection(state, tfm, sfield); err != nil {
+				return err
+			}
+			if loc.isMap {
+				if sfield.IsNil() {
+					sfield.Set(reflect.MakeMap(sfield.Type()))
+				}
+				if loc.isSlice {
+					// A map of slices, with each value of the map read from
+					// a single csv or ssv value.
+					st = reflect.MakeSlice(sfield.Type().Elem(), 0, len(vals))
+					st = reflect.Append(st, vals...)
+				}
+				// Convert the key, as the map may be keyed by a defined
+				// string type.
+				sfield.SetMapIndex(reflect.ValueOf(mapKey).Convert(sfield.Type().Key()), st)
+			} else if tfm.isSpecial() {
 				sfield.Set(reflect.Append(sfield, vals...))
+			} else if d.SliceIndex != SliceIndexOrder {
+				if err := d.setIndex(state, tfm, thisPair, prefix, sfield, st); err != nil {
+					return err
+				}
+			} else {
+				sfield.Set(reflect.Append(sfield, st))
+			}
+			if !nested {
+				if !loc.isMap && tfm.isSpecial() {
+					st = sfield
+				}
+				d.assigned(state, tfm, thisPair, val, st)
 			}
 			return nil
 		}
@@ -665,66 +1612,236 @@ func (d *Decoder) allocAssign(tfm *tFieldMeta, thisPair *api.KVPair, rest *api.K
 		tval = fv
 	}
 
-	if tfm.computedType == typeTextUnmarshaler {
+	if tfm.computedType == typeAtomic {
+		err = d.storeAtomic(tfm, tval, value, zero)
+	} else if zero {
+		tval.Set(reflect.Zero(tval.Type()))
+	} else if d.hintJSON(thisPair) {
+		err = json.Unmarshal(value, tval.Addr().Interface())
+	} else if tfm.computedType == typeTextUnmarshaler {
 		tu := tval.Addr().Interface().(encoding.TextUnmarshaler)
-		return tu.UnmarshalText(thisPair.Value)
+		err = tu.UnmarshalText(value)
+	} else if tfm.computedType == typeTLSCertificate {
+		cert := tval.Addr().Interface().(*tls.Certificate)
+		err = setTLSCertificate(cert, tfm.tlsPart, value, state.tlsPaired(cert, tfm.tlsPart))
+	} else {
+		var v reflect.Value
+		v, err = d.handleIntrinsicType(value, tval.Type(), tfm.computedType, tfm.params)
+		if err == nil {
+			err = d.checkValue(tfm, v)
+		}
+		if err == nil {
+			tval.Set(v)
+		}
 	}
-
-	v, err := handleIntrinsicType(thisPair.Value, tval.Type(), tfm.computedType)
 	if err != nil {
-		return err
+		return d.parseError(state, thisPair, err)
 	}
-	tval.Set(v)
+	d.assigned(state, tfm, thisPair, val, tval)
 
 	return nil
 }
 
-func handleIntrinsicType(data []byte, ttype reflect.Type, cType computedType) (reflect.Value, error) {
+// sortPairs returns kvps sorted by key, which is the order consul returns
+// them in, copying them first if they aren't already sorted.  This makes
+// the order of the elements of slices independent of the order of the
+// input.
+func sortPairs(kvps api.KVPairs) api.KVPairs {
+	less := func(i, j int) bool { return kvps[i].Key < kvps[j].Key }
+	if sort.SliceIsSorted(kvps, less) {
+		return kvps
+	}
+	kvps = append(api.KVPairs(nil), kvps...)
+	sort.SliceStable(kvps, less)
+	return kvps
+}
+
+// curatePairs removes the pairs under newprefix, the folder of a single
+// element of a map or slice, from rest, and returns them following
+// thisPair.  The pairs of an element needn't be contiguous, so that input
+// which hasn't come straight from consul, and so isn't sorted, is decoded
+// correctly.  No allocation is needed for sorted input, where they are.  The
+// pairs are appended to buf, which may come from the pool.
+func (d *Decoder) curatePairs(state *decodeState, prefix, newprefix string, thisPair *api.KVPair, rest *api.KVPairs, buf api.KVPairs) (api.KVPairs, error) {
+	curatedPairs := append(buf, thisPair)
+	take := func(pair *api.KVPair) (bool, error) {
+		// cutPrefix ignores case without lowering the keys, which would
+		// allocate for each key with upper case in it.
+		if _, ok := d.cutPrefix(pair.Key, newprefix); !ok {
+			return false, nil
+		}
+		curatedPairs = append(curatedPairs, pair)
+		if state.depth == 0 {
+			k, _ := d.cutPrefix(pair.Key, prefix)
+			return true, d.countKey(state, k, pair)
+		}
+		return true, nil
+	}
+
+	pairs := *rest
+	i := 0
+	for ; i < len(pairs); i++ {
+		if ok, err := take(pairs[i]); err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+	}
+	// Look for any stragglers.
+	var kept api.KVPairs
+	for j := i; j < len(pairs); j++ {
+		ok, err := take(pairs[j])
+		if err != nil {
+			return nil, err
+		}
+		if ok && kept == nil {
+			kept = append(make(api.KVPairs, 0, len(pairs)-i), pairs[i:j]...)
+		} else if !ok && kept != nil {
+			kept = append(kept, pairs[j])
+		}
+	}
+	if kept != nil {
+		*rest = kept
+	} else {
+		*rest = pairs[i:]
+	}
+	return curatedPairs, nil
+}
+
+// checkValue returns an error if v isn't one of the values permitted by
+// the oneof modifier on the field, or is out of the range given by the min
+// and max modifiers.
+func (d *Decoder) checkValue(tfm *tFieldMeta, v reflect.Value) error {
+	if tfm.min.IsValid() && compareValues(v, tfm.min) < 0 {
+		return fmt.Errorf("invalid value %v, must be at least %v", v, tfm.min)
+	}
+	if tfm.max.IsValid() && compareValues(v, tfm.max) > 0 {
+		return fmt.Errorf("invalid value %v, must be at most %v", v, tfm.max)
+	}
+	if tfm.oneof == nil {
+		return nil
+	}
+	for _, opt := range tfm.oneof {
+		ov, err := d.handleIntrinsicType([]byte(opt), v.Type(), tfm.computedType, tfm.params)
+		if err == nil && ov.Interface() == v.Interface() {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value %v, must be one of: %s", v, strings.Join(tfm.oneof, ", "))
+}
+
+// compareValues compares two numeric values of the same kind, returning
+// -1, 0 or 1.
+func compareValues(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		if a.Int() < b.Int() {
+			return -1
+		} else if a.Int() > b.Int() {
+			return 1
+		}
+	case reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
+		if a.Uint() < b.Uint() {
+			return -1
+		} else if a.Uint() > b.Uint() {
+			return 1
+		}
+	case reflect.Float64, reflect.Float32:
+		if a.Float() < b.Float() {
+			return -1
+		} else if a.Float() > b.Float() {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (d *Decoder) handleIntrinsicType(data []byte, ttype reflect.Type, cType computedType, params map[string]string) (reflect.Value, error) {
 	tval := reflect.New(ttype).Elem()
+	if conv, ok := converters[cType]; ok {
+		return tval, conv(d, data, tval, params)
+	}
 	switch cType {
-	case typeInt:
-		ival, err := strconv.ParseInt(string(data), 10, 64)
-		if err != nil {
+	case typeTextUnmarshaler:
+		tu := tval.Addr().Interface().(encoding.TextUnmarshaler)
+		if err := tu.UnmarshalText(data); err != nil {
+			return tval, err
+		}
+	case typeInterface:
+		if err := d.checkString(data); err != nil {
 			return tval, err
 		}
-		tval.SetInt(ival)
-	case typeUint:
-		uival, err := strconv.ParseUint(string(data), 10, 64)
+		if d.InferTypes {
+			tval.Set(reflect.ValueOf(inferValue(data)))
+		} else {
+			tval.Set(reflect.ValueOf(string(data)))
+		}
+	case typeByteSlice:
+		tval.SetBytes(data)
+	case typeNetIP, typeNetMask:
+		if len(data) == 0 {
+			break
+		}
+		ipval := net.ParseIP(string(data))
+		if ipval == nil {
+			return tval, fmt.Errorf("invalid address: %s", string(data))
+		}
+		tval.SetBytes([]byte(ipval))
+	case typeCertificate:
+		if len(data) == 0 {
+			break
+		}
+		certs, err := parseCertificates(data)
 		if err != nil {
 			return tval, err
 		}
-		tval.SetUint(uival)
-	case typeFloat:
-		fval, err := strconv.ParseFloat(string(data), 64)
+		tval.Set(reflect.ValueOf(certs[0]).Elem())
+	case typeCertChain:
+		if len(data) == 0 {
+			break
+		}
+		if err := setCertChain(tval, data); err != nil {
+			return tval, err
+		}
+	case typeHardwareAddr:
+		if len(data) == 0 {
+			break
+		}
+		mac, err := net.ParseMAC(string(data))
 		if err != nil {
 			return tval, err
 		}
-		tval.SetFloat(fval)
-	case typeString:
-		tval.SetString(string(data))
-	case typeByteSlice:
-		tval.SetBytes(data)
-	case typeBool:
-		bval, err := strconv.ParseBool(string(data))
+		tval.SetBytes(mac)
+	case typeTCPAddr:
+		if len(data) == 0 {
+			break
+		}
+		addr, err := net.ResolveTCPAddr("tcp", string(data))
 		if err != nil {
 			return tval, err
 		}
-		tval.SetBool(bval)
-	case typeDuration:
-		dval, err := time.ParseDuration(string(data))
+		tval.Set(reflect.ValueOf(*addr))
+	case typeUDPAddr:
+		if len(data) == 0 {
+			break
+		}
+		addr, err := net.ResolveUDPAddr("udp", string(data))
 		if err != nil {
 			return tval, err
 		}
-		tval.SetInt(int64(dval))
-	case typeNetIP, typeNetMask:
+		tval.Set(reflect.ValueOf(*addr))
+	case typePublicKey:
 		if len(data) == 0 {
 			break
 		}
-		ipval := net.ParseIP(string(data))
-		if ipval == nil {
-			return tval, fmt.Errorf("invalid address: %s", string(data))
+		if err := setPublicKey(tval, data); err != nil {
+			return tval, err
+		}
+	case typeTLSCertificate:
+		err := setTLSCertificate(tval.Addr().Interface().(*tls.Certificate), "", data, false)
+		if err != nil {
+			return tval, err
 		}
-		tval.SetBytes([]byte(ipval))
 
 	default:
 		// TODO: mention this...
@@ -733,3 +1850,52 @@ func handleIntrinsicType(data []byte, ttype reflect.Type, cType computedType) (r
 
 	return tval, nil
 }
+
+// weakBool interprets s as a bool for WeaklyTypedInput, accepting the
+// likes of "yes" and "off", the empty string, and numbers.
+func weakBool(s string) (bool, bool) {
+	s = strings.TrimSpace(s)
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b, true
+	}
+	switch strings.ToLower(s) {
+	case "yes", "y", "on":
+		return true, true
+	case "no", "n", "off", "":
+		return false, true
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f != 0, true
+	}
+	return false, false
+}
+
+// commaFloat interprets s as a number written with a decimal comma, for
+// DecimalComma.
+func commaFloat(s string) (float64, bool) {
+	if strings.Count(s, ",") != 1 {
+		return 0, false
+	}
+	s = strings.NewReplacer(".", "", " ", "", "\u00a0", "", "\u202f", "", ",", ".").Replace(s)
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
+
+// weakFloat interprets s as a number for WeaklyTypedInput, accepting bools,
+// the empty string and surrounding space.
+func weakFloat(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, true
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, true
+	}
+	if b, err := strconv.ParseBool(s); err == nil {
+		if b {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
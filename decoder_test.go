@@ -2,8 +2,16 @@ package decoder
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
+	"math/rand"
 	"net"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
@@ -11,6 +19,7 @@ import (
 
 	consulapi "github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 const prefix = "testing"
@@ -409,3 +418,1240 @@ func TestUnmarshal(t *testing.T) {
 	server.Stop()
 
 }
+
+type tbFlagHints struct {
+	Encoded  string
+	Zipped   string
+	JSONMap  map[string]int
+	JSONList []*TestStruct
+	Plain    string
+}
+
+func TestUnmarshalFlagHints(t *testing.T) {
+	var zipped bytes.Buffer
+	zw := gzip.NewWriter(&zipped)
+	_, _ = zw.Write([]byte("unzipped"))
+	_ = zw.Close()
+
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/encoded", Value: []byte(base64.StdEncoding.EncodeToString([]byte("decoded"))), Flags: FlagBase64},
+		{Key: prefix + "/jsonlist/one", Value: []byte(`{"field1":"a","field2":"b"}`), Flags: FlagJSON},
+		{Key: prefix + "/jsonmap", Value: []byte(`{"one":1,"two":2}`), Flags: FlagJSON},
+		{Key: prefix + "/plain", Value: []byte("plain")},
+		{Key: prefix + "/zipped", Value: []byte(base64.StdEncoding.EncodeToString(zipped.Bytes())), Flags: FlagBase64 | FlagGzip},
+	}
+
+	t.Run("Honored", func(t *testing.T) {
+		cfg := &tbFlagHints{}
+		if err := (&Decoder{UseFlags: true}).Unmarshal(prefix, kvs, cfg); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		tests := []struct {
+			asserter assertThis
+			value    interface{}
+		}{
+			{&valueIs{"decoded"}, cfg.Encoded},
+			{&valueIs{"unzipped"}, cfg.Zipped},
+			{&valueIs{"plain"}, cfg.Plain},
+			{&valueIs{2}, cfg.JSONMap["two"]},
+			{&lenIs{1}, cfg.JSONList},
+			{&valueIs{"b"}, cfg.JSONList[0].Field2},
+		}
+		for _, test := range tests {
+			if err := test.asserter.Assert(t, test.value); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		kvs := consulapi.KVPairs{{Key: prefix + "/zipped", Value: []byte("notzipped"), Flags: FlagGzip}}
+		if err := (&Decoder{UseFlags: true}).Unmarshal(prefix, kvs, &tbFlagHints{}); err == nil {
+			t.Fatal("expected error decoding value flagged as gzip")
+		}
+	})
+
+	t.Run("Limited", func(t *testing.T) {
+		var bomb bytes.Buffer
+		zw := gzip.NewWriter(&bomb)
+		_, _ = zw.Write(bytes.Repeat([]byte("a"), 1000))
+		_ = zw.Close()
+		kvs := consulapi.KVPairs{{Key: prefix + "/zipped", Value: bomb.Bytes(), Flags: FlagGzip}}
+		err := (&Decoder{UseFlags: true, MaxValueSize: 100}).Unmarshal(prefix, kvs, &tbFlagHints{})
+		if !errors.Is(err, LimitExceededErr) {
+			t.Errorf("expected LimitExceededErr decompressing past MaxValueSize, got %v", err)
+		}
+	})
+
+	t.Run("Ignored", func(t *testing.T) {
+		kvs := consulapi.KVPairs{{Key: prefix + "/plain", Value: []byte("cGxhaW4="), Flags: FlagBase64}}
+		cfg := &tbFlagHints{}
+		if err := Unmarshal(prefix, kvs, cfg); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if cfg.Plain != "cGxhaW4=" {
+			t.Errorf("expected flags to be ignored, got %q", cfg.Plain)
+		}
+	})
+}
+
+type (
+	TestRecursive struct {
+		Name string
+		Next *TestRecursive
+	}
+
+	TestIndirectRecursive struct {
+		Name  string
+		Inner *TestIndirectRecursiveInner
+	}
+
+	TestIndirectRecursiveInner struct {
+		Outer TestIndirectRecursive
+	}
+
+	TestRecursiveCollections struct {
+		Name     string
+		Children map[string]*TestRecursiveCollections
+	}
+)
+
+func TestUnmarshalRecursiveTypes(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/children/one/name", Value: []byte("child")},
+		{Key: prefix + "/name", Value: []byte("parent")},
+	}
+
+	for _, v := range []interface{}{&TestRecursive{}, &TestIndirectRecursive{}} {
+		err := Unmarshal(prefix, kvs, v)
+		if err == nil || !strings.Contains(err.Error(), "recursive struct type") {
+			t.Errorf("expected recursive struct error for %T, got %v", v, err)
+		}
+	}
+
+	rc := &TestRecursiveCollections{}
+	if err := Unmarshal(prefix, kvs, rc); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if rc.Name != "parent" || rc.Children["one"] == nil || rc.Children["one"].Name != "child" {
+		t.Errorf("unexpected recursive collection result: %+v", rc)
+	}
+}
+
+func TestUnmarshalLimitsNested(t *testing.T) {
+	// The last pair of an element of a map of structs is the last pair
+	// passed in, and each pair is counted once.
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/structs/a/field1", Value: []byte("1")},
+		{Key: prefix + "/structs/a/field2", Value: []byte("12345678")},
+	}
+	type tbNested struct {
+		Structs map[string]*TestStruct
+	}
+
+	if err := (&Decoder{MaxKeys: 2}).Unmarshal(prefix, kvs, &tbNested{}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	err := (&Decoder{MaxValueSize: 4}).Unmarshal(prefix, kvs, &tbNested{})
+	if !errors.Is(err, LimitExceededErr) || !strings.Contains(err.Error(), "field2") {
+		t.Errorf("expected LimitExceededErr for field2, got %v", err)
+	}
+}
+
+func TestUnmarshalLimits(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/l1/int", Value: []byte("1")},
+		{Key: prefix + "/l1/level2/int", Value: []byte("2")},
+		{Key: prefix + "/l1/level2/level3/int", Value: []byte("3")},
+		{Key: prefix + "/l1/uint", Value: []byte("12345")},
+	}
+
+	tests := []struct {
+		name string
+		dec  *Decoder
+		fail bool
+	}{
+		{"NoLimits", &Decoder{}, false},
+		{"WithinLimits", &Decoder{MaxDepth: 4, MaxKeys: 4, MaxValueSize: 5}, false},
+		{"MaxDepth", &Decoder{MaxDepth: 3}, true},
+		{"MaxKeys", &Decoder{MaxKeys: 3}, true},
+		{"MaxValueSize", &Decoder{MaxValueSize: 4}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.dec.Unmarshal(prefix, kvs, &tbConfig{})
+			if test.fail && !errors.Is(err, LimitExceededErr) {
+				t.Errorf("expected LimitExceededErr, got %v", err)
+			} else if !test.fail && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+type tbStrings struct {
+	Name   string
+	Notes  []string `decoder:",csv"`
+	Labels map[string]string
+	Count  int
+}
+
+func TestUnmarshalStringGuards(t *testing.T) {
+	valid := consulapi.KVPairs{
+		{Key: prefix + "/count", Value: []byte("123456")},
+		{Key: prefix + "/labels/env", Value: []byte("prod")},
+		{Key: prefix + "/name", Value: []byte("héllo")},
+		{Key: prefix + "/notes", Value: []byte("a,b")},
+	}
+	dec := &Decoder{MaxStringSize: 6, RequireUTF8: true}
+	cfg := &tbStrings{}
+	if err := dec.Unmarshal(prefix, valid, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Name != "héllo" || cfg.Count != 123456 {
+		t.Errorf("unexpected values: %+v", cfg)
+	}
+
+	for _, test := range []struct {
+		key, value string
+		want       error
+	}{
+		{"name", "much too long", LimitExceededErr},
+		{"notes", "short,much too long", LimitExceededErr},
+		{"labels/env", "much too long", LimitExceededErr},
+		{"name", "\xff\xfe", InvalidUTF8Err},
+		{"labels/env", "\xc3", InvalidUTF8Err},
+	} {
+		kvs := consulapi.KVPairs{{Key: prefix + "/" + test.key, Value: []byte(test.value)}}
+		if err := dec.Unmarshal(prefix, kvs, &tbStrings{}); !errors.Is(err, test.want) {
+			t.Errorf("%s: expected %v, got %v", test.key, test.want, err)
+		}
+	}
+
+	// As with other values that can't be parsed, lenient decoders skip them.
+	lenient := &Decoder{MaxStringSize: 6, Lenient: true}
+	kvs := consulapi.KVPairs{{Key: prefix + "/name", Value: []byte("much too long")}}
+	report, err := lenient.UnmarshalReport(prefix, kvs, &tbStrings{})
+	if err != nil || report.Stats.ParseErrors != 1 {
+		t.Errorf("expected the value to be skipped, got %+v %v", report.Stats, err)
+	}
+}
+
+type tbWeak struct {
+	Bool1  bool
+	Bool2  bool
+	Bool3  bool
+	Int1   int
+	Int2   int8
+	Uint   uint
+	Float  float64
+	String string
+}
+
+func TestUnmarshalWeaklyTyped(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/bool1", Value: []byte("yes")},
+		{Key: prefix + "/bool2", Value: []byte("2")},
+		{Key: prefix + "/bool3", Value: []byte("")},
+		{Key: prefix + "/float", Value: []byte("true")},
+		{Key: prefix + "/int1", Value: []byte("true")},
+		{Key: prefix + "/int2", Value: []byte(" 3.0 ")},
+		{Key: prefix + "/string", Value: []byte("42")},
+		{Key: prefix + "/uint", Value: []byte("")},
+	}
+
+	if err := Unmarshal(prefix, kvs, &tbWeak{}); err == nil {
+		t.Fatal("expected strict parsing to fail")
+	}
+
+	cfg := &tbWeak{Uint: 5}
+	if err := (&Decoder{WeaklyTypedInput: true}).Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := tbWeak{Bool1: true, Bool2: true, Int1: 1, Int2: 3, Float: 1, String: "42"}
+	if *cfg != expected {
+		t.Errorf("expected %+v, got %+v", expected, *cfg)
+	}
+
+	kvs = consulapi.KVPairs{{Key: prefix + "/int1", Value: []byte("1.5")}}
+	if err := (&Decoder{WeaklyTypedInput: true}).Unmarshal(prefix, kvs, &tbWeak{}); err == nil {
+		t.Error("expected fractional value into int to fail")
+	}
+}
+
+type tbDecimalComma struct {
+	Rate   float64
+	Total  float32
+	Plain  float64
+	Limits []float64 `decoder:",csv,sep=;"`
+}
+
+func TestUnmarshalDecimalComma(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/limits", Value: []byte("0,5;1,25")},
+		{Key: prefix + "/plain", Value: []byte("2.5")},
+		{Key: prefix + "/rate", Value: []byte("1,5")},
+		{Key: prefix + "/total", Value: []byte("1.234,5")},
+	}
+
+	if err := Unmarshal(prefix, kvs, &tbDecimalComma{}); err == nil {
+		t.Fatal("expected decimal commas to fail by default")
+	}
+
+	cfg := &tbDecimalComma{}
+	if err := (&Decoder{DecimalComma: true}).Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Rate != 1.5 || cfg.Total != 1234.5 || cfg.Plain != 2.5 {
+		t.Errorf("unexpected floats: %+v", cfg)
+	}
+	if len(cfg.Limits) != 2 || cfg.Limits[0] != 0.5 || cfg.Limits[1] != 1.25 {
+		t.Errorf("unexpected limits: %v", cfg.Limits)
+	}
+
+	kvs = consulapi.KVPairs{{Key: prefix + "/rate", Value: []byte("1,5,0")}}
+	if err := (&Decoder{DecimalComma: true}).Unmarshal(prefix, kvs, &tbDecimalComma{}); err == nil {
+		t.Error("expected error for more than one comma")
+	}
+}
+
+func TestUnmarshalLenient(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/testcommasepint", Value: []byte("1,two,3")},
+		{Key: prefix + "/testduration", Value: []byte("soon")},
+		{Key: prefix + "/testmapstringstruct/key1/field1", Value: []byte("value")},
+		{Key: prefix + "/testslicestring", Value: []byte("[not json")},
+		{Key: prefix + "/l1/int", Value: []byte("-1")},
+		{Key: prefix + "/l1/uint", Value: []byte("-1")},
+	}
+
+	if err := Unmarshal(prefix, kvs, &tbConfig{}); err == nil {
+		t.Fatal("expected error without lenient")
+	}
+
+	cfg := &tbConfig{TestDuration: time.Second}
+	report, err := (&Decoder{Lenient: true}).UnmarshalReport(prefix, kvs, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if report.Stats.ParseErrors != 4 || len(report.Warnings) != 4 {
+		t.Errorf("expected 4 parse errors reported, got %+v", report)
+	}
+	if cfg.TestDuration != time.Second || len(cfg.TestCommaSepInt) != 0 || len(cfg.TestSliceString) != 0 || cfg.L1.Uint != 0 {
+		t.Errorf("expected unparsable values to be skipped: %+v", cfg)
+	}
+	if cfg.L1.Int != -1 || cfg.TestMapStringStruct["key1"].Field1 != "value" {
+		t.Errorf("expected parsable values to be decoded: %+v", cfg)
+	}
+}
+
+type tbEmpty struct {
+	String  string
+	Int     int
+	IP      net.IP
+	Strings map[string]string
+	Ints    []int `decoder:",csv"`
+	Structs map[string]TestStruct
+}
+
+func TestUnmarshalEmptyValues(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/int", Value: []byte("")},
+		{Key: prefix + "/ints", Value: []byte("")},
+		{Key: prefix + "/ip", Value: []byte("")},
+		{Key: prefix + "/string", Value: []byte("")},
+		{Key: prefix + "/strings/one", Value: []byte("")},
+		{Key: prefix + "/structs/one/field1", Value: []byte("")},
+	}
+	initial := func() *tbEmpty {
+		return &tbEmpty{String: "s", Int: 1, IP: net.IPv4bcast, Strings: map[string]string{"two": "2"}}
+	}
+
+	if err := Unmarshal(prefix, kvs, initial()); err == nil {
+		t.Error("expected empty int to fail by default")
+	}
+
+	cfg := initial()
+	if err := (&Decoder{EmptyValues: EmptyAsMissing}).Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.String != "s" || cfg.Int != 1 || !cfg.IP.Equal(net.IPv4bcast) || len(cfg.Strings) != 1 || len(cfg.Structs) != 1 {
+		t.Errorf("expected empty values to be skipped: %+v", cfg)
+	}
+
+	cfg = initial()
+	if err := (&Decoder{EmptyValues: EmptyAsZero}).Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.String != "" || cfg.Int != 0 || cfg.IP != nil || len(cfg.Strings) != 2 || len(cfg.Ints) != 0 || len(cfg.Structs) != 1 {
+		t.Errorf("expected empty values to be zeroed: %+v", cfg)
+	}
+
+	if err := (&Decoder{EmptyValues: EmptyError}).Unmarshal(prefix, kvs[3:4], initial()); err == nil {
+		t.Error("expected empty string to fail")
+	}
+}
+
+type tbTrim struct {
+	Trimmed   int  `decoder:",trim"`
+	Untrimmed bool `decoder:"untrimmed"`
+	Spaced    string
+}
+
+func TestUnmarshalTrim(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/spaced", Value: []byte(" spaced\n")},
+		{Key: prefix + "/trimmed", Value: []byte("42\n")},
+	}
+
+	cfg := &tbTrim{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Trimmed != 42 || cfg.Spaced != " spaced\n" {
+		t.Errorf("expected only the tagged field to be trimmed: %+v", cfg)
+	}
+
+	kvs = append(kvs, &consulapi.KVPair{Key: prefix + "/untrimmed", Value: []byte("true\r\n")})
+	if err := Unmarshal(prefix, kvs, &tbTrim{}); err == nil {
+		t.Error("expected untrimmed bool to fail")
+	}
+
+	cfg = &tbTrim{}
+	if err := (&Decoder{TrimSpace: true}).Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !cfg.Untrimmed || cfg.Spaced != "spaced" {
+		t.Errorf("expected all values to be trimmed: %+v", cfg)
+	}
+}
+
+type (
+	tbOneOf struct {
+		Level  string        `decoder:",oneof=debug|info|warn"`
+		Port   int           `decoder:",oneof=80|443"`
+		Wait   time.Duration `decoder:",oneof=1s|1m"`
+		Levels []string      `decoder:",csv,oneof=debug|info"`
+	}
+
+	tbOneOfInvalid struct {
+		Struct TestStruct `decoder:",oneof=a|b"`
+	}
+)
+
+func TestUnmarshalOneOf(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/level", Value: []byte("info")},
+		{Key: prefix + "/levels", Value: []byte("debug,info")},
+		{Key: prefix + "/port", Value: []byte("0443")},
+		{Key: prefix + "/wait", Value: []byte("60s")},
+	}
+	cfg := &tbOneOf{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Level != "info" || cfg.Port != 443 || cfg.Wait != time.Minute || len(cfg.Levels) != 2 {
+		t.Errorf("unexpected result: %+v", cfg)
+	}
+
+	for _, kv := range [][2]string{{"level", "error"}, {"levels", "debug,warn"}, {"port", "8080"}, {"wait", "1h"}} {
+		err := Unmarshal(prefix, consulapi.KVPairs{{Key: prefix + "/" + kv[0], Value: []byte(kv[1])}}, &tbOneOf{})
+		if err == nil || !strings.Contains(err.Error(), "must be one of") {
+			t.Errorf("expected %s=%s to be rejected, got %v", kv[0], kv[1], err)
+		}
+	}
+
+	if err := Unmarshal(prefix, kvs, &tbOneOfInvalid{}); err == nil {
+		t.Error("expected oneof on a struct to be rejected")
+	}
+}
+
+type (
+	tbRange struct {
+		Port    uint16        `decoder:",min=1,max=65535"`
+		Timeout time.Duration `decoder:",min=1s,max=1m"`
+		Ratio   float64       `decoder:",max=1"`
+		Offset  int           `decoder:",min=-10"`
+	}
+
+	tbRangeInvalid struct {
+		Name string `decoder:",min=1"`
+	}
+
+	tbRangeInvalidBound struct {
+		Port int `decoder:",max=lots"`
+	}
+)
+
+func TestUnmarshalRange(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/offset", Value: []byte("-10")},
+		{Key: prefix + "/port", Value: []byte("65535")},
+		{Key: prefix + "/ratio", Value: []byte("0.5")},
+		{Key: prefix + "/timeout", Value: []byte("30s")},
+	}
+	cfg := &tbRange{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Port != 65535 || cfg.Timeout != 30*time.Second || cfg.Ratio != 0.5 || cfg.Offset != -10 {
+		t.Errorf("unexpected result: %+v", cfg)
+	}
+
+	for _, kv := range [][2]string{{"port", "0"}, {"timeout", "2m"}, {"timeout", "10ms"}, {"ratio", "1.5"}, {"offset", "-11"}} {
+		err := Unmarshal(prefix, consulapi.KVPairs{{Key: prefix + "/" + kv[0], Value: []byte(kv[1])}}, &tbRange{})
+		if err == nil || !strings.Contains(err.Error(), "must be at") {
+			t.Errorf("expected %s=%s to be rejected, got %v", kv[0], kv[1], err)
+		}
+	}
+
+	if err := Unmarshal(prefix, kvs, &tbRangeInvalid{}); err == nil {
+		t.Error("expected min on a string to be rejected")
+	}
+	if err := Unmarshal(prefix, kvs, &tbRangeInvalidBound{}); err == nil {
+		t.Error("expected invalid max to be rejected")
+	}
+}
+
+type tbMsgpack struct {
+	Struct *TestStruct               `decoder:",msgpack"`
+	Map    map[string]int            `decoder:",msgpack"`
+	List   []string                  `decoder:",msgpack"`
+	Value  TestNestedJSONStructValue `decoder:",msgpack"`
+}
+
+func TestUnmarshalMsgpack(t *testing.T) {
+	encode := func(v interface{}) []byte {
+		b, err := msgpack.Marshal(v)
+		if err != nil {
+			t.Fatalf("unable to encode %v: %s", v, err)
+		}
+		return b
+	}
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/list", Value: encode([]string{"a", "b"})},
+		{Key: prefix + "/map", Value: encode(map[string]int{"one": 1})},
+		{Key: prefix + "/struct", Value: encode(map[string]string{"Field1": "value1"})},
+		{Key: prefix + "/value", Value: encode(map[string]interface{}{"Field1": "value", "Field2": map[string]interface{}{"x": 1}})},
+	}
+
+	cfg := &tbMsgpack{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Struct == nil || cfg.Struct.Field1 != "value1" || cfg.Map["one"] != 1 || len(cfg.List) != 2 || cfg.Value.Field1 != "value" {
+		t.Errorf("unexpected result: %+v", cfg)
+	}
+
+	kvs = consulapi.KVPairs{{Key: prefix + "/map", Value: []byte{0xc1}}}
+	if err := Unmarshal(prefix, kvs, &tbMsgpack{}); err == nil {
+		t.Error("expected invalid msgpack to fail")
+	}
+}
+
+type tbFileMode struct {
+	Mode  os.FileMode
+	Dir   *os.FileMode
+	Modes []os.FileMode `decoder:",csv"`
+}
+
+func TestUnmarshalFileMode(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/dir", Value: []byte("0o755")},
+		{Key: prefix + "/mode", Value: []byte("0644")},
+		{Key: prefix + "/modes", Value: []byte("600,640")},
+	}
+
+	cfg := &tbFileMode{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Mode != 0644 || cfg.Dir == nil || *cfg.Dir != 0755 || len(cfg.Modes) != 2 || cfg.Modes[1] != 0640 {
+		t.Errorf("unexpected result: %+v", cfg)
+	}
+
+	kvs = consulapi.KVPairs{{Key: prefix + "/mode", Value: []byte("0958")}}
+	if err := Unmarshal(prefix, kvs, &tbFileMode{}); err == nil {
+		t.Error("expected error for non-octal mode")
+	}
+}
+
+// tVersion stands in for the semver types of third party packages, which
+// implement encoding.TextUnmarshaler in the same way.
+type tVersion struct {
+	Major, Minor, Patch int
+}
+
+func (v *tVersion) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(strings.TrimPrefix(string(text), "v"), "%d.%d.%d", &v.Major, &v.Minor, &v.Patch)
+	if err != nil {
+		return fmt.Errorf("invalid version %q: %s", text, err)
+	}
+	return nil
+}
+
+type tbVersion struct {
+	MinVersion *tVersion
+	MaxVersion tVersion
+	Services   map[string]*tVersion
+	Supported  []tVersion
+}
+
+func TestUnmarshalVersion(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/maxversion", Value: []byte("v2.0.0")},
+		{Key: prefix + "/minversion", Value: []byte("v1.2.3")},
+		{Key: prefix + "/services/api", Value: []byte("2.0.1")},
+		{Key: prefix + "/supported/0", Value: []byte("1.0.0")},
+		{Key: prefix + "/supported/1", Value: []byte("1.1.0")},
+	}
+
+	cfg := &tbVersion{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.MinVersion == nil || *cfg.MinVersion != (tVersion{1, 2, 3}) {
+		t.Errorf("unexpected min version: %v", cfg.MinVersion)
+	}
+	if cfg.MaxVersion != (tVersion{2, 0, 0}) {
+		t.Errorf("unexpected max version: %v", cfg.MaxVersion)
+	}
+	if cfg.Services["api"] == nil || *cfg.Services["api"] != (tVersion{2, 0, 1}) {
+		t.Errorf("unexpected services: %v", cfg.Services)
+	}
+	if len(cfg.Supported) != 2 || cfg.Supported[1] != (tVersion{1, 1, 0}) {
+		t.Errorf("unexpected supported versions: %v", cfg.Supported)
+	}
+
+	for _, key := range []string{"/minversion", "/services/api", "/supported/0"} {
+		kvs = consulapi.KVPairs{{Key: prefix + key, Value: []byte("latest")}}
+		err := Unmarshal(prefix, kvs, &tbVersion{})
+		if err == nil || !strings.Contains(err.Error(), "invalid version") {
+			t.Errorf("%s: expected invalid version error, got %v", key, err)
+		}
+	}
+}
+
+// tUUID is laid out like the UUID types of the common uuid packages.
+type tUUID [16]byte
+
+func (u *tUUID) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(strings.ReplaceAll(string(text), "-", ""))
+	if err != nil {
+		return err
+	}
+	if len(b) != len(u) {
+		return fmt.Errorf("invalid uuid %q", text)
+	}
+	copy(u[:], b)
+	return nil
+}
+
+type tbUUID struct {
+	ID     *tUUID
+	Parent tUUID
+}
+
+func TestUnmarshalUUID(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/id", Value: []byte("6ba7b810-9dad-11d1-80b4-00c04fd430c8")},
+		{Key: prefix + "/parent", Value: []byte("6ba7b811-9dad-11d1-80b4-00c04fd430c9")},
+	}
+
+	cfg := &tbUUID{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.ID == nil || cfg.ID[0] != 0x6b || cfg.ID[15] != 0xc8 || cfg.Parent[15] != 0xc9 {
+		t.Errorf("unexpected result: %v", cfg.ID)
+	}
+
+	kvs = consulapi.KVPairs{{Key: prefix + "/id", Value: []byte("6ba7b810")}}
+	if err := Unmarshal(prefix, kvs, &tbUUID{}); err == nil {
+		t.Error("expected error for short uuid")
+	}
+}
+
+type tbCSVTypes struct {
+	Durations []time.Duration `decoder:",csv"`
+	IPs       []net.IP        `decoder:",ssv"`
+	Versions  []*tVersion     `decoder:",csv"`
+	Peers     []HostPort      `decoder:",ssv"`
+}
+
+func TestUnmarshalCSVTypes(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/durations", Value: []byte("1s,2m")},
+		{Key: prefix + "/ips", Value: []byte("10.0.0.1 ::1")},
+		{Key: prefix + "/peers", Value: []byte("a:1 b:2")},
+		{Key: prefix + "/versions", Value: []byte("1.0.0,v2.1.0")},
+	}
+
+	cfg := &tbCSVTypes{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(cfg.Durations) != 2 || cfg.Durations[1] != 2*time.Minute {
+		t.Errorf("unexpected durations: %v", cfg.Durations)
+	}
+	if len(cfg.IPs) != 2 || !cfg.IPs[1].Equal(net.IPv6loopback) {
+		t.Errorf("unexpected ips: %v", cfg.IPs)
+	}
+	if len(cfg.Versions) != 2 || *cfg.Versions[1] != (tVersion{2, 1, 0}) {
+		t.Errorf("unexpected versions: %v", cfg.Versions)
+	}
+	if len(cfg.Peers) != 2 || cfg.Peers[1].String() != "b:2" {
+		t.Errorf("unexpected peers: %v", cfg.Peers)
+	}
+
+	kvs = consulapi.KVPairs{{Key: prefix + "/ips", Value: []byte("10.0.0.1 nope")}}
+	if err := Unmarshal(prefix, kvs, &tbCSVTypes{}); err == nil {
+		t.Error("expected error for invalid address")
+	}
+}
+
+type tbMapCSV struct {
+	Tenants map[string][]string        `decoder:",csv"`
+	Ports   map[string][]*int          `decoder:",ssv"`
+	Ranges  map[string][]time.Duration `decoder:",csv"`
+}
+
+func TestUnmarshalMapCSV(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/ports/web", Value: []byte("80 443")},
+		{Key: prefix + "/ranges/retry", Value: []byte("1s,5s")},
+		{Key: prefix + "/tenants/a", Value: []byte("alice,bob")},
+		{Key: prefix + "/tenants/b", Value: []byte("carol")},
+	}
+
+	cfg := &tbMapCSV{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Join(cfg.Tenants["a"], " ") != "alice bob" || strings.Join(cfg.Tenants["b"], " ") != "carol" {
+		t.Errorf("unexpected tenants: %v", cfg.Tenants)
+	}
+	if len(cfg.Ports["web"]) != 2 || *cfg.Ports["web"][1] != 443 {
+		t.Errorf("unexpected ports: %v", cfg.Ports)
+	}
+	if len(cfg.Ranges["retry"]) != 2 || cfg.Ranges["retry"][1] != 5*time.Second {
+		t.Errorf("unexpected ranges: %v", cfg.Ranges)
+	}
+
+	type tbMapSlice struct {
+		Tenants map[string][]string
+	}
+	err := Unmarshal(prefix, kvs, &tbMapSlice{})
+	if err == nil || !strings.Contains(err.Error(), "maps of slices") {
+		t.Errorf("expected maps of slices error, got %v", err)
+	}
+}
+
+type tbGroupedItem struct {
+	Name string
+	Port int
+	Tags []string
+}
+
+type tbGrouped struct {
+	Items  map[string]*tbGroupedItem
+	Single string
+}
+
+func TestUnmarshalScatteredMapPairs(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/items/a/name", Value: []byte("alpha")},
+		{Key: prefix + "/items/b/name", Value: []byte("beta")},
+		{Key: prefix + "/single", Value: []byte("value")},
+		{Key: prefix + "/items/a/port", Value: []byte("1")},
+		{Key: prefix + "/items/b/tags/0", Value: []byte("x")},
+		{Key: prefix + "/items/a/tags/0", Value: []byte("y")},
+		{Key: prefix + "/items/b/port", Value: []byte("2")},
+		{Key: prefix + "/items/a/tags/1", Value: []byte("z")},
+	}
+
+	cfg := &tbGrouped{}
+	rep, err := UnmarshalReport(prefix, kvs, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	a, b := cfg.Items["a"], cfg.Items["b"]
+	if a == nil || a.Name != "alpha" || a.Port != 1 || strings.Join(a.Tags, ",") != "y,z" {
+		t.Errorf("unexpected item a: %+v", a)
+	}
+	if b == nil || b.Name != "beta" || b.Port != 2 || strings.Join(b.Tags, ",") != "x" {
+		t.Errorf("unexpected item b: %+v", b)
+	}
+	if cfg.Single != "value" {
+		t.Errorf("unexpected single: %s", cfg.Single)
+	}
+	if rep.Stats.Keys != len(kvs) {
+		t.Errorf("expected %d keys, got %d", len(kvs), rep.Stats.Keys)
+	}
+}
+
+type tbShuffled struct {
+	Items  []tbGroupedItem
+	Ptrs   []*tbGroupedItem
+	Names  []string
+	Single string
+}
+
+func TestUnmarshalShuffledSlicePairs(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/items/0/name", Value: []byte("alpha")},
+		{Key: prefix + "/items/0/port", Value: []byte("1")},
+		{Key: prefix + "/items/0/tags/0", Value: []byte("x")},
+		{Key: prefix + "/items/0/tags/1", Value: []byte("y")},
+		{Key: prefix + "/items/1/name", Value: []byte("beta")},
+		{Key: prefix + "/items/1/port", Value: []byte("2")},
+		{Key: prefix + "/names/0", Value: []byte("first")},
+		{Key: prefix + "/names/1", Value: []byte("second")},
+		{Key: prefix + "/ptrs/0/name", Value: []byte("gamma")},
+		{Key: prefix + "/ptrs/1/name", Value: []byte("delta")},
+		{Key: prefix + "/ptrs/1/port", Value: []byte("4")},
+		{Key: prefix + "/single", Value: []byte("value")},
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		shuffled := append(consulapi.KVPairs(nil), kvs...)
+		rnd.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+		cfg := &tbShuffled{}
+		if err := Unmarshal(prefix, shuffled, cfg); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(cfg.Items) != 2 || cfg.Items[0].Name != "alpha" || strings.Join(cfg.Items[0].Tags, ",") != "x,y" ||
+			cfg.Items[1].Name != "beta" || cfg.Items[1].Port != 2 {
+			t.Fatalf("unexpected items: %+v", cfg.Items)
+		}
+		if len(cfg.Ptrs) != 2 || cfg.Ptrs[0].Name != "gamma" || cfg.Ptrs[1].Name != "delta" || cfg.Ptrs[1].Port != 4 {
+			t.Fatalf("unexpected ptrs: %+v", cfg.Ptrs)
+		}
+		if strings.Join(cfg.Names, ",") != "first,second" || cfg.Single != "value" {
+			t.Fatalf("unexpected result: %+v", cfg)
+		}
+	}
+}
+
+type tbPtrCollections struct {
+	Map       *map[string]string
+	MapPtrs   **map[string]*string
+	List      **[]string
+	Ints      *[]**int
+	Items     []**tbGroupedItem
+	ItemMap   *map[string]**tbGroupedItem
+	ValuePtrs map[string]**string
+	CSV       **[]*int               `decoder:",csv"`
+	JSON      **map[string]int       `decoder:",json"`
+	Struct    **tbGroupedItem        `decoder:",json"`
+	Nested    *map[string][]**string `decoder:",ssv"`
+}
+
+func TestUnmarshalPointerCollections(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/csv", Value: []byte("1,2")},
+		{Key: prefix + "/ints/0", Value: []byte("3")},
+		{Key: prefix + "/itemmap/a/name", Value: []byte("alpha")},
+		{Key: prefix + "/items/0/name", Value: []byte("beta")},
+		{Key: prefix + "/json", Value: []byte(`{"a":4}`)},
+		{Key: prefix + "/list/0", Value: []byte("x")},
+		{Key: prefix + "/map/a", Value: []byte("y")},
+		{Key: prefix + "/mapptrs/a", Value: []byte("z")},
+		{Key: prefix + "/nested/a", Value: []byte("p q")},
+		{Key: prefix + "/struct", Value: []byte(`{"Name":"gamma"}`)},
+		{Key: prefix + "/valueptrs/a", Value: []byte("w")},
+	}
+
+	cfg := &tbPtrCollections{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	switch {
+	case cfg.Map == nil || (*cfg.Map)["a"] != "y":
+		t.Errorf("unexpected map: %v", cfg.Map)
+	case cfg.MapPtrs == nil || *(**cfg.MapPtrs)["a"] != "z":
+		t.Errorf("unexpected map of pointers: %v", cfg.MapPtrs)
+	case cfg.List == nil || len(**cfg.List) != 1 || (**cfg.List)[0] != "x":
+		t.Errorf("unexpected list: %v", cfg.List)
+	case cfg.Ints == nil || len(*cfg.Ints) != 1 || **(*cfg.Ints)[0] != 3:
+		t.Errorf("unexpected ints: %v", cfg.Ints)
+	case len(cfg.Items) != 1 || (**cfg.Items[0]).Name != "beta":
+		t.Errorf("unexpected items: %v", cfg.Items)
+	case cfg.ItemMap == nil || (**(*cfg.ItemMap)["a"]).Name != "alpha":
+		t.Errorf("unexpected item map: %v", cfg.ItemMap)
+	case **cfg.ValuePtrs["a"] != "w":
+		t.Errorf("unexpected value pointers: %v", cfg.ValuePtrs)
+	case cfg.CSV == nil || len(**cfg.CSV) != 2 || *(**cfg.CSV)[1] != 2:
+		t.Errorf("unexpected csv: %v", cfg.CSV)
+	case cfg.JSON == nil || (**cfg.JSON)["a"] != 4:
+		t.Errorf("unexpected json: %v", cfg.JSON)
+	case cfg.Struct == nil || (**cfg.Struct).Name != "gamma":
+		t.Errorf("unexpected struct: %v", cfg.Struct)
+	case cfg.Nested == nil || len((*cfg.Nested)["a"]) != 2 || **(*cfg.Nested)["a"][1] != "q":
+		t.Errorf("unexpected nested: %v", cfg.Nested)
+	}
+}
+
+type tbOutside struct {
+	Name string
+}
+
+func TestUnmarshalOutsidePrefix(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: "other/name", Value: []byte("wrong")},
+		{Key: prefix + "/name", Value: []byte("right")},
+	}
+
+	cfg := &tbOutside{}
+	rep, err := UnmarshalReport(prefix, kvs, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Name != "right" || len(rep.Warnings) != 1 || rep.Warnings[0].Key != "other/name" {
+		t.Errorf("unexpected result: %+v, %v", cfg, rep.Warnings)
+	}
+
+	rep, err = (&Decoder{OutsidePrefix: IssueIgnore}).UnmarshalReport(prefix, kvs, &tbOutside{})
+	if err != nil || len(rep.Warnings) != 0 {
+		t.Errorf("expected no warnings, got %v, %v", rep.Warnings, err)
+	}
+
+	err = (&Decoder{OutsidePrefix: IssueError}).Unmarshal(prefix, kvs, &tbOutside{})
+	if err == nil || !strings.Contains(err.Error(), "outside of prefix") {
+		t.Errorf("expected outside of prefix error, got %v", err)
+	}
+
+	// An empty prefix is the root of the tree.
+	cfg = &tbOutside{}
+	rep, err = UnmarshalReport("", consulapi.KVPairs{{Key: "name", Value: []byte("root")}}, cfg)
+	if err != nil || cfg.Name != "root" || len(rep.Warnings) != 0 {
+		t.Errorf("unexpected result for empty prefix: %+v, %v, %v", cfg, rep.Warnings, err)
+	}
+}
+
+type tbEmbedBase struct {
+	Region  string
+	Timeout time.Duration
+	Name    string
+}
+
+type tbEmbedNamed struct {
+	Level string
+}
+
+type tbEmbedIface interface {
+	Close() error
+}
+
+type tbEmbedded struct {
+	tbEmbedBase
+	tbEmbedNamed `decoder:"logging"`
+	tbEmbedIface
+	Name string
+}
+
+type tbEmbeddedPtr struct {
+	*tbEmbedBase
+}
+
+func TestUnmarshalEmbedded(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/logging/level", Value: []byte("debug")},
+		{Key: prefix + "/name", Value: []byte("own")},
+		{Key: prefix + "/region", Value: []byte("us-east")},
+		{Key: prefix + "/tbembedbase/region", Value: []byte("ignored")},
+		{Key: prefix + "/timeout", Value: []byte("5s")},
+	}
+
+	cfg := &tbEmbedded{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Region != "us-east" || cfg.Timeout != 5*time.Second || cfg.Name != "own" || cfg.tbEmbedBase.Name != "" {
+		t.Errorf("unexpected promoted fields: %+v", cfg.tbEmbedBase)
+	}
+	if cfg.Level != "debug" {
+		t.Errorf("unexpected named embedded struct: %+v", cfg.tbEmbedNamed)
+	}
+	if h, err := Hash(cfg); err != nil || h == 0 {
+		t.Errorf("unable to hash: %v", err)
+	}
+
+	err := Unmarshal(prefix, kvs, &tbEmbeddedPtr{})
+	if err == nil || !strings.Contains(err.Error(), "embedded pointer to unexported struct") {
+		t.Errorf("expected embedded pointer error, got %v", err)
+	}
+}
+
+type (
+	tbPort    uint16
+	tbHosts   []string
+	tbName    string
+	tbBlob    []byte
+	tbLabels  map[tbName]string
+	tbTimeout time.Duration
+)
+
+type tbDefinedTypes struct {
+	Port     tbPort `decoder:",min=1,oneof=80|443"`
+	Hosts    tbHosts
+	Peers    tbHosts `decoder:",csv"`
+	Name     *tbName
+	Blob     tbBlob
+	Labels   tbLabels
+	Ports    map[tbName][]tbPort `decoder:",ssv"`
+	Month    time.Month
+	Timeout  tbTimeout
+	Interval time.Duration
+}
+
+func TestUnmarshalDefinedTypes(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/blob", Value: []byte("raw")},
+		{Key: prefix + "/hosts/0", Value: []byte("a")},
+		{Key: prefix + "/hosts/1", Value: []byte("b")},
+		{Key: prefix + "/interval", Value: []byte("5s")},
+		{Key: prefix + "/labels/env", Value: []byte("prod")},
+		{Key: prefix + "/month", Value: []byte("3")},
+		{Key: prefix + "/name", Value: []byte("web")},
+		{Key: prefix + "/peers", Value: []byte("c,d")},
+		{Key: prefix + "/port", Value: []byte("443")},
+		{Key: prefix + "/ports/web", Value: []byte("80 443")},
+		{Key: prefix + "/timeout", Value: []byte("250")},
+	}
+
+	cfg := &tbDefinedTypes{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Port != 443 || cfg.Month != time.March || *cfg.Name != "web" || string(cfg.Blob) != "raw" {
+		t.Errorf("unexpected scalars: %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Hosts, tbHosts{"a", "b"}) || !reflect.DeepEqual(cfg.Peers, tbHosts{"c", "d"}) {
+		t.Errorf("unexpected slices: %v %v", cfg.Hosts, cfg.Peers)
+	}
+	if cfg.Labels["env"] != "prod" || !reflect.DeepEqual(cfg.Ports["web"], []tbPort{80, 443}) {
+		t.Errorf("unexpected maps: %v %v", cfg.Labels, cfg.Ports)
+	}
+	// A type defined in terms of time.Duration is decoded by its kind.
+	if cfg.Timeout != 250 || cfg.Interval != 5*time.Second {
+		t.Errorf("unexpected durations: %d %s", cfg.Timeout, cfg.Interval)
+	}
+
+	labels := tbLabels{}
+	if err := Unmarshal(prefix+"/labels", kvs, &labels); err != nil || labels["env"] != "prod" {
+		t.Errorf("unexpected labels: %v %v", labels, err)
+	}
+}
+
+type tbInterface struct {
+	Any      interface{}
+	Settings map[string]interface{}
+	Args     []interface{} `decoder:",ssv"`
+	Raw      interface{}   `decoder:",json"`
+	Weight   json.Number
+}
+
+func TestUnmarshalInterface(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/any", Value: []byte("42")},
+		{Key: prefix + "/args", Value: []byte("-v 3")},
+		{Key: prefix + "/raw", Value: []byte(`{"a":[1,2]}`)},
+		{Key: prefix + "/settings/enabled", Value: []byte("true")},
+		{Key: prefix + "/settings/name", Value: []byte("web")},
+		{Key: prefix + "/settings/ratio", Value: []byte("0.5")},
+		{Key: prefix + "/weight", Value: []byte("1e3")},
+	}
+
+	cfg := &tbInterface{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Any != "42" || cfg.Settings["enabled"] != "true" || cfg.Settings["ratio"] != "0.5" {
+		t.Errorf("expected strings by default, got %#v %#v", cfg.Any, cfg.Settings)
+	}
+	if !reflect.DeepEqual(cfg.Args, []interface{}{"-v", "3"}) {
+		t.Errorf("unexpected args: %#v", cfg.Args)
+	}
+	if !reflect.DeepEqual(cfg.Raw, map[string]interface{}{"a": []interface{}{1.0, 2.0}}) {
+		t.Errorf("unexpected raw: %#v", cfg.Raw)
+	}
+	if cfg.Weight != "1e3" {
+		t.Errorf("unexpected weight: %s", cfg.Weight)
+	}
+
+	cfg = &tbInterface{}
+	if err := (&Decoder{InferTypes: true}).Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Any != int64(42) || cfg.Settings["enabled"] != true || cfg.Settings["ratio"] != 0.5 || cfg.Settings["name"] != "web" {
+		t.Errorf("unexpected inferred types: %#v %#v", cfg.Any, cfg.Settings)
+	}
+	if !reflect.DeepEqual(cfg.Args, []interface{}{"-v", int64(3)}) {
+		t.Errorf("unexpected inferred args: %#v", cfg.Args)
+	}
+
+	for _, bad := range []string{"abc", "0x10", "+1", "NaN", "1.", ""} {
+		kvs := consulapi.KVPairs{{Key: prefix + "/weight", Value: []byte(bad)}}
+		if err := Unmarshal(prefix, kvs, &tbInterface{}); err == nil {
+			t.Errorf("expected error for json.Number %q", bad)
+		}
+	}
+}
+
+type tbNestedCollections struct {
+	Web struct {
+		Labels map[string]string
+		Hosts  []string
+		Peers  map[string]tbGroupedItem
+	}
+}
+
+func TestUnmarshalNestedCollections(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/web/hosts/0", Value: []byte("a")},
+		{Key: prefix + "/web/labels/env", Value: []byte("prod")},
+		{Key: prefix + "/web/peers/b/name", Value: []byte("peer")},
+	}
+
+	cfg := &tbNestedCollections{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(cfg.Web.Labels, map[string]string{"env": "prod"}) || !reflect.DeepEqual(cfg.Web.Hosts, []string{"a"}) {
+		t.Errorf("unexpected collections: %+v", cfg.Web)
+	}
+	if cfg.Web.Peers["b"].Name != "peer" {
+		t.Errorf("unexpected peers: %+v", cfg.Web.Peers)
+	}
+}
+
+type tbJSONScalars struct {
+	String   string           `decoder:",json"`
+	Int      int              `decoder:",json"`
+	Bool     bool             `decoder:",json"`
+	Float    float64          `decoder:",json"`
+	Ptr      *int             `decoder:",json"`
+	PtrPtr   **string         `decoder:",json"`
+	Duration time.Duration    `decoder:",json"`
+	Time     time.Time        `decoder:",json"`
+	Bytes    []byte           `decoder:",json"`
+	Nested   map[string][]int `decoder:",json"`
+}
+
+func TestUnmarshalJSONScalars(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/string", Value: []byte(`"a \"quoted\" string"`)},
+		{Key: prefix + "/int", Value: []byte(`42`)},
+		{Key: prefix + "/bool", Value: []byte(`true`)},
+		{Key: prefix + "/float", Value: []byte(`1.5`)},
+		{Key: prefix + "/ptr", Value: []byte(`7`)},
+		{Key: prefix + "/ptrptr", Value: []byte(`"deep"`)},
+		{Key: prefix + "/duration", Value: []byte(`1000000000`)},
+		{Key: prefix + "/time", Value: []byte(`"2020-01-02T03:04:05Z"`)},
+		{Key: prefix + "/bytes", Value: []byte(`"aGk="`)},
+		{Key: prefix + "/nested", Value: []byte(`{"a":[1,2]}`)},
+	}
+	ts := &tbJSONScalars{}
+	if err := Unmarshal(prefix, kvs, ts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ts.String != `a "quoted" string` || ts.Int != 42 || !ts.Bool || ts.Float != 1.5 {
+		t.Errorf("unexpected scalars: %+v", ts)
+	}
+	if ts.Ptr == nil || *ts.Ptr != 7 || ts.PtrPtr == nil || **ts.PtrPtr != "deep" {
+		t.Errorf("unexpected pointers: %v, %v", ts.Ptr, ts.PtrPtr)
+	}
+	if ts.Duration != time.Second || !ts.Time.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("unexpected duration or time: %s, %s", ts.Duration, ts.Time)
+	}
+	if string(ts.Bytes) != "hi" || !reflect.DeepEqual(ts.Nested, map[string][]int{"a": {1, 2}}) {
+		t.Errorf("unexpected bytes or nested: %q, %v", ts.Bytes, ts.Nested)
+	}
+
+	// Values which aren't json, such as a bare string, fail to parse.
+	kvs = consulapi.KVPairs{{Key: prefix + "/string", Value: []byte(`bare`)}}
+	if err := Unmarshal(prefix, kvs, &tbJSONScalars{}); err == nil {
+		t.Error("expected error for a value which isn't json")
+	}
+}
+
+type tbByteSliceElements struct {
+	Keys   map[string][]byte `decoder:",base64"`
+	Blobs  [][]byte
+	Ptrs   map[string]*[]byte
+	Fields [][]byte `decoder:",csv"`
+}
+
+func TestUnmarshalByteSliceElements(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/keys/tenant-a", Value: []byte(base64.StdEncoding.EncodeToString([]byte{0, 1, 2}))},
+		{Key: prefix + "/keys/tenant-b", Value: []byte(base64.StdEncoding.EncodeToString([]byte{0xff}))},
+		{Key: prefix + "/blobs/one", Value: []byte("first")},
+		{Key: prefix + "/blobs/two", Value: []byte("second")},
+		{Key: prefix + "/ptrs/a", Value: []byte("pointed")},
+		{Key: prefix + "/fields", Value: []byte("x,y")},
+	}
+	ts := &tbByteSliceElements{}
+	if err := Unmarshal(prefix, kvs, ts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := map[string][]byte{"tenant-a": {0, 1, 2}, "tenant-b": {0xff}}; !reflect.DeepEqual(ts.Keys, want) {
+		t.Errorf("expected %v, got %v", want, ts.Keys)
+	}
+	if want := [][]byte{[]byte("first"), []byte("second")}; !reflect.DeepEqual(ts.Blobs, want) {
+		t.Errorf("expected %q, got %q", want, ts.Blobs)
+	}
+	if p := ts.Ptrs["a"]; p == nil || string(*p) != "pointed" {
+		t.Errorf("unexpected pointers: %v", ts.Ptrs)
+	}
+	if want := [][]byte{[]byte("x"), []byte("y")}; !reflect.DeepEqual(ts.Fields, want) {
+		t.Errorf("expected %q, got %q", want, ts.Fields)
+	}
+}
+
+type tbDecimals struct {
+	Price  big.Rat
+	Fee    *big.Rat
+	Rates  map[string]*big.Rat
+	Splits []big.Rat `decoder:",csv"`
+	Raw    json.Number
+}
+
+func TestUnmarshalDecimals(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/fee", Value: []byte("0.1")},
+		{Key: prefix + "/price", Value: []byte("19.99")},
+		{Key: prefix + "/rates/eur", Value: []byte("1.10")},
+		{Key: prefix + "/raw", Value: []byte("12.50")},
+		{Key: prefix + "/splits", Value: []byte("0.7,0.3")},
+	}
+	td := &tbDecimals{}
+	if err := Unmarshal(prefix, kvs, td); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if td.Price.Cmp(big.NewRat(1999, 100)) != 0 || td.Fee == nil || td.Fee.Cmp(big.NewRat(1, 10)) != 0 {
+		t.Errorf("unexpected amounts: %s, %v", td.Price.String(), td.Fee)
+	}
+	if r := td.Rates["eur"]; r == nil || r.Cmp(big.NewRat(11, 10)) != 0 {
+		t.Errorf("unexpected rates: %v", td.Rates)
+	}
+	sum := new(big.Rat)
+	for i := range td.Splits {
+		sum.Add(sum, &td.Splits[i])
+	}
+	if len(td.Splits) != 2 || sum.Cmp(big.NewRat(1, 1)) != 0 {
+		t.Errorf("expected splits to sum to exactly 1, got %s", sum)
+	}
+	if td.Raw != "12.50" {
+		t.Errorf("expected the number as written, got %s", td.Raw)
+	}
+
+	kvs = consulapi.KVPairs{{Key: prefix + "/price", Value: []byte("19,99")}}
+	if err := Unmarshal(prefix, kvs, &tbDecimals{}); err == nil {
+		t.Error("expected error for an invalid amount")
+	}
+}
@@ -0,0 +1,12 @@
+// Command decoderlint - checks the struct tags read by the decoder, as a
+// standalone command or with `go vet -vettool=$(which decoderlint)`.
+package main
+
+import (
+	"github.com/myENA/consul-decoder/decoderlint"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(decoderlint.Analyzer)
+}
@@ -0,0 +1,256 @@
+// Package decoderlint - an analysis.Analyzer which checks the struct tags
+// read by the decoder, so that the mistakes it would only report when a
+// struct is first decoded into are caught at build time: unknown modifiers,
+// types which can't be decoded into, such as slices of slices and maps with
+// keys other than strings, and fields whose keys are the same once case is
+// folded.  The decoderlint command runs it, on its own or as a vet tool with
+// `go vet -vettool=$(which decoderlint)`, and it may be added to a
+// multichecker with other analyzers.  It is a module of its own, as it needs
+// a newer go than the decoder.
+package decoderlint
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+
+	decoder "github.com/myENA/consul-decoder"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// Analyzer - checks the decoder struct tags of the structs in a package.
+// Only structs with at least one field with the tag are checked.
+var Analyzer = &analysis.Analyzer{
+	Name:     "decoderlint",
+	Doc:      "check the struct tags read by github.com/myENA/consul-decoder",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+var (
+	// tagName is the struct tag read by the decoder, as in Decoder.Tag.
+	tagName string
+
+	// modifiers are the names of the modifiers and transformers registered
+	// with the decoder, which aren't known otherwise.
+	modifiers string
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&tagName, "tag", "decoder", "the struct tag read by the decoder")
+	Analyzer.Flags.StringVar(&modifiers, "modifiers", "", "comma separated names of the modifiers and transformers registered with the decoder")
+}
+
+// encodingModifiers are the modifiers which decode values whole, so that
+// any type may be used with them.
+var encodingModifiers = map[string]bool{
+	"json":    true,
+	"msgpack": true,
+}
+
+// textUnmarshaler is the method set of encoding.TextUnmarshaler, whose
+// values are decoded whole.
+var textUnmarshaler = types.NewInterfaceType([]*types.Func{
+	types.NewFunc(0, nil, "UnmarshalText", types.NewSignatureType(nil, nil, nil,
+		types.NewTuple(types.NewVar(0, nil, "text", types.NewSlice(types.Typ[types.Byte]))),
+		types.NewTuple(types.NewVar(0, nil, "", types.Universe.Lookup("error").Type())),
+		false)),
+}, nil).Complete()
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	registered := make(map[string]bool)
+	for _, name := range strings.Split(modifiers, ",") {
+		if name != "" {
+			registered[name] = true
+		}
+	}
+
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	ins.Preorder([]ast.Node{(*ast.StructType)(nil)}, func(n ast.Node) {
+		st := n.(*ast.StructType)
+		if !hasTag(st) {
+			return
+		}
+		keys := make(map[string]string)
+		for _, field := range st.Fields.List {
+			checkField(pass, field, registered, keys)
+		}
+	})
+	return nil, nil
+}
+
+// hasTag returns true if any of the fields of st have the decoder's tag.
+func hasTag(st *ast.StructType) bool {
+	for _, field := range st.Fields.List {
+		if _, ok := fieldTag(field); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldTag returns the value of the decoder's tag of field, and whether it
+// has one.
+func fieldTag(field *ast.Field) (string, bool) {
+	if field.Tag == nil {
+		return "", false
+	}
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return "", false
+	}
+	return reflect.StructTag(tag).Lookup(tagName)
+}
+
+// checkField reports the problems with the tag and type of field.  keys
+// holds the keys of the fields of the struct seen so far, lower cased, with
+// the names of their fields.
+func checkField(pass *analysis.Pass, field *ast.Field, registered map[string]bool, keys map[string]string) {
+	tag, _ := fieldTag(field)
+	key, mods, _ := strings.Cut(tag, ",")
+	if key == "-" {
+		return
+	}
+
+	encoded := false
+	var modList []string
+	if mods != "" {
+		modList = strings.Split(mods, ",")
+	}
+	for _, mod := range modList {
+		name, _, _ := strings.Cut(mod, "=")
+		switch {
+		case name == "":
+		case name == "omitempty" && tagName == "mapstructure":
+			// Ignored by decoders with Mapstructure set.
+		case encodingModifiers[name] || registered[name]:
+			// Registered modifiers may decode values whole, so types
+			// aren't checked.
+			encoded = true
+		case !decoder.IsBuiltinModifier(name):
+			pass.Reportf(field.Pos(), "unknown decoder modifier %q", mod)
+		}
+	}
+
+	t := pass.TypesInfo.TypeOf(field.Type)
+	if t != nil && !encoded {
+		if msg := checkType(t, hasModifier(modList, "csv") || hasModifier(modList, "ssv")); msg != "" {
+			pass.Reportf(field.Pos(), "%s", msg)
+		}
+	}
+
+	if hasModifier(modList, "squash") || hasModifier(modList, "remain") {
+		// Neither is read from a key of its own.
+		return
+	}
+	for _, name := range fieldNames(field, t) {
+		if !ast.IsExported(name) && key == "" {
+			// Unexported fields are skipped, and the fields of
+			// unexported embedded structs are promoted.
+			continue
+		}
+		k := key
+		if k == "" {
+			k = name
+		}
+		folded := strings.ToLower(k)
+		if other, ok := keys[folded]; ok {
+			pass.Reportf(field.Pos(), "decoder key %q of %s is the same as that of %s once case is folded", k, name, other)
+			continue
+		}
+		keys[folded] = name
+	}
+}
+
+// hasModifier returns true if mods holds name.
+func hasModifier(mods []string, name string) bool {
+	for _, mod := range mods {
+		if mod == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldNames returns the names of field, which for embedded fields is the
+// name of their type.
+func fieldNames(field *ast.Field, t types.Type) []string {
+	if len(field.Names) > 0 {
+		names := make([]string, 0, len(field.Names))
+		for _, ident := range field.Names {
+			names = append(names, ident.Name)
+		}
+		return names
+	}
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return []string{named.Obj().Name()}
+	}
+	return nil
+}
+
+// checkType returns why a field of type t can't be decoded into, or an
+// empty string if it can.  split is set for fields with csv or ssv.
+func checkType(t types.Type, split bool) string {
+	t = deref(t)
+	if isDecodedWhole(t) {
+		return ""
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		elem := deref(u.Elem())
+		if _, ok := elem.Underlying().(*types.Slice); ok && !isByteSlice(elem) && !isDecodedWhole(elem) {
+			return "slices of slices can't be decoded into, except [][]byte"
+		}
+	case *types.Map:
+		if k, ok := u.Key().Underlying().(*types.Basic); !ok || k.Info()&types.IsString == 0 {
+			return "maps can only be decoded into with string keys, not " + u.Key().String()
+		}
+		elem := deref(u.Elem())
+		switch elem.Underlying().(type) {
+		case *types.Map:
+			if !isDecodedWhole(elem) {
+				return "maps of maps can't be decoded into"
+			}
+		case *types.Slice:
+			if !split && !isByteSlice(elem) && !isDecodedWhole(elem) {
+				return "maps of slices can only be decoded into with csv or ssv, or as map[string][]byte"
+			}
+		}
+	}
+	return ""
+}
+
+// deref returns the type t points to, through any number of pointers.
+func deref(t types.Type) types.Type {
+	for {
+		p, ok := t.Underlying().(*types.Pointer)
+		if !ok {
+			return t
+		}
+		t = p.Elem()
+	}
+}
+
+// isByteSlice returns true if t is a slice of bytes, such as []byte or
+// net.IP.
+func isByteSlice(t types.Type) bool {
+	s, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	b, ok := s.Elem().Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Byte
+}
+
+// isDecodedWhole returns true if values of t are decoded by t itself, as
+// an encoding.TextUnmarshaler.
+func isDecodedWhole(t types.Type) bool {
+	return types.Implements(types.NewPointer(t), textUnmarshaler)
+}
@@ -0,0 +1,23 @@
+package decoderlint
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	if err := Analyzer.Flags.Set("modifiers", "upper"); err != nil {
+		t.Fatal(err)
+	}
+	defer Analyzer.Flags.Set("modifiers", "")
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "a")
+}
+
+func TestAnalyzerMapstructure(t *testing.T) {
+	if err := Analyzer.Flags.Set("tag", "mapstructure"); err != nil {
+		t.Fatal(err)
+	}
+	defer Analyzer.Flags.Set("tag", "decoder")
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "b")
+}
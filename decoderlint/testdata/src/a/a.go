@@ -0,0 +1,50 @@
+package a
+
+import "time"
+
+type Level int
+
+func (l *Level) UnmarshalText(text []byte) error { return nil }
+
+type Config struct {
+	Name    string        `decoder:"name,trim"`
+	Timeout time.Duration `decoder:"timeout,min=1s"`
+	Hosts   []string      `decoder:"hosts,csv,sep=;"`
+	Secret  string        `decoder:"secret,base64,bogus"` // want `unknown decoder modifier "bogus"`
+	Limit   int           `decoder:",nonsense=1"`         // want `unknown decoder modifier "nonsense=1"`
+	Custom  string        `decoder:",upper"`
+
+	Matrix  [][]int // want `slices of slices can't be decoded into, except \[\]\[\]byte`
+	Blobs   [][]byte
+	Raw     [][]int                      `decoder:",json"`
+	ByPort  map[int]string               // want `maps can only be decoded into with string keys, not int`
+	Nested  map[string]map[string]string // want `maps of maps can't be decoded into`
+	Lists   map[string][]string          // want `maps of slices can only be decoded into with csv or ssv, or as map\[string\]\[\]byte`
+	Split   map[string][]string          `decoder:",ssv"`
+	Levels  map[Level]string             // want `maps can only be decoded into with string keys, not a.Level`
+	ByLevel map[string]Level
+
+	HOST    string
+	Host    string // want `decoder key "Host" of Host is the same as that of HOST once case is folded`
+	Alias   string `decoder:"name"` // want `decoder key "name" of Alias is the same as that of Name once case is folded`
+	Ignored string `decoder:"-"`
+	ignored string
+}
+
+// Untagged structs aren't checked.
+type Untagged struct {
+	Matrix [][]int
+}
+
+type Base struct {
+	Name string
+}
+
+// Squashed and remain fields don't have keys of their own.
+type Squashed struct {
+	Base  `decoder:",squash"`
+	Other Base              `decoder:"base,squash"`
+	Name2 string            `decoder:"base"`
+	Rest  map[string]string `decoder:",remain"`
+	Extra string            `decoder:"rest"`
+}
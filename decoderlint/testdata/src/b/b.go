@@ -0,0 +1,7 @@
+package b
+
+// omitempty is ignored by decoders with Mapstructure set.
+type Config struct {
+	Name string `mapstructure:"name,omitempty"`
+	Port int    `mapstructure:"port,unknown"` // want `unknown decoder modifier "unknown"`
+}
@@ -0,0 +1,98 @@
+// Package decodertest - helpers for testing the decoding of configuration
+// structs without a consul agent, by building api.KVPairs from maps and file
+// trees, and comparing the decoded structs against golden files.  Store is
+// an in-memory KV store for testing code which fetches and watches them.
+package decodertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// UpdateEnv - the environment variable which, when set to a non-empty value,
+// causes AssertGolden to write golden files rather than compare against
+// them, as in `DECODERTEST_UPDATE=1 go test ./...`.
+const UpdateEnv = "DECODERTEST_UPDATE"
+
+// PairsFromMap - returns a pair for each entry of m, with the key of the
+// entry below prefix, sorted by key as consul returns them.
+func PairsFromMap(prefix string, m map[string]string) api.KVPairs {
+	kvps := make(api.KVPairs, 0, len(m))
+	for k, v := range m {
+		kvps = append(kvps, &api.KVPair{Key: joinKey(prefix, k), Value: []byte(v)})
+	}
+	sortPairs(kvps)
+	return kvps
+}
+
+// PairsFromFS - returns a pair for each file in fsys, with its path below
+// prefix as its key and its contents as its value, sorted by key.  Folders
+// become folders of keys, so a tree checked in to testdata can stand in for
+// a tree in consul, as with PairsFromFS("app", os.DirFS("testdata/app")).
+func PairsFromFS(prefix string, fsys fs.FS) (api.KVPairs, error) {
+	var kvps api.KVPairs
+	err := fs.WalkDir(fsys, ".", func(name string, de fs.DirEntry, err error) error {
+		if err != nil || de.IsDir() {
+			return err
+		}
+		value, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		kvps = append(kvps, &api.KVPair{Key: joinKey(prefix, name), Value: value})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortPairs(kvps)
+	return kvps, nil
+}
+
+// AssertGolden - marshals v as indented json, and fails t if it differs from
+// the contents of the golden file at name.  If the UpdateEnv environment
+// variable is set, the golden file is written instead.
+func AssertGolden(t testing.TB, name string, v interface{}) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("unable to marshal %T: %s", v, err)
+	}
+	got = append(got, '\n')
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatalf("unable to create folder for golden file: %s", err)
+		}
+		if err := os.WriteFile(name, got, 0o644); err != nil {
+			t.Fatalf("unable to write golden file: %s", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("unable to read golden file, set %s to create it: %s", UpdateEnv, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%T differs from golden file %s, set %s to update it:\ngot:\n%s\nwant:\n%s", v, name, UpdateEnv, got, want)
+	}
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return strings.TrimPrefix(key, "/")
+	}
+	return path.Join(prefix, key)
+}
+
+func sortPairs(kvps api.KVPairs) {
+	sort.Slice(kvps, func(i, j int) bool { return kvps[i].Key < kvps[j].Key })
+}
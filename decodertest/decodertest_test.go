@@ -0,0 +1,75 @@
+package decodertest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	decoder "github.com/myENA/consul-decoder"
+)
+
+type tbConfig struct {
+	Name string
+	DB   struct {
+		Host string
+		Port int
+	}
+	Tags []string
+}
+
+func TestPairsFromMap(t *testing.T) {
+	kvps := PairsFromMap("app", map[string]string{
+		"name":    "svc",
+		"db/port": "5432",
+		"db/host": "db.local",
+	})
+	var keys []string
+	for _, kvp := range kvps {
+		keys = append(keys, kvp.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"app/db/host", "app/db/port", "app/name"}) {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+
+	cfg := &tbConfig{}
+	if err := decoder.Unmarshal("app", kvps, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Name != "svc" || cfg.DB.Port != 5432 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	if kvps := PairsFromMap("", map[string]string{"/name": "svc"}); kvps[0].Key != "name" {
+		t.Errorf("unexpected key without prefix: %s", kvps[0].Key)
+	}
+}
+
+func TestPairsFromFS(t *testing.T) {
+	kvps, err := PairsFromFS("app", os.DirFS("testdata/app"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cfg := &tbConfig{}
+	if err := decoder.Unmarshal("app", kvps, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	AssertGolden(t, "testdata/config.golden", cfg)
+
+	kvps, err = PairsFromFS("", fstest.MapFS{
+		"db/host": {Data: []byte("db.local")},
+		"tags/0":  {Data: []byte("a")},
+	})
+	if err != nil || len(kvps) != 2 || kvps[0].Key != "db/host" || string(kvps[1].Value) != "a" {
+		t.Errorf("unexpected pairs: %v %v", kvps, err)
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "out", "golden.json")
+	t.Setenv(UpdateEnv, "1")
+	AssertGolden(t, name, map[string]int{"a": 1})
+	t.Setenv(UpdateEnv, "")
+	AssertGolden(t, name, map[string]int{"a": 1})
+}
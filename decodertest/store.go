@@ -0,0 +1,191 @@
+package decodertest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// defaultWaitTime is how long a blocking query waits for a change when the
+// query doesn't say, as with consul.
+const defaultWaitTime = 5 * time.Minute
+
+// Store - an in-memory stand in for the KV store of a consul agent, for
+// testing code which fetches and watches configuration.  Its Get and List
+// methods have the signatures of those of *api.KV, so code written against
+// an interface of them can be given either, and honor blocking queries: a
+// query with a WaitIndex at or beyond the index of the store waits until the
+// index is bumped, the WaitTime elapses or the context of the query is
+// done.  Every change bumps the index, and Bump does so without a change, as
+// consul sometimes does.  A Store is safe for concurrent use.
+type Store struct {
+	mu      sync.Mutex
+	index   uint64
+	pairs   map[string]*api.KVPair
+	latency time.Duration
+
+	// changed is closed and replaced whenever the index is bumped, waking
+	// any blocked queries.
+	changed chan struct{}
+}
+
+// NewStore - returns a store holding copies of kvps, at index 1.
+func NewStore(kvps api.KVPairs) *Store {
+	s := &Store{
+		index:   1,
+		pairs:   make(map[string]*api.KVPair, len(kvps)),
+		changed: make(chan struct{}),
+	}
+	for _, kvp := range kvps {
+		s.putLocked(kvp)
+	}
+	return s
+}
+
+// SetLatency - makes every query take at least d, as a query of an agent
+// over the network would.
+func (s *Store) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Index - returns the current index of the store.
+func (s *Store) Index() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.index
+}
+
+// Put - writes copies of kvps to the store, all at the next index.
+func (s *Store) Put(kvps ...*api.KVPair) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index++
+	for _, kvp := range kvps {
+		s.putLocked(kvp)
+	}
+	s.notifyLocked()
+}
+
+// Delete - removes the pairs with the given keys, at the next index.
+func (s *Store) Delete(keys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index++
+	for _, key := range keys {
+		delete(s.pairs, key)
+	}
+	s.notifyLocked()
+}
+
+// Bump - moves the store to the next index without changing anything.
+func (s *Store) Bump() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index++
+	s.notifyLocked()
+}
+
+// Get - returns a copy of the pair with the given key, or nil if there is
+// none, as (*api.KV).Get does.
+func (s *Store) Get(key string, q *api.QueryOptions) (*api.KVPair, *api.QueryMeta, error) {
+	var kvp *api.KVPair
+	qm, err := s.query(q, func() {
+		if p, ok := s.pairs[key]; ok {
+			cp := *p
+			kvp = &cp
+		}
+	})
+	return kvp, qm, err
+}
+
+// List - returns copies of the pairs with keys beginning with prefix,
+// sorted by key, as (*api.KV).List does.
+func (s *Store) List(prefix string, q *api.QueryOptions) (api.KVPairs, *api.QueryMeta, error) {
+	var kvps api.KVPairs
+	qm, err := s.query(q, func() {
+		for key, p := range s.pairs {
+			if strings.HasPrefix(key, prefix) {
+				cp := *p
+				kvps = append(kvps, &cp)
+			}
+		}
+		sort.Slice(kvps, func(i, j int) bool { return kvps[i].Key < kvps[j].Key })
+	})
+	return kvps, qm, err
+}
+
+// query waits as q asks, then calls read with the store locked.
+func (s *Store) query(q *api.QueryOptions, read func()) (*api.QueryMeta, error) {
+	if q == nil {
+		q = &api.QueryOptions{}
+	}
+	ctx := q.Context()
+	start := time.Now()
+
+	s.mu.Lock()
+	latency := s.latency
+	if q.WaitIndex > 0 && q.WaitIndex >= s.index {
+		wait := q.WaitTime
+		if wait <= 0 {
+			wait = defaultWaitTime
+		}
+		changed := s.changed
+		s.mu.Unlock()
+		timer := time.NewTimer(wait)
+		select {
+		case <-changed:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		timer.Stop()
+		s.mu.Lock()
+	}
+	read()
+	index := s.index
+	s.mu.Unlock()
+
+	if err := sleep(ctx, latency); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &api.QueryMeta{LastIndex: index, RequestTime: time.Since(start)}, nil
+}
+
+func (s *Store) putLocked(kvp *api.KVPair) {
+	cp := *kvp
+	cp.ModifyIndex = s.index
+	if old, ok := s.pairs[kvp.Key]; ok {
+		cp.CreateIndex = old.CreateIndex
+	} else {
+		cp.CreateIndex = s.index
+	}
+	s.pairs[kvp.Key] = &cp
+}
+
+func (s *Store) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,80 @@
+package decodertest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	decoder "github.com/myENA/consul-decoder"
+)
+
+// kv is the part of *api.KV used by the code under test.
+type kv interface {
+	List(prefix string, q *api.QueryOptions) (api.KVPairs, *api.QueryMeta, error)
+}
+
+var _ kv = (*api.KV)(nil)
+var _ kv = (*Store)(nil)
+
+func TestStore(t *testing.T) {
+	s := NewStore(PairsFromMap("app", map[string]string{"name": "svc", "db/port": "5432"}))
+	var store kv = s
+
+	kvps, qm, err := store.List("app/", nil)
+	if err != nil || len(kvps) != 2 || qm.LastIndex != 1 {
+		t.Fatalf("unexpected list: %v %+v %v", kvps, qm, err)
+	}
+	cfg := &tbConfig{}
+	if err := decoder.Unmarshal("app", kvps, cfg); err != nil || cfg.DB.Port != 5432 {
+		t.Fatalf("unexpected decode: %+v %v", cfg, err)
+	}
+
+	// A blocking query returns once the index is bumped.
+	done := make(chan uint64)
+	go func() {
+		_, qm, err := store.List("app/", &api.QueryOptions{WaitIndex: 1})
+		if err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		done <- qm.LastIndex
+	}()
+	select {
+	case <-done:
+		t.Fatal("expected the query to block")
+	case <-time.After(20 * time.Millisecond):
+	}
+	s.Put(&api.KVPair{Key: "app/name", Value: []byte("renamed")})
+	if index := <-done; index != 2 {
+		t.Errorf("expected index 2, got %d", index)
+	}
+
+	kvp, _, err := s.Get("app/name", nil)
+	if err != nil || string(kvp.Value) != "renamed" || kvp.CreateIndex != 1 || kvp.ModifyIndex != 2 {
+		t.Errorf("unexpected pair: %+v %v", kvp, err)
+	}
+	s.Delete("app/name")
+	if kvp, qm, _ := s.Get("app/name", nil); kvp != nil || qm.LastIndex != 3 {
+		t.Errorf("expected deleted pair, got %+v at %d", kvp, qm.LastIndex)
+	}
+	s.Bump()
+	if s.Index() != 4 {
+		t.Errorf("expected index 4, got %d", s.Index())
+	}
+
+	// Blocking queries time out, and give up with their context.
+	_, qm, err = s.List("app/", &api.QueryOptions{WaitIndex: 4, WaitTime: 10 * time.Millisecond})
+	if err != nil || qm.LastIndex != 4 {
+		t.Errorf("expected a timed out query, got %+v %v", qm, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := s.List("app/", (&api.QueryOptions{WaitIndex: 4}).WithContext(ctx)); err != context.Canceled {
+		t.Errorf("expected context error, got %v", err)
+	}
+
+	s.SetLatency(20 * time.Millisecond)
+	if _, qm, _ := s.List("app/", nil); qm.RequestTime < 20*time.Millisecond {
+		t.Errorf("expected latency, got %s", qm.RequestTime)
+	}
+}
@@ -0,0 +1,117 @@
+package decoder
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldDescription - describes a field of a struct, and the key it is read
+// from, as worked out by a decoder.
+type FieldDescription struct {
+	// Key is the key of the field, relative to the folder of the struct
+	// holding it, as derived from the struct tags, in the case it was
+	// given.  Keys are matched case insensitively unless the decoder is
+	// CaseSensitive.
+	Key string
+	// Name is the name of the field, with the names of the structs it is
+	// nested in, as in "DB.Host".
+	Name string
+	// Index is the path to the field, as taken by reflect.Type.FieldByIndex.
+	// Any pointers to the structs it is nested in are followed, and are
+	// allocated by the decoder when the field is set.
+	Index []int
+	// Type is the type of the field.
+	Type reflect.Type
+	// Modifiers are the modifiers in the struct tag of the field, as in
+	// ["csv", "sep=;"].
+	Modifiers []string
+	// Secret is set for fields with the secret or encrypted modifiers,
+	// whose values are redacted, as are those of the fields nested in them.
+	Secret bool
+	// Folder is set for maps and slices read from the keys in the folder
+	// Key, each of which is an element, rather than from Key itself.
+	Folder bool
+	// Fields describes the fields of the structs that are the elements of
+	// a Folder, whose keys are relative to the folder of each element.  It
+	// is nil for the structs already being described, so that types which
+	// contain themselves are only described once.
+	Fields FieldDescriptions
+}
+
+// FieldDescriptions - the descriptions of the fields of a struct, in order
+// of key.
+type FieldDescriptions []FieldDescription
+
+// Describe - uses the default decoder to describe the fields of a struct.
+// See Decoder.Describe.
+func Describe(v interface{}) (FieldDescriptions, error) {
+	return defaultDecoder.Describe(v)
+}
+
+// Describe - returns the descriptions of the fields that would be read into
+// v, which is a struct, a pointer to one, or its reflect.Type, so that tools
+// such as generators of documentation and linters needn't work them out for
+// themselves.  Fields with the decodedat, lastindex or exists modifiers,
+// which aren't read from keys, are left out.  The metadata is kept in the
+// cache of the decoder, as with Precompile.
+func (d *Decoder) Describe(v interface{}) (FieldDescriptions, error) {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, InvalidValueErr
+	}
+	return d.describe(t, make(map[reflect.Type]bool))
+}
+
+// describe returns the descriptions of the fields of t.  stack holds the
+// structs being described.
+func (d *Decoder) describe(t reflect.Type, stack map[reflect.Type]bool) (FieldDescriptions, error) {
+	meta, _, err := d.typeCache().tMeta(d, t, true)
+	if err != nil {
+		return nil, err
+	}
+	stack[t] = true
+	defer delete(stack, t)
+
+	tag := d.structTag()
+	fds := make(FieldDescriptions, 0, len(meta.tFieldsMetaMap))
+	for _, tfm := range meta.tFieldsMetaMap {
+		if tfm.tlsPart != "" {
+			continue
+		}
+		fi := fieldInfo(tfm, tfm.fieldName, reflect.Zero(t))
+		fd := FieldDescription{
+			Key:    tfm.name,
+			Name:   fi.Name,
+			Index:  make([]int, 0, len(tfm.locators)),
+			Type:   fi.Type,
+			Secret: tfm.secret,
+		}
+		for _, loc := range tfm.locators {
+			fd.Index = append(fd.Index, loc.ind)
+		}
+		if _, modifiers, ok := strings.Cut(structField(t, tfm).Tag.Get(tag), ","); ok {
+			for _, m := range strings.Split(modifiers, ",") {
+				if m != "" {
+					fd.Modifiers = append(fd.Modifiers, m)
+				}
+			}
+		}
+		loc := tfm.locators[len(tfm.locators)-1]
+		fd.Folder = (loc.isMap || (loc.isSlice && tfm.isNotSpecial())) && !loc.isEncoded
+		if fd.Folder && tfm.nestsStruct() && !stack[loc.ttype] {
+			if fd.Fields, err = d.describe(loc.ttype, stack); err != nil {
+				return nil, err
+			}
+		}
+		fds = append(fds, fd)
+	}
+	sort.Slice(fds, func(i, j int) bool { return fds[i].Key < fds[j].Key })
+	return fds, nil
+}
@@ -0,0 +1,65 @@
+package decoder
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type tbDescribeNode struct {
+	Name     string
+	Children map[string]tbDescribeNode
+}
+
+type tbDescribe struct {
+	Timeout time.Duration `decoder:"Timeout,min=1s"`
+	Hosts   []string      `decoder:"hosts,csv,sep=;"`
+	DB      *struct {
+		Password string `decoder:"password,secret"`
+	} `decoder:"db"`
+	Nodes     []tbDescribeNode
+	Refreshed time.Time `decoder:",decodedat"`
+}
+
+func TestDescribe(t *testing.T) {
+	fds, err := Describe(&tbDescribe{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var keys []string
+	for _, fd := range fds {
+		keys = append(keys, fd.Key)
+	}
+	if want := []string{"Nodes", "Timeout", "db/password", "hosts"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("expected keys %q, got %q", want, keys)
+	}
+
+	nodes, timeout, password, hosts := fds[0], fds[1], fds[2], fds[3]
+	if timeout.Type != reflect.TypeOf(time.Duration(0)) || !reflect.DeepEqual(timeout.Modifiers, []string{"min=1s"}) {
+		t.Errorf("unexpected description of Timeout: %+v", timeout)
+	}
+	if password.Name != "DB.Password" || !reflect.DeepEqual(password.Index, []int{2, 0}) || !password.Secret {
+		t.Errorf("unexpected description of DB.Password: %+v", password)
+	}
+	if hosts.Folder || !reflect.DeepEqual(hosts.Modifiers, []string{"csv", "sep=;"}) {
+		t.Errorf("expected hosts to be read from one key, got %+v", hosts)
+	}
+
+	// The nodes contain themselves, so their children are only described
+	// once.
+	if !nodes.Folder || len(nodes.Fields) != 2 {
+		t.Fatalf("expected nodes to be a folder of 2 fields, got %+v", nodes)
+	}
+	children := nodes.Fields[0]
+	if children.Key != "Children" || !children.Folder || children.Fields != nil {
+		t.Errorf("unexpected description of the children of nodes: %+v", children)
+	}
+
+	byType, err := Describe(reflect.TypeOf(tbDescribe{}))
+	if err != nil || !reflect.DeepEqual(byType, fds) {
+		t.Errorf("expected the same descriptions from the type, got %v", err)
+	}
+	if _, err := Describe(map[string]string{}); err != InvalidValueErr {
+		t.Errorf("expected InvalidValueErr, got %v", err)
+	}
+}
@@ -10,22 +10,94 @@
 //
 //     bool
 //
+//     json.Number - validated as a json number, but otherwise kept as is.
+//
+//     decimals - big.Rat, and Decimal from github.com/shopspring/decimal,
+//                are read exactly by way of UnmarshalText, so amounts of
+//                money needn't pass through float64.
+//
+//     interface{} - the value as a string or, with Decoder.InferTypes, as a
+//                   bool, int64, float64 or string, whichever it looks like.
+//                   With the json modifier, whatever json.Unmarshal makes
+//                   of it.
+//
 //     time.Duration
 //
+//     os.FileMode - read as an octal number, as in 0644.
+//
 //     net.IP
 //
 //     net.IPMask
 //
+//     net.HardwareAddr - read with net.ParseMAC.
+//
+//     *x509.Certificate - read from a PEM encoded value.
+//
+//     []*x509.Certificate - a certificate chain, read from a single value
+//                           holding the PEM encoded certificates in order.
+//
+//     tls.Certificate - read either from a single value holding the PEM
+//                       encoded certificate chain and private key, or from
+//                       a folder with the subkeys "cert" and "key" holding
+//                       them separately.
+//
+//     *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey - read from a PEM
+//                       or base64 encoded DER value, in PKIX or PKCS #1
+//                       form.  The key of a PEM encoded certificate is also
+//                       accepted.
+//
+//     *net.TCPAddr, *net.UDPAddr - read from "host:port" values, resolving
+//                                  the host if it is a name.
+//
+//     HostPort - a "host:port" value split into its parts, without resolving
+//                the host.
+//
 //     struct - nested struct by default implies a consul folder with the same name.
 //              if the tag modifier "json" is encountered, then the value of in the KV
 //              is unmarshaled as json using json.Unmarshal
 //
 //     slice - the type can be most of the supported types, except another slice.
+//             [][]byte is the exception, with each element the raw value
+//             of its key, as are the values of a map[string][]byte.
+//             Elements are appended in the order of their keys, unless
+//             Decoder.SliceIndex says to take the keys as indices.
 //
 //     map - the key must be a string, the value can be anything but another map.
+//           Keys nested more than one level below a map of values are read
+//           into the element named by their first folder, unless
+//           Decoder.MapDepth says otherwise.
 //
 //     encoding.TextUnmarshaler - any type that implements this will have its
 //                                UnmarshalText() method called.
+//                                This is how third party types are
+//                                supported.  For example, *version.Version
+//                                from github.com/hashicorp/go-version and
+//                                *semver.Version from
+//                                github.com/Masterminds/semver/v3 both
+//                                implement it, so a minimum version kept in
+//                                consul is validated when it is decoded.
+//                                Types that don't, such as version
+//                                constraints, can be supported with
+//                                Decoder.RegisterModifier.
+//
+//     api.KVPair, *api.KVPair - given a copy of the whole pair read from
+//                               the key, with its indexes, flags and
+//                               session as well as its raw value, for code
+//                               that needs them for check-and-set or
+//                               locking.  Such fields can't be in maps or
+//                               slices.
+//
+//     sync/atomic types - atomic.Bool, atomic.Int64 and friends, and
+//                         atomic.Pointer[T] where T is one of the above scalar
+//                         types or a TextUnmarshaler, or any type with the
+//                         json modifier.  Values are set with Store(), so
+//                         they can be read without locking while being
+//                         updated by a later decode.
+//
+// Defined types, such as `type Port int` or `type Hosts []string`, are
+// decoded according to their kind, wherever they are declared.  Only the
+// types named above are matched by name, so a type defined in terms of one of
+// them, as in `type Timeout time.Duration`, is decoded as its kind would be.
 //
 // Struct tags
 //
@@ -40,8 +112,34 @@
 // signals that the value is to be interpreted as json and unmarshaled rather
 // than interpreted.  Similarly, the modififier ",csv" allows comma separated
 // values to be read into a slice, and ",ssv" allows space separated values
-// to be read intoa  slice.  For csv and ssv, slices of string, numeric and
-// boolean are supported.
+// to be read intoa  slice.  For csv and ssv, slices of string, numeric,
+// boolean, time.Duration, net.IP and TextUnmarshaler types are supported.
+// They may also be used with a map of slices, as in map[string][]string, in
+// which case each value of the map is read from a single key in the folder.
+// Modifiers of different kinds combine: transformers such as base64 are
+// applied first, in order, then the value is trimmed, then split by csv or
+// ssv or decoded by an encoding such as json.  So ",base64,json" reads base64
+// wrapped json.  Modifiers of the same kind, such as csv and ssv, or an
+// encoding and csv, can't be combined, and are an error.  The json modifier
+// may be used with fields of any type, including strings, numbers and
+// pointers to them, in which case the value must be json, as in "\"text\""
+// for a string, and is passed to json.Unmarshal as it is.  Modifiers which
+// aren't known, such as a misspelt ",jsno", are warned about in the Report,
+// or fail the decode and Precompile if Decoder.UnknownModifiers is
+// IssueError.
+//
+// Embedded structs which are exported are treated like any other struct
+// field, so are read from a folder named after the type.  The exported
+// fields of embedded structs which are unexported are promoted, as with
+// encoding/json, so are read from the same folder as the fields of the outer
+// struct, which take precedence.  Giving an unexported embedded struct a name
+// in its tag makes it a folder instead.  Embedded interfaces are skipped.
+//
+// With Decoder.Mapstructure set, the "mapstructure" tags of structs tagged
+// for github.com/mitchellh/mapstructure are read, ignoring omitempty.  The
+// squash modifier promotes the fields of a struct, as if it were embedded
+// and unexported, and the remain modifier gives a map the values of the keys
+// which no other field is read from.  Both work with the decoder tag too.
 //
 //     struct Foo {
 //
@@ -61,7 +159,8 @@
 //
 //         // this looks for a folder named FooField4 (case insensitive)
 //         // this is similar to the map example above, but it ignores the keys
-//         // inside and maps the values in-order into the slice.  nested slices
+//         // inside and maps the values into the slice in the order of their keys,
+//         // as consul returns them, whatever order they are given in.  nested slices
 //         // are not allowed, i.e., [][]string.
 //         FooField4 []string
 //
@@ -85,5 +184,191 @@
 //          // for parsing, so see that documentation for information.
 //          FooField9 []string `decoder:",ssv"`
 //
+//          // Surrounding whitespace, such as the trailing newline often
+//          // left by editing values in the consul UI, can be trimmed before
+//          // the value is parsed.
+//          FooField10 int `decoder:",trim"`
+//
+//          // Values can be restricted to a set of permitted values, separated
+//          // by "|".  This works for strings, bools, numbers and durations.
+//          FooField11 string `decoder:",oneof=debug|info|warn"`
+//
+//          // Numbers and durations can be restricted to a range, with either
+//          // or both of min and max.
+//          FooField12 int `decoder:",min=1,max=65535"`
+//
+//          // Values serialized with MessagePack are decoded much like json.
+//          FooField13 *SomeStruct `decoder:",msgpack"`
+//
+//          // Transformers are applied to the raw value, left to right, before it
+//          // is interpreted.  base64, gzip and hex are built in, and more can be
+//          // added with Decoder.RegisterTransformer.
+//          FooField14 []byte `decoder:",base64,gzip"`
+//
+//          // Applications can decode their own formats by registering a
+//          // modifier with Decoder.RegisterModifier.  The field is handed to the
+//          // modifier whole, as with json.
+//          FooField15 []*Key `decoder:",jwks"`
+//
+//          // Some modifiers take a parameter, which affects how the value is
+//          // parsed.  layout gives the time.Parse layout of a time.Time, or the
+//          // name of one of the layouts of the time package.  base gives the base
+//          // of an integer, and applies to min and max too.  sep replaces the
+//          // comma used by csv.
+//          FooField16 time.Time `decoder:",layout=RFC1123Z"`
+//          FooField17 uint32    `decoder:",base=8"`
+//          FooField18 []string  `decoder:",csv,sep=;"`
+//
+//          // Values of fields with the secret modifier, or of the fields of
+//          // structs with it, are redacted before they are given to
+//          // Decoder.OnAssign.
+//          FooField19 string `decoder:",secret"`
+//
+//          // Fields may describe the decode rather than be read from a key.
+//          // decodedat gives the time.Time the decode started, and lastindex
+//          // the highest ModifyIndex of the keys under the prefix, or under
+//          // the folder of the element for structs inside of maps and slices.
+//          FooField20 time.Time `decoder:",decodedat"`
+//          FooField21 uint64    `decoder:",lastindex"`
+//
+//          // With the service modifier, the value is the name of a service,
+//          // which is resolved to the "host:port" of a healthy instance by
+//          // Decoder.ServiceResolver.  HealthServiceResolver asks consul.  The
+//          // value may also select instances, as in
+//          // consul-service://redis?tag=primary&dc=east, and values of that form
+//          // are resolved for any field while a ServiceResolver is set.
+//          FooField22 string `decoder:",service"`
+//
+//          // With the encrypted modifier, the value is decrypted by
+//          // Decoder.Decryptor, in its place among the transformers, so a value
+//          // encrypted with AESGCM and kept as base64 is read with
+//          // base64,encrypted.  Encrypted fields are secret too.
+//          FooField23 string `decoder:",base64,encrypted"`
+//
+//          // duration gives the unit of a number holding a duration, so that
+//          // "2s" is read into it as 2000 with duration=ms.  Bare numbers are
+//          // read as they are, and into a time.Duration as that many of the
+//          // unit.  The units are ns, us, ms, s, m and h.
+//          FooField24 int64 `decoder:",duration=ms"`
+//
+//          // exists sets a bool to whether its key, or a folder of that name,
+//          // is there at all, whatever its value, so that feature flags can be
+//          // toggled by creating and deleting keys.
+//          FooField25 bool `decoder:"new-checkout,exists"`
+//
 //    }
+//
+// Maps and slices
+//
+// A pointer to a map or slice may be passed to Unmarshal in place of a
+// pointer to a struct, in which case the keys below the prefix are decoded
+// as if the prefix were the folder of a map or slice field.  This is handy
+// for small tools which want a whole folder as a map[string]string, without
+// defining a struct.  An empty prefix is the root of the tree, so that every
+// key is decoded, where it used to match none.  UnmarshalMulti decodes several folders of one List
+// into several targets.
+//
+// If ReuseExisting is set on the Decoder, the structs already held by maps,
+// and values with the json or msgpack modifiers, are decoded into rather
+// than replaced, so that state they hold survives a reload.
+//
+// Feature flags
+//
+// FeatureFlags holds a set of flags read from a folder with a key per flag.
+// Update replaces them with those in the latest List of the folder, telling
+// subscribers which changed, and Enabled reports whether a flag is on, by
+// its key or by default.
+//
+// Snapshots
+//
+// A SnapshotStore, such as FileSnapshotStore, keeps the pairs of the last
+// configuration that decoded successfully, and UnmarshalSnapshot decodes
+// them, so that a service can boot when consul can't be reached.
+//
+// Viper and koanf
+//
+// A Provider exposes the pairs below a prefix as a tree of maps keyed by
+// their folders, with the Read, ReadBytes and Watch methods of a koanf
+// provider, so that code reading its configuration through koanf or viper
+// can be given pairs fetched from consul.  Update replaces the pairs and
+// tells watchers to read them again.  Pairs are picked, and their values
+// read, as they are for the string fields of Unmarshal.
+//
+// Flag hints
+//
+// If UseFlags is set on the Decoder, the Flags field of each KVPair is
+// honored as a set of encoding hints.  FlagBase64 and FlagGzip cause the
+// value to be decoded and decompressed respectively before being interpreted,
+// and FlagJSON causes the value to be passed to json.Unmarshal, as if the
+// field had been tagged with the ",json" modifier.  This allows the writer of
+// the data to control its interpretation.
+//
+// Pair hooks
+//
+// Where the value alone isn't enough to decide how a pair is treated, a
+// PairHook may be set on the Decoder.  It is called with each pair and a
+// FieldInfo describing the field it is bound for, before flag hints and
+// transformers are applied, and returns the value to decode in its place.
+// Returning SkipPairErr skips the pair, so that, for instance, keys held by a
+// lock session can be ignored.
+//
+// Middleware
+//
+// Cross-cutting concerns, such as tracing, caching or rewriting pairs, can
+// wrap every decode by adding a Middleware with Use.  Each is given the rest
+// of the chain as an UnmarshalFunc, which it may call with other arguments,
+// or not at all.
+//
+// Environments
+//
+// If Environment is set on the Decoder, keys and folders qualified by it, as
+// in timeout@staging, are decoded in place of the unqualified ones, and keys
+// qualified by other environments are skipped.  Qualifiers adds more
+// specific qualifiers, such as an instance ID or region, most specific
+// first, which are preferred over the environment.
+//
+// Locks
+//
+// Keys held by a session, such as lock and leader keys, are decoded like any
+// other, unless Sessions is set on the Decoder to SessionWarn, which adds a
+// warning to the Report for each, or SessionSkip, which skips them.
+//
+// Hidden keys
+//
+// If IgnoreHidden is set on the Decoder, keys which begin with ".", or are in
+// a folder which does, are skipped.  The patterns of ExcludeKeys and
+// IncludeKeys match at any depth when they begin with "**/", as in
+// "**/.lock".
+//
+// Templates
+//
+// If Templates is set on the Decoder, values may hold the {{ }} actions of
+// consul-template, as in {{ env "PORT" | default "8080" }}, which are
+// expanded before they're parsed.  Only the env, envOrDefault and default
+// functions are supported.
+//
+// Dumping
+//
+// DumpJSON and DumpYAML serialize a struct the other way, naming its values
+// after the keys they're read from rather than by json tags, and giving them
+// in the form they'd be written to consul in.  Fields with the secret
+// modifier, and private keys, are redacted, so the effective configuration
+// can be logged or served as operators know it.
+//
+// MarkdownDoc describes the keys a struct is read from as a markdown table,
+// with their types, defaults, modifiers and the descriptions given in the
+// "doc" struct tag.  Describe returns the keys, names, types and modifiers
+// of the fields of a struct, for tools to build on.
+//
+// Linting
+//
+// The decoderlint module has an analyzer, which may be run with go vet, that
+// checks decoder struct tags for unknown modifiers, types which can't be
+// decoded into and keys which are the same once case is folded.
+//
+// Importing
+//
+// ImportEnv and ImportProperties read .env and properties files into the
+// pairs a struct would read them from, matching their names to its keys, so
+// that legacy configuration can be written to consul.
 package decoder
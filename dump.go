@@ -0,0 +1,367 @@
+package decoder
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hashicorp/consul/api"
+	"gopkg.in/yaml.v3"
+)
+
+var textMarshalerType = reflect.TypeOf(new(encoding.TextMarshaler)).Elem()
+
+// DumpJSON - uses the default decoder to serialize v as indented json.  See
+// Decoder.DumpJSON.
+func DumpJSON(v interface{}) ([]byte, error) {
+	return defaultDecoder.DumpJSON(v)
+}
+
+// DumpJSON - serializes v, a pointer to anything that may be passed to
+// Unmarshal, as indented json, with objects named after the keys the decoder
+// reads its fields from, rather than by json tags.  Values are given in the
+// form they'd be written to consul in, such as "30s" for a time.Duration,
+// and the values of fields with the secret modifier, as well as private keys,
+// are replaced with RedactedValue.  This is meant for showing the effective
+// configuration, in terms of the keys operators know.
+func (d *Decoder) DumpJSON(v interface{}) ([]byte, error) {
+	tree, err := d.dump(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(tree, "", "  ")
+}
+
+// DumpYAML - uses the default decoder to serialize v as yaml.  See
+// Decoder.DumpYAML.
+func DumpYAML(v interface{}) ([]byte, error) {
+	return defaultDecoder.DumpYAML(v)
+}
+
+// DumpYAML - this is the same as DumpJSON, but serializes v as yaml.
+func (d *Decoder) DumpYAML(v interface{}) ([]byte, error) {
+	tree, err := d.dump(v)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(tree)
+}
+
+// dump returns the tree of maps, slices and values that v is serialized as.
+func (d *Decoder) dump(v interface{}) (interface{}, error) {
+	valp := reflect.ValueOf(v)
+	if valp.Kind() != reflect.Ptr || valp.IsNil() {
+		return nil, InvalidValueErr
+	}
+	val := valp.Elem()
+	switch val.Kind() {
+	case reflect.Struct:
+		return d.dumpStruct(val)
+	case reflect.Map, reflect.Slice:
+		meta, _, err := d.typeCache().collectionMeta(d, val.Type())
+		if err != nil {
+			return nil, err
+		}
+		tfm := meta.tFieldsMetaMap[""]
+		tree, ok, err := d.dumpField(tfm, val)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return tree, nil
+	}
+	return nil, InvalidValueErr
+}
+
+// dumpStruct returns the fields of val, a struct, as a tree of maps keyed
+// by the parts of their keys.
+func (d *Decoder) dumpStruct(val reflect.Value) (map[string]interface{}, error) {
+	meta, _, err := d.typeCache().tMeta(d, val.Type(), true)
+	if err != nil {
+		return nil, err
+	}
+	tfms := make([]*tFieldMeta, 0, len(meta.tFieldsMetaMap))
+	for _, tfm := range meta.tFieldsMetaMap {
+		if tfm.tlsPart == "" {
+			tfms = append(tfms, tfm)
+		}
+	}
+	sort.Slice(tfms, func(i, j int) bool { return tfms[i].name < tfms[j].name })
+
+	tree := make(map[string]interface{})
+	for _, tfm := range tfms {
+		fv, ok := lookupField(tfm, val)
+		if !ok {
+			continue
+		}
+		value, ok, err := d.dumpField(tfm, fv)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", tfm.name, err)
+		}
+		if !ok {
+			continue
+		}
+		if err := insertDumped(tree, tfm.name, value); err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
+
+// insertDumped adds value to tree at the folders of name.
+func insertDumped(tree map[string]interface{}, name string, value interface{}) error {
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	for _, part := range parts[:len(parts)-1] {
+		switch sub := tree[part].(type) {
+		case nil:
+			folder := make(map[string]interface{})
+			tree[part] = folder
+			tree = folder
+		case map[string]interface{}:
+			tree = sub
+		default:
+			return fmt.Errorf("%s: key is both a value and a folder", name)
+		}
+	}
+	last := parts[len(parts)-1]
+	if _, ok := tree[last]; ok {
+		return fmt.Errorf("%s: key is both a value and a folder", name)
+	}
+	tree[last] = value
+	return nil
+}
+
+// dumpField returns the value of fv, the field tfm, as it is serialized,
+// or false if the field is a nil pointer, map or slice, and is left out.
+func (d *Decoder) dumpField(tfm *tFieldMeta, fv reflect.Value) (interface{}, bool, error) {
+	fv, ok := indirect(fv)
+	if !ok {
+		return nil, false, nil
+	}
+	if tfm.secret {
+		return RedactedValue, true, nil
+	}
+	loc := tfm.locators[len(tfm.locators)-1]
+	switch {
+	case loc.isEncoded:
+		value, err := dumpEncoded(fv)
+		return value, err == nil, err
+	case tfm.computedType == typeAtomic:
+		value, ok := indirect(fv.Addr().MethodByName("Load").Call(nil)[0])
+		if !ok {
+			return nil, false, nil
+		}
+		dumped, err := d.dumpElem(tfm, tfm.atomicComputedType, value)
+		return dumped, err == nil, err
+	case loc.isMap:
+		if fv.IsNil() {
+			return nil, false, nil
+		}
+		m := make(map[string]interface{}, fv.Len())
+		iter := fv.MapRange()
+		for iter.Next() {
+			ev, ok := indirect(iter.Value())
+			if !ok {
+				continue
+			}
+			var value interface{}
+			var err error
+			if loc.isSlice {
+				value, err = dumpSeparated(tfm, ev)
+			} else {
+				value, err = d.dumpElem(tfm, tfm.computedType, ev)
+			}
+			if err != nil {
+				return nil, false, err
+			}
+			m[iter.Key().String()] = value
+		}
+		return m, true, nil
+	case loc.isSlice:
+		if fv.IsNil() {
+			return nil, false, nil
+		}
+		if tfm.isSpecial() {
+			value, err := dumpSeparated(tfm, fv)
+			return value, err == nil, err
+		}
+		s := make([]interface{}, 0, fv.Len())
+		for i := 0; i < fv.Len(); i++ {
+			ev, ok := indirect(fv.Index(i))
+			if !ok {
+				continue
+			}
+			value, err := d.dumpElem(tfm, tfm.computedType, ev)
+			if err != nil {
+				return nil, false, err
+			}
+			s = append(s, value)
+		}
+		return s, true, nil
+	}
+	value, err := d.dumpElem(tfm, tfm.computedType, fv)
+	return value, err == nil, err
+}
+
+// dumpElem returns v, a single value of the field tfm, as it is serialized.
+func (d *Decoder) dumpElem(tfm *tFieldMeta, ct computedType, v reflect.Value) (interface{}, error) {
+	if tfm.nestsStruct() {
+		return d.dumpStruct(v)
+	}
+	return dumpScalar(tfm.params, ct, v)
+}
+
+// dumpSeparated returns v, a slice of a field with the csv or ssv modifier,
+// as the single value it is read from.
+func dumpSeparated(tfm *tFieldMeta, v reflect.Value) (string, error) {
+	fields := make([]string, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		ev, ok := indirect(v.Index(i))
+		if !ok {
+			continue
+		}
+		value, err := dumpScalar(tfm.params, tfm.computedType, ev)
+		if err != nil {
+			return "", err
+		}
+		fields = append(fields, fmt.Sprint(value))
+	}
+	if tfm.isSSV() {
+		return strings.Join(fields, " "), nil
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if sep, ok := tfm.params[paramSep]; ok {
+		w.Comma, _ = utf8.DecodeRuneInString(sep)
+	}
+	if err := w.Write(fields); err != nil {
+		return "", err
+	}
+	w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n"), w.Error()
+}
+
+// dumpEncoded returns v, the value of a field with the json modifier or one
+// like it, as the tree encoding/json makes of it, so that it is serialized
+// the same way whatever the format.
+func dumpEncoded(v reflect.Value) (interface{}, error) {
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+	var tree interface{}
+	if err := json.Unmarshal(b, &tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// dumpScalar returns v, a value of the type ct, in the form it would be
+// written to consul in, where that isn't a plain string, number or bool.
+func dumpScalar(params map[string]string, ct computedType, v reflect.Value) (interface{}, error) {
+	switch ct {
+	case typeInt:
+		if base := paramBaseValue(params); base != 10 && base != 0 {
+			return strconv.FormatInt(v.Int(), base), nil
+		}
+		return v.Int(), nil
+	case typeUint:
+		if base := paramBaseValue(params); base != 10 && base != 0 {
+			return strconv.FormatUint(v.Uint(), base), nil
+		}
+		return v.Uint(), nil
+	case typeFloat:
+		return v.Float(), nil
+	case typeBool:
+		return v.Bool(), nil
+	case typeString, typeJSONNumber:
+		return v.String(), nil
+	case typeDuration:
+		return time.Duration(v.Int()).String(), nil
+	case typeFileMode:
+		return fmt.Sprintf("%#o", v.Uint()), nil
+	case typeTime:
+		return v.Interface().(time.Time).Format(paramLayoutValue(params)), nil
+	case typeByteSlice:
+		if utf8.Valid(v.Bytes()) {
+			return string(v.Bytes()), nil
+		}
+		return v.Bytes(), nil
+	case typeNetIP, typeNetMask:
+		if v.Len() == 0 {
+			return "", nil
+		}
+		return net.IP(v.Bytes()).String(), nil
+	case typeHardwareAddr:
+		return net.HardwareAddr(v.Bytes()).String(), nil
+	case typeTCPAddr, typeUDPAddr:
+		return addressable(v).Addr().Interface().(fmt.Stringer).String(), nil
+	case typeCertificate:
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: v.Interface().(x509.Certificate).Raw})), nil
+	case typeCertChain:
+		var buf bytes.Buffer
+		for i := 0; i < v.Len(); i++ {
+			if cv, ok := indirect(v.Index(i)); ok {
+				buf.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cv.Interface().(x509.Certificate).Raw}))
+			}
+		}
+		return buf.String(), nil
+	case typePair:
+		return string(v.Interface().(api.KVPair).Value), nil
+	case typeTLSCertificate:
+		// The private key is never shown.
+		return RedactedValue, nil
+	case typePublicKey:
+		key := v.Interface()
+		if v.Kind() == reflect.Struct {
+			key = addressable(v).Addr().Interface()
+		}
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+	case typeTextUnmarshaler:
+		if reflect.PtrTo(v.Type()).Implements(textMarshalerType) {
+			v = addressable(v).Addr()
+		}
+		if tm, ok := v.Interface().(encoding.TextMarshaler); ok {
+			text, err := tm.MarshalText()
+			return string(text), err
+		}
+	}
+	return v.Interface(), nil
+}
+
+// indirect follows the pointers and interfaces of v, returning false if
+// one of them is nil.
+func indirect(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, true
+}
+
+// addressable returns v, or a copy of it if it can't be addressed, as is
+// the case for the values of maps.
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+	cp := reflect.New(v.Type()).Elem()
+	cp.Set(v)
+	return cp
+}
@@ -0,0 +1,128 @@
+package decoder
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbDumpDB struct {
+	Host     string `decoder:"Host"`
+	Port     int
+	Password string `decoder:",secret"`
+}
+
+type tbDumpService struct {
+	Addr    net.IP
+	Weights []int
+}
+
+type tbDump struct {
+	Name     string        `decoder:"app/Name"`
+	Timeout  time.Duration `decoder:"timeout"`
+	Mode     uint32        `decoder:",base=8"`
+	DB       tbDumpDB      `decoder:"Database"`
+	Tags     []string      `decoder:",csv"`
+	Services map[string]*tbDumpService
+	Raw      struct {
+		Level int `json:"level"`
+	} `decoder:",json"`
+	Missing *tbDumpDB
+	Skipped string `decoder:"-"`
+}
+
+func testDump() *tbDump {
+	td := &tbDump{
+		Name:    "web",
+		Timeout: 30 * time.Second,
+		Mode:    0644,
+		DB:      tbDumpDB{Host: "db.local", Port: 5432, Password: "hunter2"},
+		Tags:    []string{"a", "b,c"},
+		Services: map[string]*tbDumpService{
+			"api": {Addr: net.ParseIP("10.0.0.1"), Weights: []int{1, 2}},
+		},
+		Skipped: "skipped",
+	}
+	td.Raw.Level = 3
+	return td
+}
+
+func TestDumpJSON(t *testing.T) {
+	b, err := DumpJSON(testDump())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := `{
+  "Database": {
+    "Host": "db.local",
+    "Password": "[redacted]",
+    "Port": 5432
+  },
+  "Mode": "644",
+  "Raw": {
+    "level": 3
+  },
+  "Services": {
+    "api": {
+      "Addr": "10.0.0.1",
+      "Weights": [
+        1,
+        2
+      ]
+    }
+  },
+  "Tags": "a,\"b,c\"",
+  "app": {
+    "Name": "web"
+  },
+  "timeout": "30s"
+}`
+	if string(b) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, b)
+	}
+}
+
+func TestDumpYAML(t *testing.T) {
+	b, err := DumpYAML(testDump())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, line := range []string{
+		"Database:\n    Host: db.local\n    Password: '[redacted]'\n    Port: 5432\n",
+		"timeout: 30s\n",
+		"Services:\n    api:\n        Addr: 10.0.0.1\n",
+	} {
+		if !strings.Contains(string(b), line) {
+			t.Errorf("expected yaml to contain %q, got:\n%s", line, b)
+		}
+	}
+	if strings.Contains(string(b), "hunter2") || strings.Contains(string(b), "skipped") {
+		t.Errorf("unexpected value in yaml:\n%s", b)
+	}
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	// A dump of a map holds the keys and values it was decoded from.
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/a", Value: []byte("1s")},
+		{Key: prefix + "/b", Value: []byte("1m0s")},
+	}
+	m := map[string]time.Duration{}
+	if err := Unmarshal(prefix, kvs, &m); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b, err := DumpJSON(&m)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "{\n  \"a\": \"1s\",\n  \"b\": \"1m0s\"\n}"; string(b) != want {
+		t.Errorf("expected %s, got %s", want, b)
+	}
+
+	if _, err := DumpJSON(tbDump{}); err != InvalidValueErr {
+		t.Errorf("expected InvalidValueErr, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package decoder
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// NoDecryptorErr - returned when a field has the encrypted modifier, but the
+// decoder has no Decryptor.
+var NoDecryptorErr = errors.New("no Decryptor set")
+
+// Decryptor - decrypts the values of fields with the encrypted modifier,
+// which were encrypted before being written to consul.  See AESGCM.
+type Decryptor interface {
+	Decrypt(value []byte) ([]byte, error)
+}
+
+// decrypt decrypts value with the decoder's Decryptor.
+func (d *Decoder) decrypt(value []byte) ([]byte, error) {
+	if d.Decryptor == nil {
+		return nil, NoDecryptorErr
+	}
+	return d.Decryptor.Decrypt(value)
+}
+
+// AESGCM - a Decryptor for values sealed with AES-GCM, stored as the nonce
+// followed by the ciphertext.  Encrypt seals values in that format, for
+// writing them to consul.  Values which are kept as text, such as with
+// base64, need the matching transformer before the modifier, as in
+// `decoder:",base64,encrypted"`.
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM - returns an AESGCM using key, which must be 16, 24 or 32 bytes
+// long, for AES-128, AES-192 or AES-256.
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCM{aead: aead}, nil
+}
+
+// Encrypt - seals value with a random nonce.
+func (a *AESGCM) Encrypt(value []byte) ([]byte, error) {
+	nonce := make([]byte, a.aead.NonceSize(), a.aead.NonceSize()+len(value)+a.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return a.aead.Seal(nonce, nonce, value, nil), nil
+}
+
+// Decrypt - opens a value sealed by Encrypt.
+func (a *AESGCM) Decrypt(value []byte) ([]byte, error) {
+	n := a.aead.NonceSize()
+	if len(value) < n+a.aead.Overhead() {
+		return nil, errors.New("value is too short to have been encrypted")
+	}
+	return a.aead.Open(nil, value[:n], value[n:], nil)
+}
@@ -0,0 +1,78 @@
+package decoder
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbEncrypted struct {
+	Password string            `decoder:",encrypted"`
+	Token    string            `decoder:",base64,encrypted"`
+	Keys     map[string]string `decoder:",base64,encrypted"`
+	DB       *tbMultiDB        `decoder:",base64,encrypted,json"`
+}
+
+func TestEncrypted(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	a, err := NewAESGCM(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	seal := func(s string, b64 bool) []byte {
+		b, err := a.Encrypt([]byte(s))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if b64 {
+			b = []byte(base64.StdEncoding.EncodeToString(b))
+		}
+		return b
+	}
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/password", Value: seal("hunter2", false)},
+		{Key: prefix + "/token", Value: seal("t0ken", true)},
+		{Key: prefix + "/keys/a", Value: seal("key-a", true)},
+		{Key: prefix + "/db", Value: seal(`{"Host":"db.local"}`, true)},
+	}
+
+	var assigned []interface{}
+	d := &Decoder{
+		Decryptor: a,
+		OnAssign:  func(key, field string, value interface{}) { assigned = append(assigned, value) },
+	}
+	te := &tbEncrypted{}
+	if err := d.Unmarshal(prefix, kvs, te); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if te.Password != "hunter2" || te.Token != "t0ken" || te.Keys["a"] != "key-a" {
+		t.Errorf("unexpected values: %+v", te)
+	}
+	if te.DB == nil || te.DB.Host != "db.local" {
+		t.Errorf("unexpected db: %+v", te.DB)
+	}
+	for _, v := range assigned {
+		if v != RedactedValue {
+			t.Errorf("expected encrypted values to be redacted, got %v", v)
+		}
+	}
+
+	err = Unmarshal(prefix, kvs[:1], &tbEncrypted{})
+	if !errors.Is(err, NoDecryptorErr) {
+		t.Errorf("expected %v, got %v", NoDecryptorErr, err)
+	}
+
+	other, _ := NewAESGCM([]byte("fedcba9876543210"))
+	d = &Decoder{Decryptor: other}
+	if err := d.Unmarshal(prefix, kvs[:1], &tbEncrypted{}); err == nil {
+		t.Error("expected error for the wrong key")
+	}
+	if _, err := a.Decrypt([]byte("short")); err == nil {
+		t.Error("expected error for a short value")
+	}
+	if _, err := NewAESGCM([]byte("bad")); err == nil {
+		t.Error("expected error for a bad key length")
+	}
+}
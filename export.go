@@ -0,0 +1,66 @@
+package decoder
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// exportedPair mirrors the entries written by `consul kv export`.
+type exportedPair struct {
+	Key   string `json:"key"`
+	Flags uint64 `json:"flags"`
+	Value string `json:"value"`
+}
+
+// ReadExport - reads the json array produced by `consul kv export` from r,
+// and returns the pairs sorted by key, the same as a List call against a
+// live agent would.
+func ReadExport(r io.Reader) (api.KVPairs, error) {
+	var exported []exportedPair
+	if err := json.NewDecoder(r).Decode(&exported); err != nil {
+		return nil, fmt.Errorf("unable to read export: %s", err)
+	}
+	kvps := make(api.KVPairs, 0, len(exported))
+	for _, ep := range exported {
+		value, err := base64.StdEncoding.DecodeString(ep.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for key %s: %s", ep.Key, err)
+		}
+		kvps = append(kvps, &api.KVPair{Key: ep.Key, Flags: ep.Flags, Value: value})
+	}
+	sort.Slice(kvps, func(i, j int) bool { return kvps[i].Key < kvps[j].Key })
+	return kvps, nil
+}
+
+// WriteExport - writes kvps to w as the json array produced by `consul kv
+// export`, so that it can be read by ReadExport or `consul kv import`.
+func WriteExport(w io.Writer, kvps api.KVPairs) error {
+	exported := make([]exportedPair, len(kvps))
+	for i, kvp := range kvps {
+		exported[i] = exportedPair{Key: kvp.Key, Flags: kvp.Flags, Value: base64.StdEncoding.EncodeToString(kvp.Value)}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(exported)
+}
+
+// UnmarshalExport - uses the default decoder to decode the values at
+// pathPrefix from the `consul kv export` output read from r into v.
+func UnmarshalExport(pathPrefix string, r io.Reader, v interface{}) error {
+	return defaultDecoder.UnmarshalExport(pathPrefix, r, v)
+}
+
+// UnmarshalExport - this is the UnmarshalExport method on a custom decoder.
+// Same as above otherwise.
+func (d *Decoder) UnmarshalExport(pathPrefix string, r io.Reader, v interface{}) error {
+	kvps, err := ReadExport(r)
+	if err != nil {
+		return err
+	}
+	return d.Unmarshal(pathPrefix, kvps, v)
+}
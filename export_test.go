@@ -0,0 +1,59 @@
+package decoder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testExport = `[
+	{"key": "testing/field2", "flags": 0, "value": "dmFsdWUy"},
+	{"key": "testing/", "flags": 0, "value": ""},
+	{"key": "testing/field1", "flags": 0, "value": "dmFsdWUx"},
+	{"key": "other/field1", "flags": 0, "value": "b3RoZXI="}
+]`
+
+func TestReadExport(t *testing.T) {
+	kvps, err := ReadExport(strings.NewReader(testExport))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(kvps) != 4 {
+		t.Fatalf("expected 4 pairs, got %d", len(kvps))
+	}
+	if kvps[0].Key != "other/field1" || string(kvps[0].Value) != "other" {
+		t.Errorf("expected pairs sorted by key, got %s first", kvps[0].Key)
+	}
+
+	if _, err := ReadExport(strings.NewReader(`[{"key": "testing/bad", "value": "!!"}]`)); err == nil {
+		t.Error("expected error for invalid base64 value")
+	}
+}
+
+func TestUnmarshalExport(t *testing.T) {
+	ts := &TestStruct{}
+	if err := UnmarshalExport(prefix, strings.NewReader(testExport), ts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ts.Field1 != "value1" || ts.Field2 != "value2" {
+		t.Errorf("unexpected values decoded from export: %+v", ts)
+	}
+}
+
+func TestWriteExport(t *testing.T) {
+	kvps, err := ReadExport(strings.NewReader(testExport))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var buf strings.Builder
+	if err := WriteExport(&buf, kvps); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	again, err := ReadExport(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(kvps, again) {
+		t.Errorf("expected %v after a round trip, got %v", kvps, again)
+	}
+}
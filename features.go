@@ -0,0 +1,142 @@
+package decoder
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// FeatureFlags - a set of feature flags read from a folder of keys, one per
+// flag, whose values are boolean-ish, as in "true", "on" or "1".  The flags
+// are replaced by each call to Update, which is meant to be made with the
+// result of each List of the folder, such as from a blocking query, and
+// subscribers are told of the flags that changed.  Flags without a key take
+// their default.  Names are case insensitive, and given in lower case.  The
+// zero value has no defaults and is ready for use.  A FeatureFlags is safe
+// for concurrent use.
+type FeatureFlags struct {
+	// Decoder reads the folder into a map[string]bool.  If nil, a decoder
+	// with WeaklyTypedInput is used.
+	Decoder *Decoder
+
+	lck      sync.RWMutex
+	defaults map[string]bool
+	flags    map[string]bool
+	subs     map[int]func(name string, enabled bool)
+	nextSub  int
+}
+
+// NewFeatureFlags - returns a FeatureFlags whose flags take the values in
+// defaults until they are given keys.
+func NewFeatureFlags(defaults map[string]bool) *FeatureFlags {
+	f := &FeatureFlags{defaults: make(map[string]bool, len(defaults))}
+	for name, enabled := range defaults {
+		f.defaults[strings.ToLower(name)] = enabled
+	}
+	return f
+}
+
+// Update - replaces the flags with those in the folder pathPrefix of kvps,
+// then calls the subscribers with each flag whose value changed, in order
+// of name.  Flags whose keys are gone revert to their defaults.  If the
+// folder can't be decoded, the flags are left as they were.
+func (f *FeatureFlags) Update(pathPrefix string, kvps api.KVPairs) error {
+	d := f.Decoder
+	if d == nil {
+		d = &Decoder{WeaklyTypedInput: true}
+	}
+	decoded := make(map[string]bool)
+	if err := d.Unmarshal(pathPrefix, kvps, &decoded); err != nil {
+		return err
+	}
+	flags := make(map[string]bool, len(decoded))
+	for name, enabled := range decoded {
+		flags[strings.ToLower(name)] = enabled
+	}
+
+	f.lck.Lock()
+	before := f.all()
+	f.flags = flags
+	after := f.all()
+	subs := make([]func(string, bool), 0, len(f.subs))
+	ids := make([]int, 0, len(f.subs))
+	for id := range f.subs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		subs = append(subs, f.subs[id])
+	}
+	f.lck.Unlock()
+
+	var changed []string
+	for name, enabled := range after {
+		if before[name] != enabled {
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	for _, name := range changed {
+		for _, fn := range subs {
+			fn(name, after[name])
+		}
+	}
+	return nil
+}
+
+// Enabled - returns true if the flag name is enabled, by its key or, failing
+// that, by default.  Unknown flags are disabled.
+func (f *FeatureFlags) Enabled(name string) bool {
+	name = strings.ToLower(name)
+	f.lck.RLock()
+	defer f.lck.RUnlock()
+	if enabled, ok := f.flags[name]; ok {
+		return enabled
+	}
+	return f.defaults[name]
+}
+
+// All - returns the value of every flag with a key or a default.
+func (f *FeatureFlags) All() map[string]bool {
+	f.lck.RLock()
+	defer f.lck.RUnlock()
+	return f.all()
+}
+
+// all returns the value of every flag.  f.lck must be held.
+func (f *FeatureFlags) all() map[string]bool {
+	all := make(map[string]bool, len(f.defaults)+len(f.flags))
+	for name, enabled := range f.defaults {
+		all[name] = enabled
+	}
+	for name, enabled := range f.flags {
+		all[name] = enabled
+	}
+	return all
+}
+
+// Subscribe - registers fn to be called by Update with each flag whose value
+// changed, and returns a func which unregisters it.  fn is called from the
+// goroutine calling Update, in the order the subscribers registered.
+func (f *FeatureFlags) Subscribe(fn func(name string, enabled bool)) (cancel func()) {
+	f.lck.Lock()
+	defer f.lck.Unlock()
+	if f.subs == nil {
+		f.subs = make(map[int]func(string, bool))
+	}
+	id := f.nextSub
+	f.nextSub++
+	f.subs[id] = fn
+	return func() {
+		f.lck.Lock()
+		defer f.lck.Unlock()
+		delete(f.subs, id)
+	}
+}
@@ -0,0 +1,73 @@
+package decoder
+
+import (
+	"reflect"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	f := NewFeatureFlags(map[string]bool{"Dark-Mode": true, "beta": false})
+	if !f.Enabled("dark-mode") || f.Enabled("beta") || f.Enabled("unknown") {
+		t.Errorf("unexpected defaults: %v", f.All())
+	}
+
+	type change struct {
+		name    string
+		enabled bool
+	}
+	var changes []change
+	cancel := f.Subscribe(func(name string, enabled bool) {
+		changes = append(changes, change{name, enabled})
+	})
+
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/flags/New-Checkout", Value: []byte("on")},
+		{Key: prefix + "/flags/dark-mode", Value: []byte("0")},
+		{Key: prefix + "/flags/beta", Value: []byte("false")},
+	}
+	if err := f.Update(prefix+"/flags", kvs); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !f.Enabled("new-checkout") || f.Enabled("Dark-Mode") {
+		t.Errorf("unexpected flags: %v", f.All())
+	}
+	want := []change{{"dark-mode", false}, {"new-checkout", true}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("expected changes %v, got %v", want, changes)
+	}
+
+	// Deleting keys reverts flags to their defaults.
+	changes = nil
+	if err := f.Update(prefix+"/flags", kvs[2:]); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want = []change{{"dark-mode", true}, {"new-checkout", false}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("expected changes %v, got %v", want, changes)
+	}
+
+	// Values which aren't boolean-ish leave the flags alone.
+	bad := consulapi.KVPairs{{Key: prefix + "/flags/beta", Value: []byte("maybe")}}
+	if err := f.Update(prefix+"/flags", bad); err == nil {
+		t.Error("expected error for a value which isn't a bool")
+	}
+	if want := map[string]bool{"dark-mode": true, "beta": false}; !reflect.DeepEqual(f.All(), want) {
+		t.Errorf("expected %v, got %v", want, f.All())
+	}
+
+	cancel()
+	changes = nil
+	if err := f.Update(prefix+"/flags", kvs); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no changes after cancelling, got %v", changes)
+	}
+
+	var zero FeatureFlags
+	if err := zero.Update(prefix+"/flags", kvs); err != nil || !zero.Enabled("new-checkout") {
+		t.Errorf("expected the zero value to work, got %v", err)
+	}
+}
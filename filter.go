@@ -0,0 +1,99 @@
+package decoder
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// filterPairs returns the pairs of kvps which aren't filtered out by the
+// IncludeKeys and ExcludeKeys of the decoder, or are hidden while it has
+// IgnoreHidden set.  Pairs outside of pathPrefix are kept, so that they're
+// dealt with as usual.
+func (d *Decoder) filterPairs(pathPrefix string, kvps api.KVPairs) (api.KVPairs, error) {
+	if len(d.IncludeKeys) == 0 && len(d.ExcludeKeys) == 0 && !d.IgnoreHidden {
+		return kvps, nil
+	}
+	if !d.CaseSensitive {
+		pathPrefix = strings.ToLower(pathPrefix)
+	}
+	filtered := make(api.KVPairs, 0, len(kvps))
+	for _, kvp := range kvps {
+		key := kvp.Key
+		if !d.CaseSensitive {
+			key = strings.ToLower(key)
+		}
+		k := strings.TrimPrefix(key, pathPrefix)
+		if pathPrefix != "" && k == key {
+			filtered = append(filtered, kvp)
+			continue
+		}
+		if d.IgnoreHidden && isHidden(k) {
+			continue
+		}
+		if len(d.IncludeKeys) > 0 {
+			ok, err := d.matchKey(d.IncludeKeys, k)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		ok, err := d.matchKey(d.ExcludeKeys, k)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			filtered = append(filtered, kvp)
+		}
+	}
+	return filtered, nil
+}
+
+// matchKey returns true if one of patterns matches key, or one of the
+// folders key is in.  A pattern beginning with "**/" matches at any depth,
+// so "**/.lock" matches ".lock", "a/.lock" and "a/b/.lock".
+func (d *Decoder) matchKey(patterns []string, key string) (bool, error) {
+	for _, pattern := range patterns {
+		if !d.CaseSensitive {
+			pattern = strings.ToLower(pattern)
+		}
+		pattern = strings.Trim(pattern, "/")
+		given := pattern
+		anyDepth := strings.HasPrefix(pattern, "**/")
+		if anyDepth {
+			pattern = pattern[len("**/"):]
+		}
+		for k := strings.TrimSuffix(key, "/"); k != "." && k != "/" && k != ""; k = path.Dir(k) {
+			for sub := k; ; {
+				ok, err := path.Match(pattern, sub)
+				if err != nil {
+					return false, fmt.Errorf("invalid key pattern %q: %s", given, err)
+				}
+				if ok {
+					return true, nil
+				}
+				i := strings.Index(sub, "/")
+				if !anyDepth || i < 0 {
+					break
+				}
+				sub = sub[i+1:]
+			}
+		}
+	}
+	return false, nil
+}
+
+// isHidden returns true if key, or one of the folders it is in, begins with
+// ".", as the bookkeeping keys of other tools often do.
+func isHidden(key string) bool {
+	for _, part := range strings.Split(strings.Trim(key, "/"), "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package decoder
+
+import (
+	"strings"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbFilterSvc struct {
+	Host     string
+	Internal map[string]string
+}
+
+type tbFilter struct {
+	Name  string
+	Web   tbFilterSvc
+	Batch tbFilterSvc
+}
+
+func TestKeyFilters(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/batch/host", Value: []byte("batch.local")},
+		{Key: prefix + "/batch/internal/token", Value: []byte("secret")},
+		{Key: prefix + "/name", Value: []byte("svc")},
+		{Key: prefix + "/web/host", Value: []byte("web.local")},
+		{Key: prefix + "/web/Internal/nested/token", Value: []byte("secret")},
+	}
+
+	cfg := &tbFilter{}
+	d := &Decoder{ExcludeKeys: []string{"*/internal"}}
+	if err := d.Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Web.Host != "web.local" || cfg.Batch.Host != "batch.local" || cfg.Name != "svc" {
+		t.Errorf("expected keys to be decoded: %+v", cfg)
+	}
+	if cfg.Web.Internal != nil || cfg.Batch.Internal != nil {
+		t.Errorf("expected internal keys to be excluded: %+v", cfg)
+	}
+
+	cfg = &tbFilter{}
+	d = &Decoder{IncludeKeys: []string{"web", "name"}, ExcludeKeys: []string{"web/internal/*"}}
+	if err := d.Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Name != "svc" || cfg.Web.Host != "web.local" || cfg.Web.Internal != nil || cfg.Batch.Host != "" {
+		t.Errorf("unexpected filtered decode: %+v", cfg)
+	}
+
+	// Case sensitive decoders match patterns case sensitively.
+	type tbCase struct {
+		Web struct {
+			Internal map[string]string
+		} `decoder:"web"`
+	}
+	cs := &tbCase{}
+	d = &Decoder{CaseSensitive: true, ExcludeKeys: []string{"web/internal"}}
+	if err := d.Unmarshal(prefix, kvs, cs); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cs.Web.Internal["nested"] != "secret" {
+		t.Errorf("expected a pattern differing in case not to match: %v", cs.Web.Internal)
+	}
+
+	d = &Decoder{ExcludeKeys: []string{"[web"}}
+	err := d.Unmarshal(prefix, kvs, &tbFilter{})
+	if err == nil || !strings.Contains(err.Error(), "invalid key pattern") {
+		t.Errorf("expected invalid pattern error, got %v", err)
+	}
+}
+
+func TestHiddenKeys(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/.meta/owner", Value: []byte("team")},
+		{Key: prefix + "/internal/.lock", Value: []byte("held")},
+		{Key: prefix + "/internal/a/.lock", Value: []byte("held")},
+		{Key: prefix + "/internal/a/token", Value: []byte("secret")},
+		{Key: prefix + "/name", Value: []byte("svc")},
+	}
+
+	cfg := &tbFilterSvc{}
+	d := &Decoder{IgnoreHidden: true, MapDepth: MapDepthFlatten}
+	if err := d.Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(cfg.Internal) != 1 || cfg.Internal["a/token"] != "secret" {
+		t.Errorf("expected hidden keys to be skipped: %v", cfg.Internal)
+	}
+
+	cfg = &tbFilterSvc{}
+	d = &Decoder{ExcludeKeys: []string{"**/.lock"}, MapDepth: MapDepthFlatten}
+	if err := d.Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(cfg.Internal) != 1 || cfg.Internal["a/token"] != "secret" {
+		t.Errorf("expected .lock keys to be excluded at any depth: %v", cfg.Internal)
+	}
+}
@@ -0,0 +1,108 @@
+package decoder
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// BindFlags - uses the default decoder to register the fields of v with fs.
+func BindFlags(fs *flag.FlagSet, v interface{}) error {
+	return defaultDecoder.BindFlags(fs, v)
+}
+
+// BindFlags - registers a flag with fs for each field of v that would be
+// populated from a single key by Unmarshal, so that command line overrides
+// can be layered on top of the values decoded from consul.  Flags are named
+// after their keys, with "/" replaced by ".", so the key "db/host" becomes
+// the flag -db.host.  The current value of each field is shown as the
+// default, so this is typically called after Unmarshal, and before
+// fs.Parse().  Setting a flag assigns the field directly, with all the same
+// parsing and validation as a value from consul.  Maps, and slices of structs,
+// are skipped.
+func (d *Decoder) BindFlags(fs *flag.FlagSet, v interface{}) error {
+	val, err := structValue(v)
+	if err != nil {
+		return err
+	}
+
+	meta, _, err := d.typeCache().tMeta(d, val.Type(), true)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(meta.tFieldsMetaMap))
+	for k, tfm := range meta.tFieldsMetaMap {
+		if tfm.nestsStruct() || tfm.locators[len(tfm.locators)-1].isMap {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		ff := &fieldFlag{d: d, tfm: meta.tFieldsMetaMap[k], val: val}
+		fs.Var(ff, strings.ReplaceAll(k, "/", "."), fmt.Sprintf("overrides consul key %s", k))
+	}
+	return nil
+}
+
+// fieldFlag implements flag.Value for a single field.
+type fieldFlag struct {
+	d   *Decoder
+	tfm *tFieldMeta
+	val reflect.Value
+
+	// set is true once the flag has been set.
+	set bool
+}
+
+// String - implements flag.Value.
+func (ff *fieldFlag) String() string {
+	// flag calls this on the zero value to detect defaults.
+	if ff.tfm == nil {
+		return ""
+	}
+	fv, ok := lookupField(ff.tfm, ff.val)
+	if !ok || !fv.CanInterface() {
+		return ""
+	}
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return ""
+		}
+		fv = fv.Elem()
+	}
+	if ff.tfm.isSpecial() {
+		// Shown as it would be given, with the separator of the field.
+		if s, err := dumpSeparated(ff.tfm, fv); err == nil {
+			return s
+		}
+	}
+	return fmt.Sprint(fv.Interface())
+}
+
+// Set - implements flag.Value.  Slices read from csv or ssv values are
+// replaced by each use of the flag, and other slices by the first, with
+// later uses appending to them, so that the flag may be repeated.
+func (ff *fieldFlag) Set(s string) error {
+	if ff.tfm.locators[len(ff.tfm.locators)-1].isSlice && (ff.tfm.isSpecial() || !ff.set) {
+		if fv, ok := lookupField(ff.tfm, ff.val); ok {
+			fv.Set(reflect.Zero(fv.Type()))
+		}
+	}
+	ff.set = true
+	pair := &api.KVPair{Key: ff.tfm.fieldName, Value: []byte(s)}
+	var rest api.KVPairs
+	return ff.d.allocAssign(&decodeState{}, ff.tfm, pair, &rest, ff.val, "")
+}
+
+// IsBoolFlag - allows bool fields to be set with just -name.
+func (ff *fieldFlag) IsBoolFlag() bool {
+	return ff.tfm != nil && ff.tfm.computedType == typeBool && len(ff.tfm.locators) > 0 &&
+		!ff.tfm.locators[len(ff.tfm.locators)-1].isSlice
+}
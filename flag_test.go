@@ -0,0 +1,79 @@
+package decoder
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbFlags struct {
+	Host    string
+	Port    int `decoder:",max=65535"`
+	Debug   bool
+	Timeout time.Duration
+	Tags    []string `decoder:",csv"`
+	Hosts   []string
+	L1      *TestLevel1
+	Labels  map[string]string
+	Structs []TestStruct
+}
+
+func TestBindFlags(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/host", Value: []byte("localhost")},
+		{Key: prefix + "/hosts/0", Value: []byte("h0")},
+		{Key: prefix + "/port", Value: []byte("80")},
+		{Key: prefix + "/tags", Value: []byte("x,y")},
+	}
+	cfg := &tbFlags{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var usage bytes.Buffer
+	fs.SetOutput(&usage)
+	if err := BindFlags(fs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, name := range []string{"labels", "structs"} {
+		if fs.Lookup(name) != nil {
+			t.Errorf("expected no flag for %s", name)
+		}
+	}
+	if f := fs.Lookup("host"); f == nil || f.DefValue != "localhost" {
+		t.Fatalf("expected host flag defaulting to decoded value, got %+v", f)
+	}
+	if f := fs.Lookup("tags"); f == nil || f.DefValue != "x,y" {
+		t.Fatalf("expected tags flag defaulting to the decoded csv, got %+v", f)
+	}
+
+	err := fs.Parse([]string{"-port", "8080", "-debug", "-timeout", "5s", "-tags", "a,b", "-l1.level2.int", "-3",
+		"-hosts", "h1", "-hosts", "h2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Host != "localhost" || cfg.Port != 8080 || !cfg.Debug || cfg.Timeout != 5*time.Second {
+		t.Errorf("unexpected result: %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Tags, []string{"a", "b"}) || !reflect.DeepEqual(cfg.Hosts, []string{"h1", "h2"}) {
+		t.Errorf("expected the flags to override the decoded slices, got %q and %q", cfg.Tags, cfg.Hosts)
+	}
+	if cfg.L1 == nil || cfg.L1.Level2 == nil || cfg.L1.Level2.Int != -3 {
+		t.Errorf("expected nested field to be set: %+v", cfg.L1)
+	}
+
+	if err := fs.Parse([]string{"-port", "70000"}); err == nil {
+		t.Error("expected out of range flag to fail")
+	}
+	fs.PrintDefaults()
+	if !strings.Contains(usage.String(), "overrides consul key l1/level2/int") {
+		t.Errorf("unexpected usage: %s", usage.String())
+	}
+}
@@ -0,0 +1,73 @@
+package decoder
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// flatField is an entry of the setter table of a flat struct, one whose
+// fields are all strings, numbers, bools, durations and times.
+type flatField struct {
+	key string
+	tfm *tFieldMeta
+}
+
+// flatFields returns the setter table of meta, sorted by key, or nil if it
+// isn't a flat struct.  Fields of embedded structs count.  Fields which
+// compileAssign can't deal with, or with modifiers which change their
+// values before they are parsed, aren't flat.
+func flatFields(meta *tMeta) []flatField {
+	if len(meta.stamps) > 0 || len(meta.tFieldsMetaMap) == 0 {
+		return nil
+	}
+	flat := make([]flatField, 0, len(meta.tFieldsMetaMap))
+	for k, tfm := range meta.tFieldsMetaMap {
+		if tfm.assign == nil || tfm.trim || tfm.service || len(tfm.transforms) > 0 {
+			return nil
+		}
+		flat = append(flat, flatField{key: k, tfm: tfm})
+	}
+	sort.Slice(flat, func(i, j int) bool { return flat[i].key < flat[j].key })
+	return flat
+}
+
+// flatTable returns the setter table of meta, or nil if it has none or the
+// decoder has settings which change values before they are parsed, or
+// instead of them being parsed.
+func (d *Decoder) flatTable(meta *tMeta) []flatField {
+	if meta.flat == nil || d.UseFlags || d.Templates || d.TrimSpace ||
+		d.EmptyValues != EmptyDefault || d.PairHook != nil || d.ServiceResolver != nil {
+		return nil
+	}
+	return meta.flat
+}
+
+// findFlat returns the field of flat whose key is k, or nil.
+func findFlat(flat []flatField, k string) *flatField {
+	lo, hi := 0, len(flat)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if flat[mid].key < k {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo < len(flat) && flat[lo].key == k {
+		return &flat[lo]
+	}
+	return nil
+}
+
+// assignFlat sets the field ff of val to the value of pair, which needs no
+// more than parsing.
+func (d *Decoder) assignFlat(state *decodeState, ff *flatField, pair *api.KVPair, val reflect.Value) error {
+	fv, err := ff.tfm.assign(d, val, pair.Value)
+	if err != nil {
+		return d.parseError(state, pair, err)
+	}
+	d.assigned(state, ff.tfm, pair, val, fv)
+	return nil
+}
@@ -0,0 +1,114 @@
+package decoder
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbFlatBase struct {
+	Region string
+}
+
+type tbFlat struct {
+	tbFlatBase
+	Name    string
+	Port    uint16
+	Ratio   float32
+	Enabled bool
+	Timeout time.Duration
+	Retries int
+}
+
+// tbNotFlat has the fields of tbFlat, and a slice, so that it is decoded
+// without the setter table.
+type tbNotFlat struct {
+	tbFlatBase
+	Name    string
+	Port    uint16
+	Ratio   float32
+	Enabled bool
+	Timeout time.Duration
+	Retries int
+	Tags    []string
+}
+
+func TestFlatFields(t *testing.T) {
+	for _, tc := range []struct {
+		v    interface{}
+		keys []string
+	}{
+		{&tbFlat{}, []string{"enabled", "name", "port", "ratio", "region", "retries", "timeout"}},
+		{&tbNotFlat{}, nil},
+		{&struct {
+			Port int `decoder:",min=1"`
+		}{}, nil},
+		{&struct {
+			Name string `decoder:",trim"`
+		}{}, nil},
+		{&struct{ Next *tbFlat }{}, []string{"next/enabled", "next/name", "next/port", "next/ratio", "next/region", "next/retries", "next/timeout"}},
+		{&struct{ Addr net.IP }{}, nil},
+	} {
+		meta, _, err := defaultDecoder.typeCache().tMeta(defaultDecoder, reflect.TypeOf(tc.v).Elem(), true)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var keys []string
+		for _, ff := range meta.flat {
+			keys = append(keys, ff.key)
+		}
+		if !reflect.DeepEqual(keys, tc.keys) {
+			t.Errorf("%T: expected flat keys %q, got %q", tc.v, tc.keys, keys)
+		}
+	}
+}
+
+func TestUnmarshalFlat(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/Enabled", Value: []byte("true")},
+		{Key: prefix + "/name", Value: []byte("flat")},
+		{Key: prefix + "/port", Value: []byte("8080")},
+		{Key: prefix + "/ratio", Value: []byte("0.5")},
+		{Key: prefix + "/region", Value: []byte("east")},
+		{Key: prefix + "/retries", Value: []byte("-3")},
+		{Key: prefix + "/timeout", Value: []byte("5s")},
+		{Key: prefix + "/unknown", Value: []byte("x")},
+	}
+	tf := &tbFlat{}
+	report, err := UnmarshalReport(prefix, kvs, tf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := &tbFlat{tbFlatBase{"east"}, "flat", 8080, 0.5, true, 5 * time.Second, -3}
+	if !reflect.DeepEqual(tf, want) {
+		t.Errorf("expected %+v, got %+v", want, tf)
+	}
+	if report.Stats.Fields != 7 {
+		t.Errorf("expected 7 fields to be reported, got %d", report.Stats.Fields)
+	}
+
+	// Values the setters can't parse are treated as they are for other
+	// structs.
+	weak := consulapi.KVPairs{
+		{Key: prefix + "/enabled", Value: []byte("yes")},
+		{Key: prefix + "/retries", Value: []byte("2.0")},
+	}
+	d := &Decoder{WeaklyTypedInput: true}
+	tf = &tbFlat{}
+	if err := d.Unmarshal(prefix, weak, tf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !tf.Enabled || tf.Retries != 2 {
+		t.Errorf("expected weakly typed values, got %+v", tf)
+	}
+
+	bad := consulapi.KVPairs{{Key: prefix + "/port", Value: []byte("http")}}
+	flatErr := Unmarshal(prefix, bad, &tbFlat{})
+	notFlatErr := Unmarshal(prefix, bad, &tbNotFlat{})
+	if flatErr == nil || notFlatErr == nil || flatErr.Error() != notFlatErr.Error() {
+		t.Errorf("expected the same error for both structs, got %v and %v", flatErr, notFlatErr)
+	}
+}
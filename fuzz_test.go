@@ -0,0 +1,104 @@
+package decoder
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+type fzItem struct {
+	Name  string
+	Port  *int
+	Tags  []string `decoder:",csv"`
+	Extra map[string]string
+}
+
+type fzTree struct {
+	Name     string
+	Count    int8
+	Size     uint16 `decoder:",min=1,max=100"`
+	Ratio    float32
+	Enabled  *bool
+	Timeout  time.Duration
+	IP       net.IP
+	Mask     net.IPMask
+	Raw      []byte  `decoder:",base64"`
+	Level    string  `decoder:",oneof=debug|info"`
+	JSON     *fzItem `decoder:",json"`
+	Item     fzItem
+	Items    []fzItem
+	ItemMap  map[string]*fzItem
+	Ports    []int               `decoder:",ssv"`
+	Hosts    map[string][]string `decoder:",csv"`
+	Any      interface{}
+	Settings map[string]interface{}
+	Nested   struct {
+		Deep struct {
+			Value string
+		}
+	}
+}
+
+// fuzzPairs splits data into keys and values, one pair to a line, with the
+// key and value separated by "=".
+func fuzzPairs(data []byte) ([]string, [][]byte) {
+	var keys []string
+	var values [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		key, value, _ := bytes.Cut(line, []byte("="))
+		keys = append(keys, string(key))
+		values = append(values, value)
+	}
+	return keys, values
+}
+
+func FuzzUnmarshalValues(f *testing.F) {
+	for _, seed := range []string{
+		"testing/name=x\ntesting/count=1\ntesting/size=5",
+		"testing/items/0/name=a\ntesting/items/1/port=80\ntesting/items/0/tags=a,b",
+		"testing/itemmap/a/name=x\ntesting/itemmap/a/extra/k=v\ntesting/itemmap/b=",
+		"testing/json={\"name\":\"x\"}\ntesting/ip=10.0.0.1\ntesting/mask=ffffff00",
+		"testing/hosts/a=b,c\ntesting/ports=1 2 3\ntesting/raw=aGk=",
+		"testing/nested/deep/value=x\ntesting/nested=y\ntesting/any=1\ntesting/settings/a/b=c",
+		"testing=x\ntesting/=y\ntesting//name=z\nother/name=w",
+	} {
+		f.Add(seed)
+	}
+	decoders := []*Decoder{
+		{},
+		{CaseSensitive: true, WeaklyTypedInput: true, InferTypes: true},
+		{Lenient: true, EmptyValues: EmptyAsZero, TrimSpace: true},
+		{PathSeparator: ".", Conflicts: ConflictPreferFolder, Merge: MergeReplace},
+	}
+	f.Fuzz(func(t *testing.T, data string) {
+		keys, values := fuzzPairs([]byte(data))
+		for _, d := range decoders {
+			m := map[string]string{}
+			var s []fzItem
+			for _, v := range []interface{}{&fzTree{}, &m, &s} {
+				// Panics are recovered as a PanicError, which is what
+				// the fuzzer is after.  Other errors are expected.
+				var pe *PanicError
+				if err := d.UnmarshalValues(prefix, keys, values, v); errors.As(err, &pe) {
+					t.Fatal(pe)
+				}
+			}
+		}
+	})
+}
+
+func TestUnmarshalValues(t *testing.T) {
+	cfg := &fzTree{}
+	keys := []string{prefix + "/name", prefix + "/items/0/name"}
+	if err := UnmarshalValues(prefix, keys, [][]byte{[]byte("x"), []byte("y")}, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Name != "x" || len(cfg.Items) != 1 || cfg.Items[0].Name != "y" {
+		t.Errorf("unexpected values: %+v", cfg)
+	}
+	if err := UnmarshalValues(prefix, keys, nil, cfg); err == nil {
+		t.Error("expected an error for mismatched keys and values")
+	}
+}
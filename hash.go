@@ -0,0 +1,80 @@
+package decoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+)
+
+// Hash - uses the default decoder to compute a hash of the fields of v
+// that would be populated by Unmarshal.
+func Hash(v interface{}) (uint64, error) {
+	return defaultDecoder.Hash(v)
+}
+
+// Hash - computes a stable hash of the fields of v that this decoder would
+// populate, honoring the decoder tags.  Fields skipped by the decoder do not
+// contribute to the hash, so two structs decoded from equivalent trees hash
+// the same.  This is intended to let callers cheaply detect that the
+// effective configuration has changed, and skip redundant reloads.
+func (d *Decoder) Hash(v interface{}) (uint64, error) {
+	val, err := structValue(v)
+	if err != nil {
+		return 0, err
+	}
+
+	meta, _, err := d.typeCache().tMeta(d, val.Type(), true)
+	if err != nil {
+		return 0, err
+	}
+
+	fields := make(map[string]*tFieldMeta, len(meta.tFieldsMetaMap))
+	for k, tfm := range meta.tFieldsMetaMap {
+		fields[k] = tfm
+	}
+	// Unlike the other stamps, exists fields are configuration.
+	for _, stfm := range meta.stamps {
+		if stfm.stamp == tagExists {
+			fields[stfm.fieldName] = stfm
+		}
+	}
+	// So are the keys gathered by remain.
+	if meta.remain != nil {
+		fields[meta.remain.fieldName] = meta.remain
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	for _, k := range keys {
+		fv, ok := lookupField(fields[k], val)
+		if !ok || !fv.CanInterface() {
+			continue
+		}
+		// json gives us a deterministic representation that
+		// follows pointers and sorts map keys.
+		b, err := json.Marshal(hashValue(fields[k], fv))
+		if err != nil {
+			return 0, fmt.Errorf("unable to hash %s: %s", k, err)
+		}
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write(b)
+		h.Write([]byte{0})
+	}
+	return h.Sum64(), nil
+}
+
+// hashValue returns what is hashed for the field fv.  The sync/atomic types
+// are hashed by the value they hold, as json sees nothing in them.
+func hashValue(tfm *tFieldMeta, fv reflect.Value) interface{} {
+	if tfm.computedType == typeAtomic && fv.CanAddr() {
+		return fv.Addr().MethodByName("Load").Call(nil)[0].Interface()
+	}
+	return fv.Interface()
+}
@@ -0,0 +1,89 @@
+package decoder
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+type tbHash struct {
+	Name    string
+	Ignored string `decoder:"-"`
+	Labels  map[string]string
+	L1      *TestLevel1
+}
+
+type tbHashAtomic struct {
+	N       atomic.Int64
+	Enabled atomic.Bool
+}
+
+type tbHashRemain struct {
+	Name  string
+	Extra map[string]string `decoder:",remain"`
+}
+
+func TestHash(t *testing.T) {
+	a := &tbHash{Name: "a", Labels: map[string]string{"x": "1", "y": "2"}, L1: &TestLevel1{Uint: 1}}
+	b := &tbHash{Name: "a", Labels: map[string]string{"y": "2", "x": "1"}, L1: &TestLevel1{Uint: 1}, Ignored: "different"}
+
+	ha, err := Hash(a)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	hb, err := Hash(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ha != hb {
+		t.Errorf("expected equivalent structs to hash the same, got %d and %d", ha, hb)
+	}
+
+	b.L1.Level2 = &TestLevel2{Int: 1}
+	if hb, _ = Hash(b); ha == hb {
+		t.Error("expected hash to change with nested field")
+	}
+
+	// Atomics hash by the values they hold.
+	one, two := &tbHashAtomic{}, &tbHashAtomic{}
+	one.N.Store(1)
+	two.N.Store(2)
+	if mustHash(t, one) == mustHash(t, two) {
+		t.Error("expected atomics holding different values to hash differently")
+	}
+	two.N.Store(1)
+	if mustHash(t, one) != mustHash(t, two) {
+		t.Error("expected atomics holding the same values to hash the same")
+	}
+	two.Enabled.Store(true)
+	if mustHash(t, one) == mustHash(t, two) {
+		t.Error("expected hash to change with an atomic bool")
+	}
+
+	// The keys gathered by remain are hashed too.
+	r1 := &tbHashRemain{Name: "a", Extra: map[string]string{"x": "1"}}
+	r2 := &tbHashRemain{Name: "a", Extra: map[string]string{"x": "2"}}
+	if mustHash(t, r1) == mustHash(t, r2) {
+		t.Error("expected hash to change with a remain value")
+	}
+	r2.Extra["x"] = "1"
+	if mustHash(t, r1) != mustHash(t, r2) {
+		t.Error("expected equal remain maps to hash the same")
+	}
+	r2.Extra["y"] = "1"
+	if mustHash(t, r1) == mustHash(t, r2) {
+		t.Error("expected hash to change with a remain key")
+	}
+
+	if _, err := Hash(tbHash{}); err != InvalidValueErr {
+		t.Errorf("expected InvalidValueErr, got %v", err)
+	}
+}
+
+func mustHash(t *testing.T, v interface{}) uint64 {
+	t.Helper()
+	h, err := Hash(v)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return h
+}
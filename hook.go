@@ -0,0 +1,66 @@
+package decoder
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// SkipPairErr - may be returned by a PairHookFunc to skip the pair, as if
+// it didn't exist.
+var SkipPairErr = errors.New("skip pair")
+
+// PairHookFunc - is called with each pair before it is decoded into the
+// field described by field, and returns the value to decode in its place.
+// Unlike a TransformFunc, it is given the whole pair, so can act on its
+// Flags, ModifyIndex or Session.  Returning SkipPairErr skips the pair, and
+// any other error fails the decode, as a value that can't be parsed would.
+// The pair must not be modified.
+type PairHookFunc func(pair *api.KVPair, field FieldInfo) ([]byte, error)
+
+// FieldInfo - describes the field a pair is about to be decoded into.
+type FieldInfo struct {
+	// Key is the key of the field, relative to the folder of the struct
+	// holding it, as derived from the struct tags, and lower cased unless
+	// the decoder is CaseSensitive.  For the elements of maps and slices, it
+	// is the key of the map or slice.
+	Key string
+	// Name is the name of the field, with the names of the structs it is
+	// nested in, as in "DB.Host".
+	Name string
+	// Type is the type of the field.
+	Type reflect.Type
+}
+
+// fieldInfo returns the FieldInfo for tfm, a field of val found at key.
+func fieldInfo(tfm *tFieldMeta, key string, val reflect.Value) FieldInfo {
+	fi := FieldInfo{Key: key}
+	names := make([]string, 0, len(tfm.locators))
+	t := val.Type()
+	for _, loc := range tfm.locators {
+		f := t.Field(loc.ind)
+		names = append(names, f.Name)
+		fi.Type = f.Type
+		t = loc.ttype
+	}
+	fi.Name = strings.Join(names, ".")
+	return fi
+}
+
+// hookPair passes pair to the PairHook of the decoder, returning a copy of
+// pair holding the value it returns, or nil if the pair is to be skipped.
+func (d *Decoder) hookPair(tfm *tFieldMeta, key string, pair *api.KVPair, val reflect.Value) (*api.KVPair, error) {
+	value, err := d.PairHook(pair, fieldInfo(tfm, key, val))
+	if err == SkipPairErr {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pair hook failed for %s: %w", pair.Key, err)
+	}
+	hooked := *pair
+	hooked.Value = value
+	return &hooked, nil
+}
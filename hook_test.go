@@ -0,0 +1,107 @@
+package decoder
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbHookDB struct {
+	Host string
+	Port int
+}
+
+type tbHookItem struct {
+	Name string
+}
+
+type tbHook struct {
+	DB      *tbHookDB
+	Leader  string
+	Tags    []string
+	Items   map[string]tbHookItem
+	Timeout time.Duration `decoder:"timeout_ms"`
+}
+
+func TestPairHook(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/db/host", Value: []byte("db.local")},
+		{Key: prefix + "/db/port", Value: []byte("5432"), Flags: 1},
+		{Key: prefix + "/items/a/name", Value: []byte("first")},
+		{Key: prefix + "/leader", Value: []byte("node1"), Session: "abc"},
+		{Key: prefix + "/tags/0", Value: []byte("a"), ModifyIndex: 10},
+		{Key: prefix + "/tags/1", Value: []byte("b"), ModifyIndex: 5},
+		{Key: prefix + "/timeout_ms", Value: []byte("250")},
+	}
+
+	fields := map[string]FieldInfo{}
+	d := &Decoder{
+		PairHook: func(pair *consulapi.KVPair, field FieldInfo) ([]byte, error) {
+			fields[pair.Key] = field
+			switch {
+			case pair.Session != "":
+				// Held by a lock session.
+				return nil, SkipPairErr
+			case pair.ModifyIndex > 0 && pair.ModifyIndex < 8:
+				return nil, SkipPairErr
+			case pair.Flags == 1:
+				return []byte("6432"), nil
+			case field.Key == "timeout_ms":
+				return append(pair.Value, "ms"...), nil
+			}
+			return pair.Value, nil
+		},
+	}
+
+	cfg := &tbHook{}
+	if err := d.Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.DB.Host != "db.local" || cfg.DB.Port != 6432 || cfg.Timeout != 250*time.Millisecond {
+		t.Errorf("unexpected values: %+v %s", cfg.DB, cfg.Timeout)
+	}
+	if cfg.Leader != "" || !reflect.DeepEqual(cfg.Tags, []string{"a"}) {
+		t.Errorf("expected pairs to be skipped: %q %v", cfg.Leader, cfg.Tags)
+	}
+	if cfg.Items["a"].Name != "first" {
+		t.Errorf("unexpected items: %v", cfg.Items)
+	}
+	if string(kvs[1].Value) != "5432" {
+		t.Errorf("pair was modified: %s", kvs[1].Value)
+	}
+
+	if fi := fields[prefix+"/db/port"]; fi.Name != "DB.Port" || fi.Key != "db/port" || fi.Type != reflect.TypeOf(0) {
+		t.Errorf("unexpected field info: %+v", fi)
+	}
+	if fi := fields[prefix+"/items/a/name"]; fi.Name != "Name" || fi.Key != "name" {
+		t.Errorf("unexpected field info for struct in map: %+v", fi)
+	}
+	if fi := fields[prefix+"/tags/0"]; fi.Name != "Tags" || fi.Type != reflect.TypeOf([]string{}) {
+		t.Errorf("unexpected field info for slice: %+v", fi)
+	}
+
+	fail := errors.New("denied")
+	d = &Decoder{
+		PairHook: func(pair *consulapi.KVPair, field FieldInfo) ([]byte, error) {
+			if strings.HasSuffix(pair.Key, "leader") {
+				return nil, fail
+			}
+			if field.Type == reflect.TypeOf(time.Duration(0)) {
+				return append(pair.Value, "ms"...), nil
+			}
+			return pair.Value, nil
+		},
+	}
+	if err := d.Unmarshal(prefix, kvs, &tbHook{}); !errors.Is(err, fail) {
+		t.Errorf("expected hook error, got %v", err)
+	}
+	d.Lenient = true
+	report, err := d.UnmarshalReport(prefix, kvs, &tbHook{})
+	if err != nil || len(report.Warnings) != 1 {
+		t.Errorf("expected a warning, got %v %v", report.Warnings, err)
+	}
+}
@@ -0,0 +1,272 @@
+package decoder
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// importEntry is a name and value read from a .env or properties file.
+type importEntry struct {
+	name, value string
+	line        int
+}
+
+// importKey matches the names of entries to a key of a struct.
+type importKey struct {
+	segments []string
+	re       *regexp.Regexp
+	wild     int
+}
+
+// ImportEnv - uses the default decoder to read a .env file into the pairs
+// of v.  See Decoder.ImportEnv.
+func ImportEnv(pathPrefix string, r io.Reader, v interface{}) (api.KVPairs, error) {
+	return defaultDecoder.ImportEnv(pathPrefix, r, v)
+}
+
+// ImportEnv - reads the NAME=value lines of a .env file from r, and returns
+// them as the pairs under pathPrefix that would be read into v, a pointer to
+// a struct, so that they can be written to consul.  Names are matched to the
+// keys of v case insensitively, with "_" in place of "/", so DB_HOST gives
+// the key "DB/Host" of a field Host in a struct DB.  The keys of maps and
+// slices are the part of the name in their place, lower cased, so
+// SERVICES_API_ADDR gives "Services/api/Addr", and may not hold a "_".  Lines
+// may start with "export", values may be quoted, and "#" starts a comment.
+// Names which don't match a key are an error.
+func (d *Decoder) ImportEnv(pathPrefix string, r io.Reader, v interface{}) (api.KVPairs, error) {
+	entries, err := parseEnv(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.importEntries(pathPrefix, entries, v, "_", false)
+}
+
+// ImportProperties - uses the default decoder to read a properties file
+// into the pairs of v.  See Decoder.ImportProperties.
+func ImportProperties(pathPrefix string, r io.Reader, v interface{}) (api.KVPairs, error) {
+	return defaultDecoder.ImportProperties(pathPrefix, r, v)
+}
+
+// ImportProperties - this is the same as ImportEnv, but reads a Java style
+// properties file, where names are separated by "." in place of "/", as in
+// db.host, and are matched case insensitively unless the decoder is
+// CaseSensitive.  The keys of maps keep their case.
+func (d *Decoder) ImportProperties(pathPrefix string, r io.Reader, v interface{}) (api.KVPairs, error) {
+	entries, err := parseProperties(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.importEntries(pathPrefix, entries, v, ".", d.CaseSensitive)
+}
+
+// importEntries returns entries as the pairs under pathPrefix of the keys
+// of v they match, where the parts of their names are separated by sep.
+func (d *Decoder) importEntries(pathPrefix string, entries []importEntry, v interface{}, sep string, caseSensitive bool) (api.KVPairs, error) {
+	val, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	var rows []docRow
+	if err := d.docRows(&rows, "", val.Type(), reflect.Value{}, make(map[reflect.Type]bool)); err != nil {
+		return nil, err
+	}
+	keys := make([]importKey, 0, len(rows))
+	for _, row := range rows {
+		if row.folder {
+			continue
+		}
+		keys = append(keys, newImportKey(row.key, sep, caseSensitive))
+	}
+	// Keys without maps or slices are preferred, so that the name of a
+	// field isn't taken for the key of a map.
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].wild < keys[j].wild })
+
+	pairs := make(map[string]*api.KVPair, len(entries))
+	for _, entry := range entries {
+		key, ok := matchImportKey(keys, entry.name, sep == "_")
+		if !ok {
+			return nil, fmt.Errorf("line %d: no key for %s", entry.line, entry.name)
+		}
+		key = path.Join(pathPrefix, key)
+		pairs[key] = &api.KVPair{Key: key, Value: []byte(entry.value)}
+	}
+	kvps := make(api.KVPairs, 0, len(pairs))
+	for _, kvp := range pairs {
+		kvps = append(kvps, kvp)
+	}
+	sort.Slice(kvps, func(i, j int) bool { return kvps[i].Key < kvps[j].Key })
+	return kvps, nil
+}
+
+// newImportKey returns the importKey for key, a key of a struct as given by
+// docRows, with "*" in place of the keys of maps and slices.
+func newImportKey(key, sep string, caseSensitive bool) importKey {
+	ik := importKey{segments: strings.Split(key, "/")}
+	parts := make([]string, len(ik.segments))
+	for i, segment := range ik.segments {
+		if segment == "*" {
+			parts[i] = "([^" + regexp.QuoteMeta(sep) + "]+)"
+			ik.wild++
+		} else {
+			parts[i] = regexp.QuoteMeta(segment)
+		}
+	}
+	expr := "^" + strings.Join(parts, regexp.QuoteMeta(sep)) + "$"
+	if !caseSensitive {
+		expr = "(?i)" + expr
+	}
+	ik.re = regexp.MustCompile(expr)
+	return ik
+}
+
+// matchImportKey returns the key of the first of keys that name matches,
+// with the parts of name in place of the keys of maps and slices.
+func matchImportKey(keys []importKey, name string, lower bool) (string, bool) {
+	for _, ik := range keys {
+		m := ik.re.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		segments := make([]string, len(ik.segments))
+		copy(segments, ik.segments)
+		n := 1
+		for i, segment := range segments {
+			if segment == "*" {
+				segments[i] = m[n]
+				if lower {
+					segments[i] = strings.ToLower(segments[i])
+				}
+				n++
+			}
+		}
+		return strings.Join(segments, "/"), true
+	}
+	return "", false
+}
+
+// parseEnv reads the entries of a .env file from r.
+func parseEnv(r io.Reader) ([]importEntry, error) {
+	var entries []importEntry
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected NAME=value", n)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case len(value) > 1 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid quoted value: %s", n, err)
+			}
+			value = unquoted
+		case len(value) > 1 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		entries = append(entries, importEntry{name: strings.TrimSpace(name), value: value, line: n})
+	}
+	return entries, scanner.Err()
+}
+
+// parseProperties reads the entries of a Java style properties file from r.
+func parseProperties(r io.Reader) ([]importEntry, error) {
+	var entries []importEntry
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		first := n
+		// A line ending with an odd number of backslashes continues on the
+		// next.
+		for continues(line) && scanner.Scan() {
+			n++
+			line = line[:len(line)-1] + strings.TrimLeft(scanner.Text(), " \t\f")
+		}
+		name, value := splitProperty(line)
+		entries = append(entries, importEntry{name: name, value: value, line: first})
+	}
+	return entries, scanner.Err()
+}
+
+// continues returns true if line ends with an unescaped backslash.
+func continues(line string) bool {
+	n := len(line) - len(strings.TrimRight(line, `\`))
+	return n%2 == 1
+}
+
+// splitProperty returns the name and value of a line of a properties file,
+// which are separated by the first unescaped "=", ":" or whitespace.
+func splitProperty(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':', ' ', '\t', '\f':
+			rest := strings.TrimLeft(line[i:], " \t\f")
+			if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+				rest = strings.TrimLeft(rest[1:], " \t\f")
+			}
+			return unescapeProperty(line[:i]), unescapeProperty(rest)
+		}
+	}
+	return unescapeProperty(line), ""
+}
+
+// unescapeProperty returns s with the escapes of properties files, such as
+// \t and \u00e9, replaced.
+func unescapeProperty(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 <= len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 16); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package decoder
+
+import (
+	"strings"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbImportService struct {
+	Addr string
+}
+
+type tbImport struct {
+	Name     string
+	MaxConns int `decoder:"max_conns"`
+	DB       struct {
+		Host string
+		Port int
+	}
+	Services map[string]tbImportService
+	Tags     []string
+	Labels   map[string]string
+}
+
+const testEnv = `# legacy settings
+export NAME="web \"app\""
+MAX_CONNS=10 # per host
+DB_HOST='db.local'
+DB_PORT=5432
+SERVICES_API_ADDR=10.0.0.1
+TAGS_0=a
+TAGS_1=b
+LABELS_ENV=prod
+`
+
+const testProperties = `# legacy settings
+name = web "app"
+max_conns: 10
+db.host=db.\
+    local
+db.port 5432
+services.Api.addr=10.0.0.1
+tags.0=a
+tags.1=b
+labels.env=prod
+`
+
+func TestImport(t *testing.T) {
+	for name, imp := range map[string]func() (string, error){
+		"env": func() (string, error) {
+			kvps, err := ImportEnv(prefix, strings.NewReader(testEnv), &tbImport{})
+			return pairKeys(kvps), err
+		},
+		"properties": func() (string, error) {
+			kvps, err := ImportProperties(prefix, strings.NewReader(testProperties), &tbImport{})
+			return pairKeys(kvps), err
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := imp()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			svc := "api"
+			if name == "properties" {
+				svc = "Api"
+			}
+			want := "testing/DB/Host=db.local testing/DB/Port=5432 testing/Labels/env=prod " +
+				"testing/Name=web \"app\" testing/Services/" + svc + "/Addr=10.0.0.1 " +
+				"testing/Tags/0=a testing/Tags/1=b testing/max_conns=10"
+			if got != want {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+		})
+	}
+
+	kvps, err := ImportEnv(prefix, strings.NewReader(testEnv), &tbImport{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ti := &tbImport{}
+	if err := Unmarshal(prefix, kvps, ti); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ti.DB.Port != 5432 || ti.Services["api"].Addr != "10.0.0.1" || len(ti.Tags) != 2 {
+		t.Errorf("unexpected values decoded from import: %+v", ti)
+	}
+
+	if _, err := ImportEnv(prefix, strings.NewReader("DB_USER=bob\n"), &tbImport{}); err == nil {
+		t.Error("expected error for a name without a key")
+	}
+	if _, err := ImportEnv(prefix, strings.NewReader("NAME\n"), &tbImport{}); err == nil {
+		t.Error("expected error for a line without a value")
+	}
+}
+
+// pairKeys returns kvps as key=value, separated by spaces.
+func pairKeys(kvps consulapi.KVPairs) string {
+	s := make([]string, len(kvps))
+	for i, kvp := range kvps {
+		s[i] = kvp.Key + "=" + string(kvp.Value)
+	}
+	return strings.Join(s, " ")
+}
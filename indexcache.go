@@ -0,0 +1,94 @@
+package decoder
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"reflect"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// decodedKey identifies the prefix decoded from by CachedUnmarshal, and the
+// type of the target decoded into.
+type decodedKey struct {
+	prefix string
+	typ    reflect.Type
+}
+
+// decodedTarget is the target last decoded into from a prefix, by address,
+// the index it was decoded at, and a hash of what it held once decoded.  The
+// target itself isn't held, so that it may be collected, and the hash tells
+// apart another target which has taken its place at the same address.
+type decodedTarget struct {
+	ptr   uintptr
+	index uint64
+	hash  uint64
+}
+
+// CachedUnmarshal - uses the default decoder to decode kvps into v, unless
+// it already holds them.  See Decoder.CachedUnmarshal.
+func CachedUnmarshal(pathPrefix string, kvps api.KVPairs, index uint64, v interface{}) (bool, error) {
+	return defaultDecoder.CachedUnmarshal(pathPrefix, kvps, index, v)
+}
+
+// CachedUnmarshal - this is Unmarshal, but skipped when v was last decoded
+// from pathPrefix by this decoder at the same index, such as the LastIndex
+// of the QueryMeta of the List which fetched kvps, so that a watch which
+// wakes without a change doesn't decode again.  It returns false if the
+// decode was skipped.  Only the last target decoded from each prefix into
+// each type is remembered, so a new one is always decoded, as is one decoded
+// into by turns with another.  The decode isn't skipped either if v no
+// longer holds what was decoded, as told by Hash for structs, and by its
+// json for maps and slices.  The decoder doesn't hold on to v.  An index of
+// zero is never skipped.  A failed decode is not remembered.
+func (d *Decoder) CachedUnmarshal(pathPrefix string, kvps api.KVPairs, index uint64, v interface{}) (bool, error) {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return false, InvalidValueErr
+	}
+	key := decodedKey{prefix: pathPrefix, typ: val.Type()}
+	reg := d.registry()
+
+	if index != 0 {
+		reg.decodedLck.Lock()
+		last, ok := reg.decoded[key]
+		reg.decodedLck.Unlock()
+		if ok && last.ptr == val.Pointer() && last.index == index {
+			if hash, err := d.targetHash(v); err == nil && hash == last.hash {
+				return false, nil
+			}
+		}
+	}
+	err := d.Unmarshal(pathPrefix, kvps, v)
+	var hash uint64
+	if err == nil && index != 0 {
+		hash, err = d.targetHash(v)
+	}
+
+	reg.decodedLck.Lock()
+	defer reg.decodedLck.Unlock()
+	if err != nil || index == 0 {
+		delete(reg.decoded, key)
+		return true, err
+	}
+	if reg.decoded == nil {
+		reg.decoded = make(map[decodedKey]decodedTarget)
+	}
+	reg.decoded[key] = decodedTarget{ptr: val.Pointer(), index: index, hash: hash}
+	return true, nil
+}
+
+// targetHash returns a hash of what v holds, for telling whether it still
+// holds what was decoded into it.
+func (d *Decoder) targetHash(v interface{}) (uint64, error) {
+	if reflect.ValueOf(v).Elem().Kind() == reflect.Struct {
+		return d.Hash(v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	h := fnv.New64a()
+	h.Write(b)
+	return h.Sum64(), nil
+}
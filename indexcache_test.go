@@ -0,0 +1,103 @@
+package decoder
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+func TestCachedUnmarshal(t *testing.T) {
+	kvs := consulapi.KVPairs{{Key: prefix + "/host", Value: []byte("db.local")}}
+	type config struct {
+		Host string
+		Port int
+	}
+
+	d := &Decoder{}
+	cfg := &config{}
+	if decoded, err := d.CachedUnmarshal(prefix, kvs, 10, cfg); err != nil || !decoded || cfg.Host != "db.local" {
+		t.Fatalf("expected a decode, got %t, %v, %+v", decoded, err, cfg)
+	}
+
+	// The same index is skipped, unless cfg was changed since.
+	if decoded, err := d.CachedUnmarshal(prefix, kvs, 10, cfg); err != nil || decoded {
+		t.Errorf("expected the decode to be skipped, got %t, %v, %+v", decoded, err, cfg)
+	}
+	cfg.Host = "changed"
+	if decoded, err := d.CachedUnmarshal(prefix, kvs, 10, cfg); err != nil || !decoded || cfg.Host != "db.local" {
+		t.Errorf("expected a changed target to be decoded, got %t, %v, %+v", decoded, err, cfg)
+	}
+
+	// Other targets, prefixes and indexes are decoded.
+	other := &config{}
+	if decoded, _ := d.CachedUnmarshal(prefix, kvs, 10, other); !decoded || other.Host != "db.local" {
+		t.Errorf("expected another target to be decoded, got %+v", other)
+	}
+	if decoded, _ := d.CachedUnmarshal(prefix+"/", kvs, 10, cfg); !decoded {
+		t.Error("expected another prefix to be decoded")
+	}
+	if decoded, _ := d.CachedUnmarshal(prefix, kvs, 11, cfg); !decoded || cfg.Host != "db.local" {
+		t.Errorf("expected a new index to be decoded, got %+v", cfg)
+	}
+	if decoded, _ := d.CachedUnmarshal(prefix, kvs, 0, cfg); !decoded {
+		t.Error("expected index 0 to be decoded")
+	}
+	if decoded, _ := d.CachedUnmarshal(prefix, kvs, 0, cfg); !decoded {
+		t.Error("expected index 0 never to be skipped")
+	}
+
+	// Failed decodes aren't remembered.
+	bad := consulapi.KVPairs{{Key: prefix + "/port", Value: []byte("x")}}
+	if _, err := d.CachedUnmarshal(prefix, bad, 12, cfg); err == nil {
+		t.Fatal("expected error")
+	}
+	if decoded, _ := d.CachedUnmarshal(prefix, kvs, 12, cfg); !decoded {
+		t.Error("expected a failed decode not to be remembered")
+	}
+
+	// Fresh targets are decoded, even where one is allocated at the address
+	// of another which was collected.
+	for i := 0; i < 10; i++ {
+		fresh := &config{}
+		if decoded, err := d.CachedUnmarshal(prefix, kvs, 20, fresh); err != nil || !decoded || fresh.Host != "db.local" {
+			t.Fatalf("expected a fresh target to be decoded, got %t, %v, %+v", decoded, err, fresh)
+		}
+		runtime.GC()
+	}
+
+	// Targets aren't kept from being collected.
+	collected := make(chan struct{})
+	func() {
+		gone := &config{}
+		runtime.SetFinalizer(gone, func(*config) { close(collected) })
+		_, _ = d.CachedUnmarshal(prefix, kvs, 30, gone)
+	}()
+	deadline := time.After(time.Second)
+wait:
+	for {
+		runtime.GC()
+		select {
+		case <-collected:
+			break wait
+		case <-deadline:
+			t.Error("expected the target to be collected")
+			break wait
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// Maps are compared by what they hold too.
+	m := map[string]string{}
+	if decoded, _ := d.CachedUnmarshal(prefix, kvs, 40, &m); !decoded || m["host"] != "db.local" {
+		t.Errorf("expected the map to be decoded, got %v", m)
+	}
+	if decoded, _ := d.CachedUnmarshal(prefix, kvs, 40, &m); decoded {
+		t.Error("expected the map decode to be skipped")
+	}
+
+	if _, err := d.CachedUnmarshal(prefix, kvs, 1, config{}); err != InvalidValueErr {
+		t.Errorf("expected InvalidValueErr, got %v", err)
+	}
+}
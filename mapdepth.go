@@ -0,0 +1,56 @@
+package decoder
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// MapDepthMode - how keys nested more than one level below a map of values
+// are treated, such as "m/a/b" for a map[string]string read from "m".
+type MapDepthMode int
+
+const (
+	// MapDepthFirst - the historical behavior.  The key of the element is
+	// the first folder below the map, so "m/a/b" and "m/a/c" both set the
+	// element "a", the last one winning.
+	MapDepthFirst MapDepthMode = iota
+	// MapDepthFlatten - the key of the element is the whole path below the
+	// map, as in "a/b".
+	MapDepthFlatten
+	// MapDepthSkip - nested keys are skipped, with a warning added to the
+	// Report.
+	MapDepthSkip
+	// MapDepthError - nested keys fail the decode.
+	MapDepthError
+)
+
+// mapKey returns the key of the element of the map field tfm that pair is
+// decoded into, or true if the pair is to be skipped.  Maps of structs read
+// nested keys into the fields of their elements, so are always keyed by the
+// first folder.
+func (d *Decoder) mapKey(state *decodeState, tfm *tFieldMeta, pair *api.KVPair, prefix string) (string, bool, error) {
+	trimpath := path.Join(prefix, tfm.fieldName) + "/"
+	key := pair.Key
+	if !d.CaseSensitive {
+		key = strings.ToLower(key)
+		trimpath = strings.ToLower(trimpath)
+	}
+	key = strings.TrimPrefix(key, trimpath)
+	first, rest, nested := strings.Cut(key, "/")
+	if !nested || rest == "" || tfm.nestsStruct() {
+		return first, false, nil
+	}
+	switch d.MapDepth {
+	case MapDepthFlatten:
+		return strings.TrimSuffix(key, "/"), false, nil
+	case MapDepthSkip:
+		state.report.warn(pair.Key, "skipped: nested more than one level below a map")
+		return "", true, nil
+	case MapDepthError:
+		return "", false, fmt.Errorf("key %s is nested more than one level below a map", pair.Key)
+	}
+	return first, false, nil
+}
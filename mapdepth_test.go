@@ -0,0 +1,51 @@
+package decoder
+
+import (
+	"reflect"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbMapDepth struct {
+	M map[string]string
+	S map[string]struct {
+		Host string
+	}
+}
+
+func TestMapDepth(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/m/a", Value: []byte("1")},
+		{Key: prefix + "/m/b/c", Value: []byte("2")},
+		{Key: prefix + "/m/b/d", Value: []byte("3")},
+		{Key: prefix + "/s/x/host", Value: []byte("x.local")},
+	}
+
+	for mode, want := range map[MapDepthMode]map[string]string{
+		MapDepthFirst:   {"a": "1", "b": "3"},
+		MapDepthFlatten: {"a": "1", "b/c": "2", "b/d": "3"},
+		MapDepthSkip:    {"a": "1"},
+	} {
+		d := &Decoder{MapDepth: mode}
+		tm := &tbMapDepth{}
+		report, err := d.UnmarshalReport(prefix, kvs, tm)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %s", mode, err)
+		}
+		if !reflect.DeepEqual(tm.M, want) {
+			t.Errorf("%d: expected %v, got %v", mode, want, tm.M)
+		}
+		if tm.S["x"].Host != "x.local" {
+			t.Errorf("%d: expected maps of structs to be unaffected, got %v", mode, tm.S)
+		}
+		if mode == MapDepthSkip && len(report.Warnings) != 2 {
+			t.Errorf("expected 2 warnings, got %v", report.Warnings)
+		}
+	}
+
+	d := &Decoder{MapDepth: MapDepthError}
+	if err := d.Unmarshal(prefix, kvs, &tbMapDepth{}); err == nil {
+		t.Error("expected error for a nested key")
+	}
+}
@@ -0,0 +1,108 @@
+package decoder
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// mapstructureTag is the tag read by decoders with Mapstructure set,
+	// unless they have a Tag of their own.
+	mapstructureTag = "mapstructure"
+
+	// tagOmitEmpty is the mapstructure modifier which omits empty values
+	// when encoding, and is ignored by decoders with Mapstructure set.
+	tagOmitEmpty = "omitempty"
+)
+
+// structTag returns the struct tag read by the decoder.
+func (d *Decoder) structTag() string {
+	switch {
+	case d.Tag != "":
+		return d.Tag
+	case d.Mapstructure:
+		return mapstructureTag
+	}
+	return defTag
+}
+
+// checkSquash returns an error unless t, the type of the field tfm with the
+// squash modifier, is a struct or a pointer to one.
+func checkSquash(tfm *tFieldMeta, t reflect.Type) error {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("%s: squash may only be used with structs and pointers to them", tfm.fieldName)
+	}
+	return nil
+}
+
+// parseRemain fills in tfm, the field with the remain modifier, of type t,
+// which must be a map keyed by strings of values that don't need modifiers
+// to be parsed.
+func parseRemain(tfm *tFieldMeta, t reflect.Type) error {
+	if t.Kind() != reflect.Map || t.Key().Kind() != reflect.String {
+		return fmt.Errorf("%s: remain may only be used with maps with string keys", tfm.fieldName)
+	}
+	et := t.Elem()
+	switch et.Kind() {
+	case reflect.Interface:
+		if et.NumMethod() != 0 {
+			return fmt.Errorf("%s: remain may not be used with maps of %s", tfm.fieldName, et)
+		}
+		tfm.computedType = typeInterface
+	case reflect.Slice:
+		if !isByteSlice(et) {
+			return fmt.Errorf("%s: remain may not be used with maps of %s", tfm.fieldName, et)
+		}
+		tfm.computedType = typeByteSlice
+	case reflect.String, reflect.Bool, reflect.Float64, reflect.Float32,
+		reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8,
+		reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
+		tfm.computedType = scalarType(et)
+	default:
+		return fmt.Errorf("%s: remain may not be used with maps of %s", tfm.fieldName, et)
+	}
+	loc := &tfm.locators[0]
+	loc.isMap = true
+	loc.ttype = et
+	return nil
+}
+
+// assignRemain sets the entry of the map tfm, the field of val with the
+// remain modifier, for pair, whose key is key relative to the folder of
+// val, as no other field of val is read from it.
+func (d *Decoder) assignRemain(state *decodeState, tfm *tFieldMeta, key string, pair *api.KVPair, val reflect.Value) error {
+	value, err := d.pairValue(tfm, pair)
+	if err != nil {
+		return d.parseError(state, pair, err)
+	}
+	ev, err := d.handleIntrinsicType(value, tfm.locators[len(tfm.locators)-1].ttype, tfm.computedType, tfm.params)
+	if err != nil {
+		return d.parseError(state, pair, err)
+	}
+
+	// Follow the locators to the map, which may be in a squashed struct.
+	fv := val
+	for _, loc := range tfm.locators {
+		fv = fv.Field(loc.ind)
+		for i := uint8(0); i < loc.ptrCt; i++ {
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+	}
+	if err := d.mergeCollection(state, tfm, fv); err != nil {
+		return err
+	}
+	if fv.IsNil() {
+		fv.Set(reflect.MakeMap(fv.Type()))
+	}
+	fv.SetMapIndex(reflect.ValueOf(key).Convert(fv.Type().Key()), ev)
+	d.assigned(state, tfm, pair, val, ev)
+	return nil
+}
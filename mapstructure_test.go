@@ -0,0 +1,77 @@
+package decoder
+
+import (
+	"reflect"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbMapstructureBase struct {
+	Region string `mapstructure:"region"`
+	Zone   string `mapstructure:"zone,omitempty"`
+}
+
+type tbMapstructureDB struct {
+	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port"`
+}
+
+type tbMapstructure struct {
+	tbMapstructureBase `mapstructure:",squash"`
+	DB                 *tbMapstructureDB      `mapstructure:",squash"`
+	Name               string                 `mapstructure:"name"`
+	Rest               map[string]interface{} `mapstructure:",remain"`
+}
+
+func TestMapstructure(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/Extra/Nested", Value: []byte("x")},
+		{Key: prefix + "/host", Value: []byte("db.local")},
+		{Key: prefix + "/name", Value: []byte("svc")},
+		{Key: prefix + "/port", Value: []byte("5432")},
+		{Key: prefix + "/region", Value: []byte("east")},
+		{Key: prefix + "/unknown", Value: []byte("y")},
+		{Key: prefix + "/zone", Value: []byte("a")},
+	}
+	d := &Decoder{Mapstructure: true, UnknownModifiers: IssueError}
+	tm := &tbMapstructure{}
+	if err := d.Unmarshal(prefix, kvs, tm); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := &tbMapstructure{
+		tbMapstructureBase: tbMapstructureBase{Region: "east", Zone: "a"},
+		DB:                 &tbMapstructureDB{Host: "db.local", Port: 5432},
+		Name:               "svc",
+		Rest:               map[string]interface{}{"Extra/Nested": "x", "unknown": "y"},
+	}
+	if !reflect.DeepEqual(tm, want) {
+		t.Errorf("expected %+v, got %+v", want, tm)
+	}
+
+	// omitempty is only ignored in Mapstructure mode.
+	d = &Decoder{Tag: mapstructureTag, UnknownModifiers: IssueError}
+	if err := d.Unmarshal(prefix, kvs, &tbMapstructure{}); err == nil {
+		t.Error("expected omitempty to be unknown without Mapstructure")
+	}
+}
+
+func TestMapstructureInvalid(t *testing.T) {
+	for _, v := range []interface{}{
+		&struct {
+			Name string `mapstructure:",squash"`
+		}{},
+		&struct {
+			Rest map[string][]string `mapstructure:",remain"`
+		}{},
+		&struct {
+			Rest  map[string]string `mapstructure:",remain"`
+			Other map[string]string `mapstructure:",remain"`
+		}{},
+	} {
+		d := &Decoder{Mapstructure: true}
+		if err := d.Unmarshal(prefix, nil, v); err == nil {
+			t.Errorf("%T: expected an error", v)
+		}
+	}
+}
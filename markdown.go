@@ -0,0 +1,154 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DocTag - the struct tag holding the description of a field, for
+// MarkdownDoc.
+const DocTag = "doc"
+
+// docRow is a row of the table written by MarkdownDoc.
+type docRow struct {
+	key, typ, def, modifiers, doc string
+
+	// folder is set for maps and slices of structs, whose keys are those of
+	// the rows that follow.
+	folder bool
+}
+
+// MarkdownDoc - uses the default decoder to describe the keys read into v.
+// See Decoder.MarkdownDoc.
+func MarkdownDoc(pathPrefix string, v interface{}) ([]byte, error) {
+	return defaultDecoder.MarkdownDoc(pathPrefix, v)
+}
+
+// MarkdownDoc - renders a markdown table of the keys under pathPrefix that
+// would be read into v, a pointer to a struct, with their types, modifiers
+// and the descriptions given in DocTag, as in `doc:"How long to wait."`.  As
+// keys which are missing leave fields alone, the values already in v are
+// shown as their defaults, redacted for fields with the secret modifier.
+// The keys of maps and slices are shown as "*".  This is meant for keeping
+// runbooks in step with the code.
+func (d *Decoder) MarkdownDoc(pathPrefix string, v interface{}) ([]byte, error) {
+	val, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	var rows []docRow
+	if err := d.docRows(&rows, strings.TrimSuffix(pathPrefix, "/"), val.Type(), val, make(map[reflect.Type]bool)); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("| Key | Type | Default | Modifiers | Description |\n")
+	buf.WriteString("|-----|------|---------|-----------|-------------|\n")
+	for _, row := range rows {
+		fmt.Fprintf(&buf, "| %s | %s | %s | %s | %s |\n",
+			docCode(row.key), docCode(row.typ), docCode(row.def), docCode(row.modifiers), docText(row.doc))
+	}
+	return buf.Bytes(), nil
+}
+
+// docRows appends the rows describing the fields of t, a struct read from
+// folder, to rows.  val is the struct holding the defaults, if there is one.
+// stack holds the structs being described, so that those which contain
+// themselves inside of maps or slices are only described once.
+func (d *Decoder) docRows(rows *[]docRow, folder string, t reflect.Type, val reflect.Value, stack map[reflect.Type]bool) error {
+	meta, _, err := d.typeCache().tMeta(d, t, true)
+	if err != nil {
+		return err
+	}
+	stack[t] = true
+	defer delete(stack, t)
+
+	tfms := make([]*tFieldMeta, 0, len(meta.tFieldsMetaMap))
+	for _, tfm := range meta.tFieldsMetaMap {
+		if tfm.tlsPart == "" {
+			tfms = append(tfms, tfm)
+		}
+	}
+	sort.Slice(tfms, func(i, j int) bool { return tfms[i].name < tfms[j].name })
+
+	tag := d.structTag()
+	for _, tfm := range tfms {
+		sf := structField(t, tfm)
+		loc := tfm.locators[len(tfm.locators)-1]
+		row := docRow{
+			key: path.Join(folder, tfm.name),
+			typ: sf.Type.String(),
+			doc: sf.Tag.Get(DocTag),
+		}
+		if _, modifiers, ok := strings.Cut(sf.Tag.Get(tag), ","); ok {
+			row.modifiers = strings.ReplaceAll(modifiers, ",", ", ")
+		}
+		collection := (loc.isMap || (loc.isSlice && tfm.isNotSpecial())) && !loc.isEncoded
+		if collection {
+			row.key += "/*"
+		}
+		if val.IsValid() {
+			if row.def, err = d.docDefault(tfm, val); err != nil {
+				return fmt.Errorf("%s: %s", tfm.name, err)
+			}
+		}
+		row.folder = collection && tfm.nestsStruct()
+		*rows = append(*rows, row)
+
+		if row.folder && !stack[loc.ttype] {
+			if err := d.docRows(rows, row.key, loc.ttype, reflect.Value{}, stack); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// docDefault returns the value of the field tfm of val, as shown in the
+// Default column, or an empty string if it is the zero value.
+func (d *Decoder) docDefault(tfm *tFieldMeta, val reflect.Value) (string, error) {
+	fv, ok := lookupField(tfm, val)
+	if !ok || fv.IsZero() {
+		return "", nil
+	}
+	value, ok, err := d.dumpField(tfm, fv)
+	if err != nil || !ok {
+		return "", err
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(value)
+	return string(b), err
+}
+
+// structField returns the field of t that tfm locates, following the
+// structs it is nested in.
+func structField(t reflect.Type, tfm *tFieldMeta) reflect.StructField {
+	var sf reflect.StructField
+	for _, loc := range tfm.locators {
+		sf = t.Field(loc.ind)
+		t = loc.ttype
+	}
+	return sf
+}
+
+// docCode returns s as inline code in a table cell, or nothing if s is
+// empty.
+func docCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + docText(s) + "`"
+}
+
+// docText returns s escaped to fit in a table cell.
+func docText(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
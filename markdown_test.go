@@ -0,0 +1,47 @@
+package decoder
+
+import (
+	"testing"
+	"time"
+)
+
+type tbDocService struct {
+	Addr  string `doc:"Address of the service."`
+	Peers map[string]*tbDocService
+}
+
+type tbDoc struct {
+	Timeout  time.Duration `decoder:"timeout,min=1s" doc:"How long to wait for a reply."`
+	Level    string        `decoder:",oneof=debug|info" doc:"Log level."`
+	Password string        `decoder:",secret"`
+	Tags     []string      `decoder:",csv"`
+	Services map[string]tbDocService
+	DB       struct {
+		Host string `doc:"Database host | address."`
+	}
+}
+
+func TestMarkdownDoc(t *testing.T) {
+	td := &tbDoc{Timeout: 5 * time.Second, Password: "hunter2", Tags: []string{"a", "b"}}
+	b, err := MarkdownDoc(prefix+"/", td)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := "| Key | Type | Default | Modifiers | Description |\n" +
+		"|-----|------|---------|-----------|-------------|\n" +
+		"| `testing/DB/Host` | `string` |  |  | Database host \\| address. |\n" +
+		"| `testing/Level` | `string` |  | `oneof=debug\\|info` | Log level. |\n" +
+		"| `testing/Password` | `string` | `[redacted]` | `secret` |  |\n" +
+		"| `testing/Services/*` | `map[string]decoder.tbDocService` |  |  |  |\n" +
+		"| `testing/Services/*/Addr` | `string` |  |  | Address of the service. |\n" +
+		"| `testing/Services/*/Peers/*` | `map[string]*decoder.tbDocService` |  |  |  |\n" +
+		"| `testing/Tags` | `[]string` | `a,b` | `csv` |  |\n" +
+		"| `testing/timeout` | `time.Duration` | `5s` | `min=1s` | How long to wait for a reply. |\n"
+	if string(b) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, b)
+	}
+
+	if _, err := MarkdownDoc(prefix, tbDoc{}); err != InvalidValueErr {
+		t.Errorf("expected InvalidValueErr, got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package decoder
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MergeMode - what happens to the maps and slices of a struct which has
+// already been decoded into, when it is decoded into again, as when
+// layering configuration or re-decoding after a change.
+type MergeMode int
+
+const (
+	// MergeAppend - the historical behavior.  Elements are appended to
+	// slices, and keys are added to maps, alongside those already there.
+	MergeAppend MergeMode = iota
+	// MergeReplace - maps and slices are emptied before the first of their
+	// elements is decoded, so they hold only what was just decoded.
+	// Those with no keys in the input are left alone.
+	MergeReplace
+	// MergeError - fail the decode if a map or slice with keys in the
+	// input already holds elements.
+	MergeError
+)
+
+// touchedKey identifies a map or slice within the target of a decode.
+type touchedKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// mergeCollection applies the decoder's MergeMode to sfield, a map or slice
+// about to be decoded into, the first time it is seen during a decode.
+func (d *Decoder) mergeCollection(state *decodeState, tfm *tFieldMeta, sfield reflect.Value) error {
+	if d.Merge == MergeAppend {
+		return nil
+	}
+	tk := touchedKey{ptr: sfield.Addr().Pointer(), typ: sfield.Type()}
+	if state.touched[tk] {
+		return nil
+	}
+	if state.touched == nil {
+		state.touched = make(map[touchedKey]bool)
+	}
+	state.touched[tk] = true
+
+	if sfield.Len() == 0 {
+		return nil
+	}
+	if d.Merge == MergeError {
+		return fmt.Errorf("%s: already holds %d elements", tfm.fieldName, sfield.Len())
+	}
+	sfield.Set(reflect.Zero(sfield.Type()))
+	return nil
+}
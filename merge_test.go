@@ -0,0 +1,61 @@
+package decoder
+
+import (
+	"strings"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbMerge struct {
+	Names  []string
+	Labels map[string]string
+	Ports  *[]int `decoder:",csv"`
+	Other  []string
+}
+
+func TestMergeModes(t *testing.T) {
+	first := consulapi.KVPairs{
+		{Key: prefix + "/labels/a", Value: []byte("1")},
+		{Key: prefix + "/names/0", Value: []byte("x")},
+		{Key: prefix + "/other/0", Value: []byte("kept")},
+		{Key: prefix + "/ports", Value: []byte("80,443")},
+	}
+	second := consulapi.KVPairs{
+		{Key: prefix + "/labels/b", Value: []byte("2")},
+		{Key: prefix + "/names/0", Value: []byte("y")},
+		{Key: prefix + "/names/1", Value: []byte("z")},
+		{Key: prefix + "/ports", Value: []byte("8080")},
+	}
+
+	decode := func(mode MergeMode) (*tbMerge, error) {
+		dec := &Decoder{Merge: mode}
+		cfg := &tbMerge{}
+		if err := dec.Unmarshal(prefix, first, cfg); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return cfg, dec.Unmarshal(prefix, second, cfg)
+	}
+
+	cfg, err := decode(MergeAppend)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Join(cfg.Names, ",") != "x,y,z" || len(cfg.Labels) != 2 || len(*cfg.Ports) != 3 {
+		t.Errorf("unexpected append result: %+v", cfg)
+	}
+
+	cfg, err = decode(MergeReplace)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Join(cfg.Names, ",") != "y,z" || len(cfg.Labels) != 1 || cfg.Labels["b"] != "2" ||
+		len(*cfg.Ports) != 1 || (*cfg.Ports)[0] != 8080 || strings.Join(cfg.Other, ",") != "kept" {
+		t.Errorf("unexpected replace result: %+v", cfg)
+	}
+
+	_, err = decode(MergeError)
+	if err == nil || !strings.Contains(err.Error(), "already holds") {
+		t.Errorf("expected merge error, got %v", err)
+	}
+}
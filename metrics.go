@@ -0,0 +1,40 @@
+package decoder
+
+import (
+	"time"
+)
+
+// DecodeStats - counters gathered over the course of a single call to
+// Unmarshal.
+type DecodeStats struct {
+	// Keys is the number of keys found under the prefix.
+	Keys int
+	// Fields is the number of values assigned.  Values assigned to
+	// fields of structs inside of maps and slices are included.
+	Fields int
+	// ParseErrors is the number of values that could not be converted
+	// to the type of their field.
+	ParseErrors int
+	// CacheHits and CacheMisses count lookups of type metadata.  A miss
+	// means the type had to be parsed, while a hit saves inspecting it, and
+	// the allocations that come with that, again.
+	CacheHits   int
+	CacheMisses int
+}
+
+// Metrics - set this on a Decoder to be informed of every call to
+// Unmarshal, for instance to feed dashboards on config reload health.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// Observe is called once Unmarshal has finished, with how long it took
+	// and the error it is returning, if any.
+	Observe(stats DecodeStats, elapsed time.Duration, err error)
+}
+
+// MetricsFunc - adapts a function to the Metrics interface.
+type MetricsFunc func(stats DecodeStats, elapsed time.Duration, err error)
+
+// Observe - calls f.
+func (f MetricsFunc) Observe(stats DecodeStats, elapsed time.Duration, err error) {
+	f(stats, elapsed, err)
+}
@@ -0,0 +1,63 @@
+package decoder
+
+import (
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbMetrics struct {
+	Name    string
+	Count   int
+	Structs map[string]*TestStruct
+}
+
+func TestMetrics(t *testing.T) {
+	var (
+		observed []DecodeStats
+		lastErr  error
+	)
+	dec := &Decoder{
+		Metrics: MetricsFunc(func(stats DecodeStats, _ time.Duration, err error) {
+			observed = append(observed, stats)
+			lastErr = err
+		}),
+	}
+
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/"},
+		{Key: prefix + "/count", Value: []byte("3")},
+		{Key: prefix + "/name", Value: []byte("name")},
+		{Key: prefix + "/structs/one/field1", Value: []byte("a")},
+		{Key: prefix + "/structs/one/field2", Value: []byte("b")},
+		{Key: "elsewhere/name", Value: []byte("ignored")},
+	}
+
+	if err := dec.Unmarshal(prefix, kvs, &tbMetrics{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := dec.Unmarshal(prefix, kvs[:2], &tbMetrics{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	kvs[1].Value = []byte("three")
+	if err := dec.Unmarshal(prefix, kvs, &tbMetrics{}); err == nil {
+		t.Fatal("expected parse error")
+	}
+
+	if len(observed) != 3 {
+		t.Fatalf("expected 3 observations, got %d", len(observed))
+	}
+	if lastErr == nil {
+		t.Error("expected error to be observed")
+	}
+	if s := observed[0]; s.Keys != 4 || s.Fields != 4 || s.ParseErrors != 0 {
+		t.Errorf("unexpected stats for first decode: %+v", s)
+	}
+	if s := observed[1]; s.Keys != 1 || s.Fields != 1 || s.CacheHits != 1 || s.CacheMisses != 0 {
+		t.Errorf("unexpected stats for second decode: %+v", s)
+	}
+	if s := observed[2]; s.ParseErrors != 1 {
+		t.Errorf("expected parse error to be counted: %+v", s)
+	}
+}
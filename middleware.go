@@ -0,0 +1,42 @@
+package decoder
+
+import (
+	"github.com/hashicorp/consul/api"
+)
+
+// UnmarshalFunc - decodes kvps at pathPrefix into v, as UnmarshalReport
+// does.  Middleware wraps one.
+type UnmarshalFunc func(pathPrefix string, kvps api.KVPairs, v interface{}) (*Report, error)
+
+// Middleware - wraps next, the rest of the chain, with a cross-cutting
+// concern such as tracing, caching or rewriting pairs.  It may change the
+// arguments passed to next, or not call it at all, but must return a
+// Report, if only an empty one.
+type Middleware func(next UnmarshalFunc) UnmarshalFunc
+
+// Use - adds mw to the middleware wrapping each call to Unmarshal, and to
+// the methods built on it.  The first middleware added is the outermost.
+// The nested decodes of structs inside of maps and slices aren't wrapped.
+// Middleware must be added before the decoder is first used.
+func (d *Decoder) Use(mw ...Middleware) {
+	reg := d.registry()
+	reg.lck.Lock()
+	defer reg.lck.Unlock()
+	reg.middleware = append(reg.middleware, mw...)
+}
+
+// chain returns unmarshalReport wrapped in the decoder's middleware, or nil
+// if it has none, which saves allocating a func for every decode.
+func (d *Decoder) chain() UnmarshalFunc {
+	reg := d.registry()
+	reg.lck.RLock()
+	defer reg.lck.RUnlock()
+	if len(reg.middleware) == 0 {
+		return nil
+	}
+	fn := d.unmarshalReport
+	for i := len(reg.middleware) - 1; i >= 0; i-- {
+		fn = reg.middleware[i](fn)
+	}
+	return fn
+}
@@ -0,0 +1,70 @@
+package decoder
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+func TestUse(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/host", Value: []byte("db.local")},
+		{Key: prefix + "/port", Value: []byte("5432")},
+	}
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next UnmarshalFunc) UnmarshalFunc {
+			return func(pathPrefix string, kvps consulapi.KVPairs, v interface{}) (*Report, error) {
+				calls = append(calls, name+" before")
+				report, err := next(pathPrefix, kvps, v)
+				calls = append(calls, name+" after")
+				return report, err
+			}
+		}
+	}
+	// Rewrites the pairs before they're decoded.
+	override := func(next UnmarshalFunc) UnmarshalFunc {
+		return func(pathPrefix string, kvps consulapi.KVPairs, v interface{}) (*Report, error) {
+			kvps = append(kvps, &consulapi.KVPair{Key: prefix + "/host", Value: []byte("override.local")})
+			return next(pathPrefix, kvps, v)
+		}
+	}
+
+	d := &Decoder{}
+	d.Use(trace("outer"), trace("inner"))
+	d.Use(override)
+	cfg := &struct {
+		Host string
+		Port int
+	}{}
+	report, err := d.UnmarshalReport(prefix, kvs, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Host != "override.local" || cfg.Port != 5432 {
+		t.Errorf("unexpected values: %+v", cfg)
+	}
+	if report.Stats.Keys != 3 {
+		t.Errorf("expected the report of the decode, got %+v", report.Stats)
+	}
+	want := []string{"outer before", "inner before", "inner after", "outer after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected %v, got %v", want, calls)
+	}
+
+	// Middleware may short circuit the decode.
+	skipErr := errors.New("skipped")
+	d = &Decoder{}
+	d.Use(func(next UnmarshalFunc) UnmarshalFunc {
+		return func(string, consulapi.KVPairs, interface{}) (*Report, error) {
+			return nil, skipErr
+		}
+	})
+	report, err = d.UnmarshalReport(prefix, kvs, cfg)
+	if !errors.Is(err, skipErr) || report == nil {
+		t.Errorf("expected the middleware's error and an empty report, got %v, %v", report, err)
+	}
+}
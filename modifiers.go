@@ -0,0 +1,152 @@
+package decoder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseModifiers applies modifiers, the parts of a struct tag after the
+// name, to tfm.  Modifiers of different kinds compose: the transformers are
+// applied to the value in order, then it is trimmed, then split by csv or
+// ssv or decoded whole by an encoding, so `,base64,json` is base64 wrapped
+// json, and `,gzip,csv` is compressed comma separated values.  Modifiers of
+// the same kind can't be combined, as only one of them could be honored.
+// The modifiers which aren't known are returned, for the UnknownModifiers
+// policy of the decoder to deal with.
+func (d *Decoder) parseModifiers(tfm *tFieldMeta, modifiers []string) ([]string, error) {
+	topLoc := &tfm.locators[0]
+
+	// encoding and split are the modifiers which decode the value whole,
+	// and which split it, so that conflicting ones are reported.
+	var encoding, split string
+	var unknown []string
+	setEncoding := func(name string, fn unmarshalFunc) error {
+		if encoding != "" && encoding != name {
+			return fmt.Errorf("%s: %s and %s can't be combined", tfm.fieldName, encoding, name)
+		}
+		encoding = name
+		topLoc.isEncoded = true
+		tfm.unmarshal = fn
+		return nil
+	}
+	setSplit := func(name string, sp special) error {
+		if split != "" && split != name {
+			return fmt.Errorf("%s: %s and %s can't be combined", tfm.fieldName, split, name)
+		}
+		split = name
+		tfm.special = sp
+		return nil
+	}
+
+	for _, tv := range modifiers {
+		var err error
+		switch tv {
+		case tagJSON, tagMsgpack:
+			err = setEncoding(tv, encodings[tv])
+		case tagCSV:
+			err = setSplit(tv, sCSV)
+		case tagSSV:
+			err = setSplit(tv, sSSV)
+		case tagTrim:
+			tfm.trim = true
+		case tagSecret:
+			tfm.secret = true
+		case tagService:
+			tfm.service = true
+		case tagEncrypted:
+			// Decrypted values are as sensitive as secret ones.
+			tfm.transforms = append(tfm.transforms, namedTransform{name: tv})
+			tfm.secret = true
+		case tagDecodedAt, tagLastIndex, tagExists:
+			tfm.stamp = tv
+		case tagSquash:
+			tfm.squash = true
+		case tagRemain:
+			tfm.remain = true
+		case tagOmitEmpty:
+			if !d.Mapstructure {
+				unknown = append(unknown, tv)
+			}
+		case "":
+			// A trailing comma, as in `decoder:"name,"`.
+		default:
+			name, value, hasValue := strings.Cut(tv, "=")
+			switch name {
+			case tagOneOf:
+				tfm.oneof = strings.Split(value, "|")
+			case tagMin:
+				tfm.minStr = value
+			case tagMax:
+				tfm.maxStr = value
+			default:
+				if hasValue {
+					if !isParam(name) {
+						unknown = append(unknown, tv)
+						continue
+					}
+					if tfm.params == nil {
+						tfm.params = make(map[string]string)
+					}
+					tfm.params[name] = value
+				} else if fn, ok := d.modifier(tv); ok {
+					err = setEncoding(tv, unmarshalFunc(fn))
+				} else if fn, ok := d.transformer(tv); ok {
+					tfm.transforms = append(tfm.transforms, namedTransform{name: tv, fn: fn})
+				} else {
+					unknown = append(unknown, tv)
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if encoding != "" && split != "" {
+		return nil, fmt.Errorf("%s: %s and %s can't be combined", tfm.fieldName, encoding, split)
+	}
+	return unknown, nil
+}
+
+// unknownModifier is a modifier in the tag of the field read from key which
+// isn't known.
+type unknownModifier struct {
+	key, modifier string
+}
+
+// checkModifiers deals with the unknown modifiers of meta according to the
+// UnknownModifiers policy of the decoder.  Each type is only warned about
+// once per decode.
+func (d *Decoder) checkModifiers(state *decodeState, meta *tMeta) error {
+	if len(meta.unknown) == 0 || d.UnknownModifiers == IssueIgnore {
+		return nil
+	}
+	if d.UnknownModifiers == IssueError {
+		return unknownModifierError(meta)
+	}
+	if state.warned[meta] {
+		return nil
+	}
+	if state.warned == nil {
+		state.warned = make(map[*tMeta]bool)
+	}
+	state.warned[meta] = true
+	for _, um := range meta.unknown {
+		state.report.warn(um.key, "unknown modifier %q", um.modifier)
+	}
+	return nil
+}
+
+// unknownModifierError returns the error for the unknown modifiers of meta.
+func unknownModifierError(meta *tMeta) error {
+	um := meta.unknown[0]
+	return fmt.Errorf("%s: unknown modifier %q", um.key, um.modifier)
+}
+
+// IsBuiltinModifier - returns true if name is one of the modifiers built in
+// to the decoder, rather than one registered with RegisterModifier or
+// RegisterTransformer.  For the modifiers which take a value, as in
+// base=16, name is the part before the "=".  This is meant for tools which
+// check struct tags, such as the decoderlint package.
+func IsBuiltinModifier(name string) bool {
+	return isReservedModifier(name) || isParam(name)
+}
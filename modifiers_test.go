@@ -0,0 +1,116 @@
+package decoder
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+func TestCombinedModifiers(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/encoded", Value: []byte("eyJ4IjoxfQ==")},
+		{Key: prefix + "/split", Value: []byte("YSxiLGM=")},
+		{Key: prefix + "/spaced", Value: []byte("613a62")},
+	}
+	tc := &struct {
+		Encoded map[string]int `decoder:",base64,json"`
+		Split   []string       `decoder:",base64,csv"`
+		Spaced  []string       `decoder:",hex,csv,sep=:"`
+	}{}
+	if err := Unmarshal(prefix, kvs, tc); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(tc.Encoded, map[string]int{"x": 1}) {
+		t.Errorf("unexpected encoded: %v", tc.Encoded)
+	}
+	if !reflect.DeepEqual(tc.Split, []string{"a", "b", "c"}) {
+		t.Errorf("unexpected split: %v", tc.Split)
+	}
+	if !reflect.DeepEqual(tc.Spaced, []string{"a", "b"}) {
+		t.Errorf("unexpected spaced: %v", tc.Spaced)
+	}
+
+	for name, v := range map[string]interface{}{
+		"csv and ssv": &struct {
+			F []string `decoder:",csv,ssv"`
+		}{},
+		"json and msgpack": &struct {
+			F []string `decoder:",json,msgpack"`
+		}{},
+		"json and csv": &struct {
+			F []string `decoder:",json,csv"`
+		}{},
+	} {
+		if err := Unmarshal(prefix, kvs, v); err == nil {
+			t.Errorf("expected error for %s", name)
+		}
+	}
+}
+
+type tbUnknownElem struct {
+	Host string `decoder:",tirm"`
+}
+
+type tbUnknown struct {
+	Raw   map[string]int `decoder:",jsno"`
+	Time  string         `decoder:",lyout=RFC3339"`
+	Name  string         `decoder:"name,"`
+	DB    tbUnknownElem
+	Elems map[string]tbUnknownElem
+}
+
+func TestUnknownModifiers(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/elems/a/host", Value: []byte("a.local")},
+		{Key: prefix + "/elems/b/host", Value: []byte("b.local")},
+		{Key: prefix + "/name", Value: []byte("web")},
+	}
+
+	report, err := UnmarshalReport(prefix, kvs, &tbUnknown{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var got []string
+	for _, w := range report.Warnings {
+		got = append(got, w.String())
+	}
+	sort.Strings(got)
+	want := []string{
+		`DB/Host: unknown modifier "tirm"`,
+		`Host: unknown modifier "tirm"`,
+		`Raw: unknown modifier "jsno"`,
+		`Time: unknown modifier "lyout=RFC3339"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected warnings %v, got %v", want, got)
+	}
+
+	d := &Decoder{UnknownModifiers: IssueError}
+	if err := d.Unmarshal(prefix, kvs, &tbUnknown{}); err == nil {
+		t.Error("expected error for unknown modifiers")
+	}
+	if err := d.Precompile(&tbUnknown{}); err == nil {
+		t.Error("expected Precompile to fail for unknown modifiers")
+	}
+	if err := d.Precompile(&map[string]tbUnknownElem{}); err != nil {
+		t.Errorf("unexpected error for a map of structs, which are checked when decoded: %s", err)
+	}
+
+	d = &Decoder{UnknownModifiers: IssueIgnore}
+	if report, err := d.UnmarshalReport(prefix, kvs, &tbUnknown{}); err != nil || len(report.Warnings) != 0 {
+		t.Errorf("expected no warnings or error, got %v, %v", report.Warnings, err)
+	}
+}
+
+func TestIsBuiltinModifier(t *testing.T) {
+	for name, builtin := range map[string]bool{
+		"json": true, "csv": true, "secret": true, "exists": true,
+		"oneof": true, "base": true, "duration": true, "upper": false, "": false,
+	} {
+		if IsBuiltinModifier(name) != builtin {
+			t.Errorf("%q: expected builtin to be %t", name, builtin)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package decoder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// UnmarshalMulti - uses the default decoder to decode the folders of kvps
+// at pathPrefix into several targets.  See Decoder.UnmarshalMulti.
+func UnmarshalMulti(pathPrefix string, kvps api.KVPairs, targets map[string]interface{}) error {
+	return defaultDecoder.UnmarshalMulti(pathPrefix, kvps, targets)
+}
+
+// UnmarshalMulti - decodes the keys of kvps below pathPrefix into targets,
+// which are keyed by the folder below pathPrefix that each is decoded from,
+// as if Unmarshal were called for each.  This lets one List of a prefix feed
+// several structs, with the pairs handed out in a single pass rather than
+// each decode scanning them all.  Folders may overlap, as "app" and "app/db"
+// do, in which case the pairs below both are decoded into both.  An empty
+// folder is pathPrefix itself.  Targets are decoded in order of their
+// folders, and the first error, which names the folder, ends the decode.
+func (d *Decoder) UnmarshalMulti(pathPrefix string, kvps api.KVPairs, targets map[string]interface{}) error {
+	sep := d.PathSeparator
+	if sep == "" {
+		sep = "/"
+	}
+	pathPrefix = strings.TrimSuffix(strings.TrimSuffix(pathPrefix, "/"), sep)
+
+	folders := make([]string, 0, len(targets))
+	prefixes := make([]string, 0, len(targets))
+	for folder := range targets {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+	for _, folder := range folders {
+		prefix := pathPrefix
+		if folder = strings.Trim(folder, "/"); folder != "" {
+			if prefix != "" {
+				prefix += sep
+			}
+			prefix += folder
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	split := make([]api.KVPairs, len(folders))
+	for _, kvp := range kvps {
+		for i, prefix := range prefixes {
+			if d.underPrefix(kvp.Key, prefix, sep) {
+				split[i] = append(split[i], kvp)
+			}
+		}
+	}
+
+	for i, folder := range folders {
+		if err := d.Unmarshal(prefixes[i], split[i], targets[folder]); err != nil {
+			return fmt.Errorf("%s: %w", folder, err)
+		}
+	}
+	return nil
+}
+
+// underPrefix returns true if key is prefix, or a key in its folder.
+func (d *Decoder) underPrefix(key, prefix, sep string) bool {
+	if prefix == "" {
+		return true
+	}
+	rest, ok := d.cutPrefix(key, prefix)
+	return ok && (rest == "" || strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, sep))
+}
@@ -0,0 +1,55 @@
+package decoder
+
+import (
+	"strings"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbMultiApp struct {
+	Name string
+	DB   struct {
+		Host string
+	}
+}
+
+type tbMultiDB struct {
+	Host string
+}
+
+func TestUnmarshalMulti(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/app/name", Value: []byte("web")},
+		{Key: prefix + "/app/db/host", Value: []byte("db.local")},
+		{Key: prefix + "/application/name", Value: []byte("other")},
+		{Key: prefix + "/cache", Value: []byte("redis")},
+		{Key: "other/app/name", Value: []byte("ignored")},
+	}
+
+	app := &tbMultiApp{}
+	db := &tbMultiDB{}
+	cache := map[string]string{}
+	err := UnmarshalMulti(prefix, kvs, map[string]interface{}{
+		"app":    app,
+		"app/db": db,
+		"":       &cache,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if app.Name != "web" || app.DB.Host != "db.local" {
+		t.Errorf("unexpected app: %+v", app)
+	}
+	if db.Host != "db.local" {
+		t.Errorf("unexpected db: %+v", db)
+	}
+	if cache["cache"] != "redis" {
+		t.Errorf("unexpected cache: %v", cache)
+	}
+
+	err = UnmarshalMulti(prefix, kvs, map[string]interface{}{"app": app, "bad": tbMultiDB{}})
+	if err == nil || !strings.HasPrefix(err.Error(), "bad: ") {
+		t.Errorf("expected error naming the folder, got %v", err)
+	}
+}
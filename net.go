@@ -0,0 +1,46 @@
+package decoder
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// HostPort - a "host:port" pair, as accepted by net.SplitHostPort.  Unlike
+// net.TCPAddr and net.UDPAddr, the host is kept as given rather than being
+// resolved, so it may be a name.
+type HostPort struct {
+	Host string
+	Port int
+}
+
+// UnmarshalText - implements encoding.TextUnmarshaler.  The port may be a
+// number or a service name known to net.LookupPort.
+func (hp *HostPort) UnmarshalText(text []byte) error {
+	host, port, err := net.SplitHostPort(string(text))
+	if err != nil {
+		return err
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		lp, lerr := net.LookupPort("tcp", port)
+		if lerr != nil {
+			return fmt.Errorf("invalid port %q in %q", port, text)
+		}
+		p = uint64(lp)
+	}
+	hp.Host = host
+	hp.Port = int(p)
+	return nil
+}
+
+// MarshalText - implements encoding.TextMarshaler.
+func (hp HostPort) MarshalText() ([]byte, error) {
+	return []byte(hp.String()), nil
+}
+
+// String - returns the pair in "host:port" form, with brackets around IPv6
+// hosts.
+func (hp HostPort) String() string {
+	return net.JoinHostPort(hp.Host, strconv.Itoa(hp.Port))
+}
@@ -0,0 +1,82 @@
+package decoder
+
+import (
+	"net"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbNetAddr struct {
+	TCP   *net.TCPAddr
+	UDP   net.UDPAddr
+	Peers []*net.TCPAddr
+	Proxy HostPort
+	IPv6  *HostPort
+	Named HostPort
+}
+
+func TestNetAddrs(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/ipv6", Value: []byte("[::1]:8500")},
+		{Key: prefix + "/named", Value: []byte("consul.service.consul:8301")},
+		{Key: prefix + "/peers/0", Value: []byte("10.0.0.1:1")},
+		{Key: prefix + "/peers/1", Value: []byte("10.0.0.2:2")},
+		{Key: prefix + "/proxy", Value: []byte("proxy.example.com:3128")},
+		{Key: prefix + "/tcp", Value: []byte("127.0.0.1:8080")},
+		{Key: prefix + "/udp", Value: []byte("[::1]:53")},
+	}
+
+	cfg := &tbNetAddr{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.TCP == nil || cfg.TCP.String() != "127.0.0.1:8080" {
+		t.Errorf("unexpected tcp address: %v", cfg.TCP)
+	}
+	if cfg.UDP.String() != "[::1]:53" {
+		t.Errorf("unexpected udp address: %v", cfg.UDP)
+	}
+	if len(cfg.Peers) != 2 || cfg.Peers[1].Port != 2 {
+		t.Errorf("unexpected peers: %v", cfg.Peers)
+	}
+	if cfg.Proxy.Host != "proxy.example.com" || cfg.Proxy.Port != 3128 {
+		t.Errorf("unexpected proxy: %v", cfg.Proxy)
+	}
+	if cfg.IPv6 == nil || cfg.IPv6.Host != "::1" || cfg.IPv6.String() != "[::1]:8500" {
+		t.Errorf("unexpected ipv6 host port: %v", cfg.IPv6)
+	}
+	if cfg.Named.String() != "consul.service.consul:8301" {
+		t.Errorf("unexpected named host port: %v", cfg.Named)
+	}
+
+	kvs = consulapi.KVPairs{{Key: prefix + "/proxy", Value: []byte("no-port")}}
+	if err := Unmarshal(prefix, kvs, &tbNetAddr{}); err == nil {
+		t.Error("expected error for missing port")
+	}
+}
+
+type tbHardwareAddr struct {
+	MAC   net.HardwareAddr
+	Ports map[string]net.HardwareAddr
+}
+
+func TestHardwareAddr(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/mac", Value: []byte("aa:bb:cc:dd:ee:ff")},
+		{Key: prefix + "/ports/eth0", Value: []byte("00-11-22-33-44-55")},
+	}
+
+	cfg := &tbHardwareAddr{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.MAC.String() != "aa:bb:cc:dd:ee:ff" || cfg.Ports["eth0"].String() != "00:11:22:33:44:55" {
+		t.Errorf("unexpected result: %+v", cfg)
+	}
+
+	kvs = consulapi.KVPairs{{Key: prefix + "/mac", Value: []byte("aa:bb")}}
+	if err := Unmarshal(prefix, kvs, &tbHardwareAddr{}); err == nil {
+		t.Error("expected error for invalid address")
+	}
+}
@@ -0,0 +1,45 @@
+package decoder
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// kvPairType is the type of the fields which are given the whole pair read
+// for them, with its indexes, flags and session, rather than its value.
+var kvPairType = reflect.TypeOf(api.KVPair{})
+
+// parsePair fills in tfm, a field of type api.KVPair or a pointer to one.
+// Pairs are only captured for single keys, so the field may not be in a
+// map or slice.
+func parsePair(tfm *tFieldMeta) error {
+	topLoc := tfm.locators[0]
+	if topLoc.isMap || topLoc.isSlice || tfm.isSpecial() {
+		return fmt.Errorf("%s: api.KVPair fields cannot be in maps or slices, or use csv or ssv", tfm.fieldName)
+	}
+	if len(tfm.transforms) > 0 || tfm.trim || tfm.service || tfm.oneof != nil {
+		return fmt.Errorf("%s: api.KVPair fields are given pairs untouched, so can't have modifiers", tfm.fieldName)
+	}
+	tfm.computedType = typePair
+	return nil
+}
+
+// capturePair sets the field tfm of val to a copy of pair, allocating any
+// pointers on the way to it.  The value of pair is given as it was read,
+// without flag hints or EmptyValues being applied.
+func (d *Decoder) capturePair(state *decodeState, tfm *tFieldMeta, pair *api.KVPair, val reflect.Value) {
+	fv := val
+	for _, loc := range tfm.locators {
+		fv = fv.Field(loc.ind)
+		for i := uint8(0); i < loc.ptrCt; i++ {
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+	}
+	fv.Set(reflect.ValueOf(*pair))
+	d.assigned(state, tfm, pair, val, fv)
+}
@@ -0,0 +1,61 @@
+package decoder
+
+import (
+	"reflect"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbPair struct {
+	Leader *consulapi.KVPair `decoder:"leader"`
+	DB     struct {
+		Lock consulapi.KVPair `decoder:"lock"`
+	} `decoder:"db"`
+	Name string `decoder:"name"`
+}
+
+func TestPair(t *testing.T) {
+	leader := &consulapi.KVPair{
+		Key:         prefix + "/leader",
+		Value:       []byte("node-1"),
+		CreateIndex: 10,
+		ModifyIndex: 12,
+		LockIndex:   1,
+		Flags:       FlagGzip,
+		Session:     "abc",
+	}
+	lock := &consulapi.KVPair{Key: prefix + "/db/lock", ModifyIndex: 7}
+	kvs := consulapi.KVPairs{
+		lock,
+		leader,
+		{Key: prefix + "/name", Value: []byte("svc")},
+	}
+	d := &Decoder{UseFlags: true, EmptyValues: EmptyError}
+	tp := &tbPair{}
+	if err := d.Unmarshal(prefix, kvs, tp); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tp.Leader == leader || !reflect.DeepEqual(tp.Leader, leader) {
+		t.Errorf("expected a copy of %+v, got %+v", leader, tp.Leader)
+	}
+	if !reflect.DeepEqual(tp.DB.Lock, *lock) {
+		t.Errorf("expected %+v, got %+v", *lock, tp.DB.Lock)
+	}
+	if tp.Name != "svc" {
+		t.Errorf("expected name svc, got %q", tp.Name)
+	}
+
+	for _, v := range []interface{}{
+		&struct {
+			Locks map[string]*consulapi.KVPair
+		}{},
+		&struct {
+			Leader *consulapi.KVPair `decoder:"leader,trim"`
+		}{},
+	} {
+		if err := Unmarshal(prefix, nil, v); err == nil {
+			t.Errorf("%T: expected an error", v)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package decoder
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/debug"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// PanicError - returned when decoding panics, which can only be due to a bug,
+// either in the decoder or in code it calls, such as an UnmarshalText method
+// or a modifier.  The panic is recovered, so that a bad tree or type can't
+// crash the caller, and described with as much context as is known.
+type PanicError struct {
+	// Key is the key being decoded, if known.
+	Key string
+	// Field is the name of the field being decoded into, with the names of
+	// the structs it is nested in, if known.
+	Field string
+	// Type is the type of the field, or of the value passed to Unmarshal if
+	// the field isn't known.
+	Type reflect.Type
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+// Error - implements error.
+func (e *PanicError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("panic decoding into %s: %v", e.Type, e.Value)
+	}
+	return fmt.Sprintf("panic decoding %s into %s (%s): %v", e.Key, e.Field, e.Type, e.Value)
+}
+
+// assignPair passes thisPair, the pair of the field tfm found at key, to the
+// PairHook, if any, then assigns it with allocAssign.  Should either panic,
+// a *PanicError is returned.
+func (d *Decoder) assignPair(state *decodeState, tfm *tFieldMeta, key string, thisPair *api.KVPair, rest *api.KVPairs, val reflect.Value, prefix string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			fi := fieldInfo(tfm, key, val)
+			err = &PanicError{Key: thisPair.Key, Field: fi.Name, Type: fi.Type, Value: r, Stack: debug.Stack()}
+		}
+	}()
+	if d.PairHook != nil && !tfm.nestsStruct() {
+		hooked, err := d.hookPair(tfm, key, thisPair, val)
+		if err != nil {
+			return d.parseError(state, thisPair, err)
+		}
+		if hooked == nil {
+			return nil
+		}
+		thisPair = hooked
+	}
+	return d.allocAssign(state, tfm, thisPair, rest, val, prefix)
+}
+
+// recoverPanic sets *err to a *PanicError describing any panic, which
+// happened outside of assignPair.  It must be deferred.
+func recoverPanic(v interface{}, err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Type: reflect.TypeOf(v), Value: r, Stack: debug.Stack()}
+	}
+}
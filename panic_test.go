@@ -0,0 +1,74 @@
+package decoder
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbPanicky struct{}
+
+func (p *tbPanicky) UnmarshalText([]byte) error {
+	panic("broken")
+}
+
+type tbPanicInner struct {
+	Value tbPanicky
+}
+
+type tbPanic struct {
+	Name  string
+	Inner tbPanicInner
+	Items map[string]tbPanicInner
+}
+
+func TestPanicRecovery(t *testing.T) {
+	kvs := consulapi.KVPairs{{Key: prefix + "/inner/value", Value: []byte("x")}}
+	err := Unmarshal(prefix, kvs, &tbPanic{})
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected a PanicError, got %v", err)
+	}
+	if pe.Key != prefix+"/inner/value" || pe.Field != "Inner.Value" || pe.Type != reflect.TypeOf(tbPanicky{}) || pe.Value != "broken" {
+		t.Errorf("unexpected panic error: %+v", pe)
+	}
+	if len(pe.Stack) == 0 || !strings.Contains(pe.Error(), "broken") {
+		t.Errorf("unexpected panic error: %s", pe)
+	}
+
+	// Panics inside of maps are described by the nested decode.
+	kvs = consulapi.KVPairs{{Key: prefix + "/items/a/value", Value: []byte("x")}}
+	if err := Unmarshal(prefix, kvs, &tbPanic{}); !errors.As(err, &pe) || pe.Field != "Value" || pe.Key != prefix+"/items/a/value" {
+		t.Errorf("unexpected panic error in map: %v", err)
+	}
+
+	d := &Decoder{PairHook: func(*consulapi.KVPair, FieldInfo) ([]byte, error) { panic("hook") }}
+	kvs = consulapi.KVPairs{{Key: prefix + "/name", Value: []byte("x")}}
+	if err := d.Unmarshal(prefix, kvs, &tbPanic{}); !errors.As(err, &pe) || pe.Value != "hook" || pe.Field != "Name" {
+		t.Errorf("unexpected panic error from hook: %v", err)
+	}
+
+	// Panics outside of the assignment of a pair are described by the type
+	// passed to Unmarshal, and don't leave the cache in a bad state.
+	type tbPanicResolved struct {
+		Name string
+	}
+	resolve := true
+	d = &Decoder{NameResolver: func(field, tag string) string {
+		if resolve {
+			panic("resolver")
+		}
+		return field
+	}}
+	if err := d.Unmarshal(prefix, kvs, &tbPanicResolved{}); !errors.As(err, &pe) || pe.Key != "" || pe.Type != reflect.TypeOf(&tbPanicResolved{}) {
+		t.Errorf("unexpected panic error from resolver: %v", err)
+	}
+	resolve = false
+	cfg := &tbPanicResolved{}
+	if err := d.Unmarshal(prefix, kvs, cfg); err != nil || cfg.Name != "x" {
+		t.Errorf("expected decode to succeed after panic, got %+v %v", cfg, err)
+	}
+}
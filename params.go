@@ -0,0 +1,157 @@
+package decoder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The key=value modifiers which affect how values are parsed.  These are
+// kept in the params of a field, rather than having a field of their own in
+// tFieldMeta, so that more can be added without changing the tag parser.
+const (
+	// layout is the layout, as understood by time.Parse, or the name of one
+	// of the layouts in the time package, used for time.Time fields.
+	paramLayout = "layout"
+
+	// base is the base of integers, as understood by strconv.ParseInt.
+	paramBase = "base"
+
+	// sep is the separator used instead of a comma by the csv modifier.
+	paramSep = "sep"
+
+	// duration is the unit of a number holding a duration, as in
+	// duration=ms, so that "2s" is read into an int as 2000, and a bare
+	// number into a time.Duration as that many of the unit.
+	paramDuration = "duration"
+)
+
+// durationUnits are the units which may be given to the duration modifier.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// isParam returns true if name is one of the modifiers which take a value,
+// other than oneof, min and max.
+func isParam(name string) bool {
+	switch name {
+	case paramLayout, paramBase, paramSep, paramDuration:
+		return true
+	}
+	return false
+}
+
+// namedLayouts are the layouts of the time package which may be given to
+// the layout modifier by name, since they can't otherwise be given in a
+// tag.  The names are lower case, as layout names are case insensitive.
+var namedLayouts = map[string]string{
+	"ansic":       time.ANSIC,
+	"unixdate":    time.UnixDate,
+	"rubydate":    time.RubyDate,
+	"rfc822":      time.RFC822,
+	"rfc822z":     time.RFC822Z,
+	"rfc850":      time.RFC850,
+	"rfc1123":     time.RFC1123,
+	"rfc1123z":    time.RFC1123Z,
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"kitchen":     time.Kitchen,
+	"stamp":       time.Stamp,
+	"datetime":    "2006-01-02 15:04:05",
+	"dateonly":    "2006-01-02",
+	"timeonly":    "15:04:05",
+}
+
+// checkParams returns an error if the params of tfm can't be used with the
+// type of the field, or are invalid.  Params which aren't known never reach
+// it, as they're left to the UnknownModifiers policy of the decoder.
+func checkParams(tfm *tFieldMeta) error {
+	if _, ok := tfm.params[paramLayout]; ok && tfm.computedType != typeTime {
+		return fmt.Errorf("%s: layout may only be used with time.Time", tfm.fieldName)
+	}
+	if base, ok := tfm.params[paramBase]; ok {
+		ct := tfm.computedType
+		if ct == typeAtomic {
+			ct = tfm.atomicComputedType
+		}
+		if ct != typeInt && ct != typeUint {
+			return fmt.Errorf("%s: base may only be used with integers", tfm.fieldName)
+		}
+		b, err := strconv.Atoi(base)
+		if err != nil || b == 1 || b < 0 || b > 36 {
+			return fmt.Errorf("%s: invalid base %s", tfm.fieldName, base)
+		}
+	}
+	if unit, ok := tfm.params[paramDuration]; ok {
+		ct := tfm.computedType
+		if ct == typeAtomic {
+			ct = tfm.atomicComputedType
+		}
+		if ct != typeInt && ct != typeUint && ct != typeFloat && ct != typeDuration {
+			return fmt.Errorf("%s: duration may only be used with numbers and time.Duration", tfm.fieldName)
+		}
+		if _, ok := durationUnits[unit]; !ok {
+			return fmt.Errorf("%s: invalid duration unit %s", tfm.fieldName, unit)
+		}
+	}
+	if sep, ok := tfm.params[paramSep]; ok {
+		if !tfm.isCSV() {
+			return fmt.Errorf("%s: sep may only be used with csv", tfm.fieldName)
+		}
+		r, size := utf8.DecodeRuneInString(sep)
+		if size == 0 || size != len(sep) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+			return fmt.Errorf("%s: invalid sep %q", tfm.fieldName, sep)
+		}
+	}
+	return nil
+}
+
+// paramBaseValue returns the base given to the base modifier, or 10.  The
+// base has already been checked by checkParams.
+func paramBaseValue(params map[string]string) int {
+	if base, ok := params[paramBase]; ok {
+		b, _ := strconv.Atoi(base)
+		return b
+	}
+	return 10
+}
+
+// paramDurationValue returns the unit given to the duration modifier, and
+// true if there is one.  The unit has already been checked by checkParams.
+func paramDurationValue(params map[string]string) (time.Duration, bool) {
+	unit, ok := params[paramDuration]
+	if !ok {
+		return 0, false
+	}
+	return durationUnits[unit], true
+}
+
+// durationParam parses data, the value of a number with the duration
+// modifier, as a duration such as "2s", returning it and the unit.  ok is
+// false if data isn't a duration, or there is no modifier, so that it is
+// parsed as a bare number instead.
+func durationParam(data []byte, params map[string]string) (dval, unit time.Duration, ok bool) {
+	if unit, ok = paramDurationValue(params); !ok {
+		return 0, 0, false
+	}
+	dval, err := time.ParseDuration(string(data))
+	return dval, unit, err == nil
+}
+
+// paramLayoutValue returns the layout given to the layout modifier,
+// resolving the names of the layouts of the time package.
+func paramLayoutValue(params map[string]string) string {
+	layout := params[paramLayout]
+	if named, ok := namedLayouts[strings.ToLower(layout)]; ok {
+		return named
+	}
+	return layout
+}
@@ -0,0 +1,149 @@
+package decoder
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbParams struct {
+	Started  time.Time    `decoder:",layout=2006-01-02"`
+	Expires  *time.Time   `decoder:",layout=RFC1123Z"`
+	Default  time.Time    // RFC 3339, by way of UnmarshalText
+	Perms    uint32       `decoder:",base=8"`
+	Mask     int          `decoder:",base=16,max=ff"`
+	Flags    atomic.Int64 `decoder:",base=2"`
+	Counts   []int        `decoder:",csv,sep=;"`
+	Holidays []time.Time  `decoder:",ssv,layout=dateonly"`
+}
+
+func TestParams(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/counts", Value: []byte("1;2;3")},
+		{Key: prefix + "/default", Value: []byte("2020-01-02T03:04:05Z")},
+		{Key: prefix + "/expires", Value: []byte("Mon, 02 Jan 2006 15:04:05 -0700")},
+		{Key: prefix + "/flags", Value: []byte("101")},
+		{Key: prefix + "/holidays", Value: []byte("2024-12-25 2025-01-01")},
+		{Key: prefix + "/mask", Value: []byte("7f")},
+		{Key: prefix + "/perms", Value: []byte("755")},
+		{Key: prefix + "/started", Value: []byte("2021-06-01")},
+	}
+
+	cfg := &tbParams{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Started.Year() != 2021 || cfg.Started.Month() != time.June {
+		t.Errorf("unexpected started: %v", cfg.Started)
+	}
+	if cfg.Expires == nil || cfg.Expires.Year() != 2006 {
+		t.Errorf("unexpected expires: %v", cfg.Expires)
+	}
+	if cfg.Default.Year() != 2020 {
+		t.Errorf("unexpected default: %v", cfg.Default)
+	}
+	if cfg.Perms != 0755 || cfg.Mask != 0x7f || cfg.Flags.Load() != 5 {
+		t.Errorf("unexpected numbers: %d %d %d", cfg.Perms, cfg.Mask, cfg.Flags.Load())
+	}
+	if len(cfg.Counts) != 3 || cfg.Counts[2] != 3 {
+		t.Errorf("unexpected counts: %v", cfg.Counts)
+	}
+	if len(cfg.Holidays) != 2 || cfg.Holidays[1].Year() != 2025 {
+		t.Errorf("unexpected holidays: %v", cfg.Holidays)
+	}
+
+	kvs = consulapi.KVPairs{{Key: prefix + "/mask", Value: []byte("100")}}
+	err := Unmarshal(prefix, kvs, &tbParams{})
+	if err == nil || !strings.Contains(err.Error(), "at most") {
+		t.Errorf("expected range error, got %v", err)
+	}
+}
+
+type tbDurationParams struct {
+	TimeoutMS int64         `decoder:",duration=ms,min=1s"`
+	Seconds   float64       `decoder:",duration=s"`
+	Minutes   uint          `decoder:",duration=m"`
+	Wait      time.Duration `decoder:",duration=ms"`
+	Bare      int           `decoder:",duration=ms"`
+	Backoff   []int         `decoder:",csv,duration=ms"`
+}
+
+func TestParamsDuration(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/backoff", Value: []byte("100ms,1s")},
+		{Key: prefix + "/bare", Value: []byte("250")},
+		{Key: prefix + "/minutes", Value: []byte("2h")},
+		{Key: prefix + "/seconds", Value: []byte("1500ms")},
+		{Key: prefix + "/timeoutms", Value: []byte("2s")},
+		{Key: prefix + "/wait", Value: []byte("1500")},
+	}
+	cfg := &tbDurationParams{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.TimeoutMS != 2000 || cfg.Seconds != 1.5 || cfg.Minutes != 120 || cfg.Bare != 250 {
+		t.Errorf("unexpected numbers: %+v", cfg)
+	}
+	if cfg.Wait != 1500*time.Millisecond {
+		t.Errorf("expected a bare number of ms, got %s", cfg.Wait)
+	}
+	if len(cfg.Backoff) != 2 || cfg.Backoff[0] != 100 || cfg.Backoff[1] != 1000 {
+		t.Errorf("unexpected backoff: %v", cfg.Backoff)
+	}
+
+	for key, value := range map[string]string{
+		"timeoutms": "500ms",
+		"minutes":   "-1m",
+		"bare":      "1500us",
+	} {
+		kvs := consulapi.KVPairs{{Key: prefix + "/" + key, Value: []byte(value)}}
+		if err := Unmarshal(prefix, kvs, &tbDurationParams{}); err == nil {
+			t.Errorf("%s: expected error for %s", key, value)
+		}
+	}
+}
+
+type tbBadDuration struct {
+	Name string `decoder:",duration=ms"`
+}
+
+type tbBadDurationUnit struct {
+	Count int `decoder:",duration=days"`
+}
+
+type tbBadLayout struct {
+	Count int `decoder:",layout=2006"`
+}
+
+type tbBadBase struct {
+	Count int `decoder:",base=99"`
+}
+
+type tbBadSep struct {
+	Names []string `decoder:",ssv,sep=;"`
+}
+
+func TestParamsInvalid(t *testing.T) {
+	for _, v := range []interface{}{&tbBadLayout{}, &tbBadBase{}, &tbBadSep{}, &tbBadDuration{}, &tbBadDurationUnit{}} {
+		if err := Unmarshal(prefix, consulapi.KVPairs{}, v); err == nil {
+			t.Errorf("%T: expected error", v)
+		}
+	}
+}
+
+func TestParamsUnknown(t *testing.T) {
+	v := &struct {
+		When time.Time `decoder:",layuot=RFC3339"`
+	}{}
+	report, err := UnmarshalReport(prefix, consulapi.KVPairs{}, v)
+	if err != nil || len(report.Warnings) != 1 {
+		t.Errorf("expected a warning for an unknown parameter, got %v, %v", report.Warnings, err)
+	}
+	d := &Decoder{UnknownModifiers: IssueError}
+	if err := d.Unmarshal(prefix, consulapi.KVPairs{}, v); err == nil {
+		t.Error("expected an error for an unknown parameter")
+	}
+}
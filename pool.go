@@ -0,0 +1,57 @@
+package decoder
+
+import (
+	"sync"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// maxPooledPairs is the capacity above which a slice of pairs isn't put
+// back in the pool, so that one huge element doesn't keep its memory for
+// good.
+const maxPooledPairs = 1024
+
+// pairsBuffer holds a slice of pairs in pairsPool.  The slice is kept
+// behind a pointer, as putting a slice itself in a pool allocates.
+type pairsBuffer struct {
+	pairs api.KVPairs
+}
+
+// pairsPool holds the slices that the pairs of structs inside of maps and
+// slices are curated into, for decoders with PoolBuffers.
+var pairsPool = sync.Pool{
+	New: func() interface{} {
+		return &pairsBuffer{pairs: make(api.KVPairs, 0, 16)}
+	},
+}
+
+// getPairs returns a buffer from the pool, or nil if the decoder doesn't
+// pool buffers.
+func (d *Decoder) getPairs() *pairsBuffer {
+	if !d.PoolBuffers {
+		return nil
+	}
+	return pairsPool.Get().(*pairsBuffer)
+}
+
+// slice returns the empty slice held by b, which is nil if b is.
+func (b *pairsBuffer) slice() api.KVPairs {
+	if b == nil {
+		return nil
+	}
+	return b.pairs[:0]
+}
+
+// putPairs returns b to the pool, holding used, the slice made from it,
+// which may have grown.  The pairs are cleared, so that the pool doesn't
+// keep them alive.
+func (d *Decoder) putPairs(b *pairsBuffer, used api.KVPairs) {
+	if b == nil || cap(used) > maxPooledPairs {
+		return
+	}
+	for i := range used {
+		used[i] = nil
+	}
+	b.pairs = used[:0]
+	pairsPool.Put(b)
+}
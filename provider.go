@@ -0,0 +1,115 @@
+package decoder
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// Provider - exposes the pairs below a prefix as a tree of maps, keyed by the
+// folders of their keys, for configuration libraries which read their
+// sources that way, so that call sites of viper or koanf can be kept while
+// the pairs are fetched and watched as for the decoder.  Its Read, ReadBytes
+// and Watch methods have the signatures of those of a koanf provider, and
+// koanf.Load(p, nil) reads the tree as is.  For viper, set the config type to
+// "json" and pass the result of ReadBytes to ReadConfig, or MergeConfig.
+// Values are strings, as viper and koanf cast them when they're read.  A
+// Provider is safe for concurrent use.
+type Provider struct {
+	d          *Decoder
+	pathPrefix string
+
+	mu       sync.Mutex
+	kvps     api.KVPairs
+	watchers []func(event interface{}, err error)
+}
+
+// NewProvider - uses the default decoder to create a provider of the pairs of
+// kvps below pathPrefix.  See Decoder.NewProvider.
+func NewProvider(pathPrefix string, kvps api.KVPairs) *Provider {
+	return defaultDecoder.NewProvider(pathPrefix, kvps)
+}
+
+// NewProvider - returns a provider of the pairs of kvps below pathPrefix.  The
+// pairs are picked as they are by Unmarshal, honoring the PathSeparator,
+// Qualifiers, IncludeKeys, ExcludeKeys, IgnoreHidden and Sessions of the
+// decoder, and their values are read as those of string fields are, honoring
+// UseFlags, Templates, TrimSpace and ServiceResolver.  Keys held by sessions
+// are kept without a warning under SessionWarn, as there is no Report to hold
+// one, and FlagJSON has no effect, as values are left as strings.
+func (d *Decoder) NewProvider(pathPrefix string, kvps api.KVPairs) *Provider {
+	return &Provider{d: d, pathPrefix: pathPrefix, kvps: kvps}
+}
+
+// Update - replaces the pairs of the provider with kvps, as fetched by a
+// watch of the prefix, and calls the functions passed to Watch.
+func (p *Provider) Update(kvps api.KVPairs) {
+	p.mu.Lock()
+	p.kvps = kvps
+	watchers := p.watchers
+	p.mu.Unlock()
+	for _, cb := range watchers {
+		cb(nil, nil)
+	}
+}
+
+// Watch - calls cb whenever Update is called, after which the tree is read
+// again, as koanf does.
+func (p *Provider) Watch(cb func(event interface{}, err error)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.watchers = append(p.watchers, cb)
+	return nil
+}
+
+// providerField is the metadata which values are read with by a Provider,
+// that of a string field without modifiers.
+var providerField = &tFieldMeta{computedType: typeString}
+
+// Read - returns the pairs below the prefix as a tree of maps.  An error is
+// returned if a key is both a value and a folder, or a value can't be read.
+func (p *Provider) Read() (map[string]interface{}, error) {
+	p.mu.Lock()
+	kvps := p.kvps
+	p.mu.Unlock()
+
+	d := p.d
+	pathPrefix, kvps := d.separatedPaths(p.pathPrefix, kvps)
+	if pathPrefix != "" && !strings.HasSuffix(pathPrefix, "/") {
+		pathPrefix += "/"
+	}
+	kvps = d.resolveQualified(pathPrefix, kvps)
+	kvps, err := d.filterPairs(pathPrefix, kvps)
+	if err != nil {
+		return nil, err
+	}
+	kvps = d.sessionPairs(&decodeState{}, pathPrefix, kvps)
+
+	tree := make(map[string]interface{})
+	for _, kvp := range kvps {
+		k, ok := d.cutPrefix(kvp.Key, pathPrefix)
+		if !ok || k == "" || strings.HasSuffix(k, "/") {
+			// Outside of the prefix, or a folder.
+			continue
+		}
+		value, err := d.pairValue(providerField, kvp)
+		if err != nil {
+			return nil, err
+		}
+		if err := insertDumped(tree, k, string(value)); err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
+
+// ReadBytes - returns the tree returned by Read as json.
+func (p *Provider) ReadBytes() ([]byte, error) {
+	tree, err := p.Read()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(tree)
+}
@@ -0,0 +1,77 @@
+package decoder
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+func TestProvider(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/", Value: nil},
+		{Key: prefix + "/db.host", Value: []byte("db.local")},
+		{Key: prefix + "/db.port", Value: []byte("5432")},
+		{Key: prefix + "/name", Value: []byte("svc")},
+		{Key: prefix + "/secret", Value: []byte("hunter2")},
+		{Key: "other/name", Value: []byte("nope")},
+	}
+	d := &Decoder{PathSeparator: ".", ExcludeKeys: []string{"secret"}}
+	p := d.NewProvider(prefix, kvs)
+	tree, err := p.Read()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := map[string]interface{}{
+		"db":   map[string]interface{}{"host": "db.local", "port": "5432"},
+		"name": "svc",
+	}
+	if !reflect.DeepEqual(tree, want) {
+		t.Errorf("expected %v, got %v", want, tree)
+	}
+
+	b, err := p.ReadBytes()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil || !reflect.DeepEqual(decoded, want) {
+		t.Errorf("expected json of %v, got %s (%v)", want, b, err)
+	}
+
+	var events int
+	if err := p.Watch(func(event interface{}, err error) { events++ }); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.Update(consulapi.KVPairs{{Key: prefix + "/name", Value: []byte("renamed")}})
+	if events != 1 {
+		t.Errorf("expected 1 event, got %d", events)
+	}
+	if tree, err = p.Read(); err != nil || tree["name"] != "renamed" {
+		t.Errorf("expected the updated pairs, got %v (%v)", tree, err)
+	}
+
+	// Pairs are picked and read as they are by Unmarshal.
+	t.Setenv("TB_PROVIDER_PORT", "6543")
+	d = &Decoder{Qualifiers: []string{"staging"}, Sessions: SessionSkip, UseFlags: true, Templates: true}
+	p = d.NewProvider(prefix, consulapi.KVPairs{
+		{Key: prefix + "/host", Value: []byte("db.local")},
+		{Key: prefix + "/host@staging", Value: []byte("db.staging")},
+		{Key: prefix + "/lock", Value: []byte("held"), Session: "s1"},
+		{Key: prefix + "/name", Value: []byte("c3Zj"), Flags: FlagBase64},
+		{Key: prefix + "/port", Value: []byte(`{{ env "TB_PROVIDER_PORT" }}`)},
+	})
+	want = map[string]interface{}{"host": "db.staging", "name": "svc", "port": "6543"}
+	if tree, err = p.Read(); err != nil || !reflect.DeepEqual(tree, want) {
+		t.Errorf("expected %v, got %v (%v)", want, tree, err)
+	}
+
+	p.Update(consulapi.KVPairs{
+		{Key: prefix + "/db", Value: []byte("x")},
+		{Key: prefix + "/db/host", Value: []byte("y")},
+	})
+	if _, err := p.Read(); err == nil {
+		t.Error("expected an error for a key that is both a value and a folder")
+	}
+}
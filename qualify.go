@@ -0,0 +1,100 @@
+package decoder
+
+import (
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// qualifierSep separates the name of a key, or of a folder, from the
+// qualifier it is given, as in "timeout@staging".
+const qualifierSep = "@"
+
+// qualifiers returns the qualifiers of the decoder, most specific first.
+func (d *Decoder) qualifiers() []string {
+	if d.Environment == "" {
+		return d.Qualifiers
+	}
+	return append(d.Qualifiers[:len(d.Qualifiers):len(d.Qualifiers)], d.Environment)
+}
+
+// resolveQualified returns kvps with the keys below pathPrefix that are
+// qualified by one of the decoder's qualifiers, as in "timeout@staging" or
+// "db@us-east-1/host", in place of the keys they override, and without the
+// keys qualified by anything else.  A key overrides the unqualified key, and
+// those qualified by less specific qualifiers.  The keys returned are
+// unqualified, and copies of the pairs are made where that changes them.
+func (d *Decoder) resolveQualified(pathPrefix string, kvps api.KVPairs) api.KVPairs {
+	quals := d.qualifiers()
+	if len(quals) == 0 {
+		return kvps
+	}
+	resolved := make(api.KVPairs, 0, len(kvps))
+	// index holds the position in resolved of each key, and ranks the
+	// position in quals of the qualifier of the pair there, with
+	// len(quals) for unqualified pairs.
+	index := make(map[string]int, len(kvps))
+	ranks := make(map[string]int, len(kvps))
+	for _, kvp := range kvps {
+		rest, ok := d.cutPrefix(kvp.Key, pathPrefix)
+		if !ok || !strings.Contains(rest, qualifierSep) {
+			d.addQualified(&resolved, index, ranks, kvp, len(quals))
+			continue
+		}
+		segments := strings.Split(rest, "/")
+		rank := len(quals)
+		for i, segment := range segments {
+			name, label, ok := strings.Cut(segment, qualifierSep)
+			if !ok {
+				continue
+			}
+			r := d.qualifierRank(quals, label)
+			if r < 0 {
+				rank = -1
+				break
+			}
+			if r < rank {
+				rank = r
+			}
+			segments[i] = name
+		}
+		if rank < 0 {
+			continue
+		}
+		cp := *kvp
+		cp.Key = kvp.Key[:len(kvp.Key)-len(rest)] + strings.Join(segments, "/")
+		d.addQualified(&resolved, index, ranks, &cp, rank)
+	}
+	return resolved
+}
+
+// addQualified adds kvp, whose qualifier is of rank, to resolved, replacing
+// a pair with the same key whose qualifier is less specific.
+func (d *Decoder) addQualified(resolved *api.KVPairs, index, ranks map[string]int, kvp *api.KVPair, rank int) {
+	key := kvp.Key
+	if !d.CaseSensitive {
+		key = strings.ToLower(key)
+	}
+	i, ok := index[key]
+	if !ok {
+		index[key] = len(*resolved)
+		ranks[key] = rank
+		*resolved = append(*resolved, kvp)
+		return
+	}
+	if rank < ranks[key] {
+		ranks[key] = rank
+		(*resolved)[i] = kvp
+	}
+}
+
+// qualifierRank returns the position of label in quals, or -1 if it isn't
+// one of them.
+func (d *Decoder) qualifierRank(quals []string, label string) int {
+	for i, q := range quals {
+		if label == q || (!d.CaseSensitive && strings.EqualFold(label, q)) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,92 @@
+package decoder
+
+import (
+	"reflect"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbQualified struct {
+	Timeout string
+	Retries int
+	DB      struct {
+		Host string
+		Port int
+	}
+	Labels map[string]string
+}
+
+func TestEnvironment(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/db/host", Value: []byte("db.local")},
+		{Key: prefix + "/db/port", Value: []byte("5432")},
+		{Key: prefix + "/db@Staging/host", Value: []byte("db.staging")},
+		{Key: prefix + "/labels/tier", Value: []byte("web")},
+		{Key: prefix + "/labels/tier@prod", Value: []byte("edge")},
+		{Key: prefix + "/retries@prod", Value: []byte("5")},
+		{Key: prefix + "/timeout@staging", Value: []byte("10s")},
+		{Key: prefix + "/timeout", Value: []byte("1s")},
+	}
+
+	tq := &tbQualified{}
+	if err := Unmarshal(prefix, kvs, tq); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tq.Timeout != "1s" || tq.Retries != 0 || tq.DB.Host != "db.local" {
+		t.Errorf("expected qualified keys to be ignored by default, got %+v", tq)
+	}
+
+	d := &Decoder{Environment: "staging"}
+	tq = &tbQualified{}
+	if err := d.Unmarshal(prefix, kvs, tq); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tq.Timeout != "10s" || tq.Retries != 0 || tq.DB.Host != "db.staging" || tq.DB.Port != 5432 {
+		t.Errorf("unexpected staging values: %+v", tq)
+	}
+	if want := map[string]string{"tier": "web"}; !reflect.DeepEqual(tq.Labels, want) {
+		t.Errorf("expected %v, got %v", want, tq.Labels)
+	}
+
+	d = &Decoder{Environment: "prod"}
+	tq = &tbQualified{}
+	if err := d.Unmarshal(prefix, kvs, tq); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tq.Timeout != "1s" || tq.Retries != 5 || tq.DB.Host != "db.local" || tq.Labels["tier"] != "edge" {
+		t.Errorf("unexpected prod values: %+v", tq)
+	}
+}
+
+func TestQualifiers(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/db/host", Value: []byte("db.local")},
+		{Key: prefix + "/db@us-east-1/host", Value: []byte("db.us-east-1")},
+		{Key: prefix + "/retries", Value: []byte("1")},
+		{Key: prefix + "/retries@prod", Value: []byte("3")},
+		{Key: prefix + "/retries@us-east-1", Value: []byte("5")},
+		{Key: prefix + "/timeout@i-0abc", Value: []byte("9s")},
+		{Key: prefix + "/timeout@prod", Value: []byte("3s")},
+		{Key: prefix + "/timeout@us-east-1", Value: []byte("5s")},
+		{Key: prefix + "/timeout@us-west-2", Value: []byte("7s")},
+	}
+
+	d := &Decoder{Environment: "prod", Qualifiers: []string{"i-0abc", "us-east-1"}}
+	tq := &tbQualified{}
+	if err := d.Unmarshal(prefix, kvs, tq); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tq.Timeout != "9s" || tq.Retries != 5 || tq.DB.Host != "db.us-east-1" {
+		t.Errorf("unexpected values: %+v", tq)
+	}
+
+	d = &Decoder{Environment: "prod", Qualifiers: []string{"us-west-2"}}
+	tq = &tbQualified{}
+	if err := d.Unmarshal(prefix, kvs, tq); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tq.Timeout != "7s" || tq.Retries != 3 || tq.DB.Host != "db.local" {
+		t.Errorf("unexpected values: %+v", tq)
+	}
+}
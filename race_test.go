@@ -0,0 +1,83 @@
+package decoder
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbConcurrentItem struct {
+	Host string
+	Port int
+}
+
+type tbConcurrentA struct {
+	Name  string
+	Items map[string]*tbConcurrentItem
+	List  []string `decoder:",csv"`
+}
+
+type tbConcurrentB struct {
+	Name    string
+	Servers []tbConcurrentItem
+}
+
+func TestConcurrentUnmarshal(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/items/a/host", Value: []byte("a.local")},
+		{Key: prefix + "/items/a/port", Value: []byte("1")},
+		{Key: prefix + "/list", Value: []byte("x,y")},
+		{Key: prefix + "/name", Value: []byte("shared")},
+		{Key: prefix + "/servers/0/host", Value: []byte("b.local")},
+		{Key: prefix + "/servers/0/port", Value: []byte("2")},
+	}
+
+	decoders := []*Decoder{
+		{},
+		{Cache: NewTypeCache(1)},
+		{CaseSensitive: false, TrimSpace: true},
+	}
+	decoders[2].RegisterTransformer("noop", func(b []byte) ([]byte, error) { return b, nil })
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 200)
+	for i := 0; i < 50; i++ {
+		for _, dec := range decoders {
+			wg.Add(1)
+			go func(dec *Decoder, i int) {
+				defer wg.Done()
+				if i%10 == 0 {
+					ClearTypeCache()
+				}
+				a := &tbConcurrentA{}
+				if err := dec.Unmarshal(prefix, kvs, a); err != nil {
+					errs <- err
+					return
+				}
+				if a.Name != "shared" || a.Items["a"] == nil || a.Items["a"].Port != 1 || len(a.List) != 2 {
+					errs <- fmt.Errorf("unexpected result: %+v", a)
+					return
+				}
+				if _, err := dec.Hash(a); err != nil {
+					errs <- err
+					return
+				}
+				b := &tbConcurrentB{}
+				if err := dec.Unmarshal(prefix, kvs, b); err != nil {
+					errs <- err
+					return
+				}
+				if b.Name != "shared" || len(b.Servers) != 1 || b.Servers[0].Port != 2 {
+					errs <- fmt.Errorf("unexpected result: %+v", b)
+				}
+			}(dec, i)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,44 @@
+package decoder
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// registry holds what is registered with a decoder, behind a pointer so that
+// the zero Decoder is ready for use without a constructor.
+type registry struct {
+	// id identifies the decoder in the keys of the type cache, so that the
+	// cache doesn't hold on to the decoder.
+	id uint64
+
+	// lck protects transformers, modifiers and middleware, which are
+	// registered with RegisterTransformer, RegisterModifier and Use, and
+	// generation, which counts the transformers and modifiers registered,
+	// so that metadata gathered before one was registered isn't used.
+	lck          sync.RWMutex
+	transformers map[string]TransformFunc
+	modifiers    map[string]ModifierFunc
+	middleware   []Middleware
+	generation   uint64
+
+	// decodedLck protects decoded, which holds the target last decoded into
+	// by CachedUnmarshal from each prefix, as a decodedTarget.
+	decodedLck sync.Mutex
+	decoded    map[decodedKey]decodedTarget
+}
+
+// registryIDs is the last id given to a registry.
+var registryIDs uint64
+
+// registry returns the registry of the decoder, creating it on first use.
+func (d *Decoder) registry() *registry {
+	if r := d.reg.Load(); r != nil {
+		return r
+	}
+	r := &registry{id: atomic.AddUint64(&registryIDs, 1)}
+	if d.reg.CompareAndSwap(nil, r) {
+		return r
+	}
+	return d.reg.Load()
+}
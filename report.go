@@ -0,0 +1,39 @@
+package decoder
+
+import (
+	"fmt"
+)
+
+// IssuePolicy - what to do about a questionable, but decodable, input.
+type IssuePolicy int
+
+const (
+	// IssueWarn - add a warning to the Report and carry on.
+	IssueWarn IssuePolicy = iota
+	// IssueError - fail the decode.
+	IssueError
+	// IssueIgnore - carry on silently.
+	IssueIgnore
+)
+
+// Report - describes a single call to Unmarshal, including anything of note
+// that was not serious enough to fail it.
+type Report struct {
+	Stats    DecodeStats
+	Warnings []Warning
+}
+
+// Warning - something of note about a key encountered while decoding.
+type Warning struct {
+	Key     string
+	Message string
+}
+
+// String - implements fmt.Stringer.
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s", w.Key, w.Message)
+}
+
+func (r *Report) warn(key, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, Warning{Key: key, Message: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,38 @@
+package decoder
+
+import (
+	"reflect"
+)
+
+// existingElem returns a pointer to the value already held by fv, the field
+// of loc, for the element at mapKey of a map of structs or for a field with
+// the json or msgpack modifiers, so that it can be decoded into in place of
+// a new one.  An invalid value is returned if there isn't one.  The struct
+// values of maps aren't addressable, so they're copied, and the copy is set
+// in place of them.
+func existingElem(loc tFieldLocator, fv reflect.Value, mapKey string) reflect.Value {
+	for i := uint8(0); i < loc.ptrCt; i++ {
+		if fv.IsNil() {
+			return reflect.Value{}
+		}
+		fv = fv.Elem()
+	}
+	switch {
+	case loc.isEncoded:
+		return fv.Addr()
+	case !loc.isMap || fv.IsNil():
+		return reflect.Value{}
+	}
+	ev := fv.MapIndex(reflect.ValueOf(mapKey).Convert(fv.Type().Key()))
+	switch {
+	case !ev.IsValid():
+		return reflect.Value{}
+	case loc.collPtrCt == 0:
+		cp := reflect.New(loc.ttype)
+		cp.Elem().Set(ev)
+		return cp
+	case loc.collPtrCt == 1 && !ev.IsNil():
+		return ev
+	}
+	return reflect.Value{}
+}
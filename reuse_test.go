@@ -0,0 +1,69 @@
+package decoder
+
+import (
+	"sync"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbReuseSvc struct {
+	mu    sync.Mutex
+	Host  string
+	Port  int
+	cache string
+}
+
+type tbReuseValue struct {
+	Host string
+	Port int
+}
+
+type tbReuseOpts struct {
+	Retries int `json:"retries"`
+	Backoff int `json:"backoff"`
+}
+
+type tbReuse struct {
+	Services map[string]*tbReuseSvc
+	Values   map[string]tbReuseValue
+	Opts     *tbReuseOpts `decoder:"opts,json"`
+}
+
+func TestReuseExisting(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/opts", Value: []byte(`{"retries": 3}`)},
+		{Key: prefix + "/services/web/host", Value: []byte("web.local")},
+		{Key: prefix + "/values/web/host", Value: []byte("web.local")},
+	}
+	web := &tbReuseSvc{Port: 80, cache: "derived"}
+	opts := &tbReuseOpts{Retries: 1, Backoff: 5}
+	tr := &tbReuse{
+		Services: map[string]*tbReuseSvc{"web": web},
+		Values:   map[string]tbReuseValue{"web": {Port: 80}},
+		Opts:     opts,
+	}
+
+	d := &Decoder{ReuseExisting: true, Merge: MergeReplace}
+	if err := d.Unmarshal(prefix, kvs, tr); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tr.Services["web"] != web || web.Host != "web.local" || web.Port != 80 || web.cache != "derived" {
+		t.Errorf("expected the service to be decoded into, got %+v", tr.Services["web"])
+	}
+	if v := tr.Values["web"]; v.Host != "web.local" || v.Port != 80 {
+		t.Errorf("expected the value to keep its port, got %+v", v)
+	}
+	if tr.Opts != opts || opts.Retries != 3 || opts.Backoff != 5 {
+		t.Errorf("expected the options to be decoded into, got %+v", tr.Opts)
+	}
+
+	// Without it, the elements are replaced.
+	tr.Services["web"] = web
+	if err := Unmarshal(prefix, kvs, tr); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tr.Services["web"] == web || tr.Opts == opts {
+		t.Error("expected new elements without ReuseExisting")
+	}
+}
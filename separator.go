@@ -0,0 +1,64 @@
+package decoder
+
+import (
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// separatedPaths returns pathPrefix and kvps with the PathSeparator of the
+// decoder replaced by "/" in the keys below pathPrefix, so that they can be
+// decoded like any others.  The pairs are copied rather than modified.
+func (d *Decoder) separatedPaths(pathPrefix string, kvps api.KVPairs) (string, api.KVPairs) {
+	sep := d.PathSeparator
+	if sep == "" || sep == "/" {
+		return pathPrefix, kvps
+	}
+	pathPrefix = strings.TrimSuffix(strings.TrimSuffix(pathPrefix, sep), "/")
+	translated := make(api.KVPairs, 0, len(kvps))
+	for _, kvp := range kvps {
+		rest := kvp.Key
+		if pathPrefix != "" {
+			var ok bool
+			if rest, ok = d.cutPrefix(kvp.Key, pathPrefix); !ok {
+				translated = append(translated, kvp)
+				continue
+			}
+			switch {
+			case strings.HasPrefix(rest, sep):
+				rest = rest[len(sep):]
+			case strings.HasPrefix(rest, "/"):
+				rest = rest[1:]
+			default:
+				translated = append(translated, kvp)
+				continue
+			}
+		}
+		tp := *kvp
+		tp.Key = pathPrefix + "/" + strings.ReplaceAll(rest, sep, "/")
+		if pathPrefix == "" {
+			tp.Key = tp.Key[1:]
+		}
+		translated = append(translated, &tp)
+	}
+	if pathPrefix != "" {
+		pathPrefix += "/"
+	}
+	return pathPrefix, translated
+}
+
+// cutPrefix returns key without prefix, and whether key began with it,
+// ignoring case unless the decoder is CaseSensitive.
+func (d *Decoder) cutPrefix(key, prefix string) (string, bool) {
+	if len(key) < len(prefix) {
+		return key, false
+	}
+	if d.CaseSensitive {
+		if key[:len(prefix)] != prefix {
+			return key, false
+		}
+	} else if !strings.EqualFold(key[:len(prefix)], prefix) {
+		return key, false
+	}
+	return key[len(prefix):], true
+}
@@ -0,0 +1,59 @@
+package decoder
+
+import (
+	"reflect"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbSeparated struct {
+	DB struct {
+		Host string
+		Port int
+	}
+	Replica string `decoder:"db.replica.host"`
+	Tags    []string
+	Labels  map[string]string
+}
+
+func TestPathSeparator(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: "legacy/app.db.host", Value: []byte("db.local")},
+		{Key: "legacy/app.db.port", Value: []byte("5432")},
+		{Key: "legacy/app.db.replica.host", Value: []byte("replica.local")},
+		{Key: "legacy/app.labels.env", Value: []byte("prod")},
+		{Key: "legacy/app.tags.0", Value: []byte("a")},
+		{Key: "legacy/app.tags.1", Value: []byte("b")},
+		{Key: "legacy/application.db.host", Value: []byte("other")},
+	}
+
+	for _, pathPrefix := range []string{"legacy/app", "legacy/app.", "Legacy/App"} {
+		cfg := &tbSeparated{}
+		report, err := (&Decoder{PathSeparator: "."}).UnmarshalReport(pathPrefix, kvs, cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if cfg.DB.Host != "db.local" || cfg.DB.Port != 5432 || cfg.Replica != "replica.local" {
+			t.Errorf("%s: unexpected values: %+v", pathPrefix, cfg)
+		}
+		if !reflect.DeepEqual(cfg.Tags, []string{"a", "b"}) || cfg.Labels["env"] != "prod" {
+			t.Errorf("%s: unexpected collections: %v %v", pathPrefix, cfg.Tags, cfg.Labels)
+		}
+		if len(report.Warnings) != 1 || report.Warnings[0].Key != "legacy/application.db.host" {
+			t.Errorf("%s: expected a warning for the key outside of the prefix, got %v", pathPrefix, report.Warnings)
+		}
+	}
+
+	kvs = consulapi.KVPairs{
+		{Key: "db:host", Value: []byte("db.local")},
+		{Key: "tags/0", Value: []byte("a")},
+	}
+	cfg := &tbSeparated{}
+	if err := (&Decoder{PathSeparator: ":"}).Unmarshal("", kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.DB.Host != "db.local" || !reflect.DeepEqual(cfg.Tags, []string{"a"}) {
+		t.Errorf("unexpected values without a prefix: %+v", cfg)
+	}
+}
@@ -0,0 +1,129 @@
+package decoder
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ServiceScheme - the scheme of values which refer to a service, as in
+// consul-service://redis?tag=primary&dc=east&connect=true.  The host is the
+// name of the service, and tag, dc and connect set the Tag, Datacenter and
+// Connect of the ServiceQuery.
+const ServiceScheme = "consul-service"
+
+// serviceTypes are the computed types that the service modifier may be used
+// with, which can hold a "host:port" address.
+var serviceTypes = map[computedType]bool{
+	typeString:          true,
+	typeInterface:       true,
+	typeTextUnmarshaler: true,
+	typeTCPAddr:         true,
+	typeUDPAddr:         true,
+}
+
+// ServiceQuery - describes the service a value refers to.
+type ServiceQuery struct {
+	// Name is the name of the service.
+	Name string
+	// Tag, if set, selects the instances with the tag.
+	Tag string
+	// Datacenter, if set, is the datacenter to look in, rather than that of
+	// the agent.
+	Datacenter string
+	// Connect selects the instances which accept Connect connections, such
+	// as sidecar proxies, rather than the service itself.
+	Connect bool
+}
+
+// ServiceResolverFunc - resolves a service to the address of a healthy
+// instance, as "host:port", which is decoded in place of the value naming
+// it.  It must be safe for concurrent use.
+type ServiceResolverFunc func(q ServiceQuery) (string, error)
+
+// HealthServiceResolver - returns a ServiceResolverFunc which asks the
+// health endpoint of consul, through client, for the passing instances of a
+// service, and picks one at random.
+func HealthServiceResolver(client *api.Client) ServiceResolverFunc {
+	return func(q ServiceQuery) (string, error) {
+		opts := &api.QueryOptions{Datacenter: q.Datacenter}
+		var entries []*api.ServiceEntry
+		var err error
+		if q.Connect {
+			entries, _, err = client.Health().Connect(q.Name, q.Tag, true, opts)
+		} else {
+			entries, _, err = client.Health().Service(q.Name, q.Tag, true, opts)
+		}
+		if err != nil {
+			return "", err
+		}
+		if len(entries) == 0 {
+			return "", fmt.Errorf("no healthy instances of service %s", q.Name)
+		}
+		entry := entries[rand.Intn(len(entries))]
+		addr := entry.Service.Address
+		if addr == "" && entry.Node != nil {
+			addr = entry.Node.Address
+		}
+		return net.JoinHostPort(addr, strconv.Itoa(entry.Service.Port)), nil
+	}
+}
+
+// isServiceURL returns true if value is of the form of ServiceScheme.
+func isServiceURL(value []byte) bool {
+	return bytes.HasPrefix(value, []byte(ServiceScheme+"://"))
+}
+
+// parseServiceQuery returns the ServiceQuery of value, which is either the
+// name of a service or of the form of ServiceScheme.
+func parseServiceQuery(value []byte) (ServiceQuery, error) {
+	if !isServiceURL(value) {
+		return ServiceQuery{Name: string(value)}, nil
+	}
+	u, err := url.Parse(string(value))
+	if err != nil {
+		return ServiceQuery{}, err
+	}
+	if u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return ServiceQuery{}, fmt.Errorf("invalid service %s", value)
+	}
+	q := ServiceQuery{Name: u.Host}
+	for name, values := range u.Query() {
+		v := values[len(values)-1]
+		switch name {
+		case "tag":
+			q.Tag = v
+		case "dc":
+			q.Datacenter = v
+		case "connect":
+			if q.Connect, err = strconv.ParseBool(v); err != nil {
+				return ServiceQuery{}, fmt.Errorf("invalid connect %q", v)
+			}
+		default:
+			return ServiceQuery{}, fmt.Errorf("unknown service parameter %s", name)
+		}
+	}
+	return q, nil
+}
+
+// resolveService returns the address of the service that value, the value
+// of the pair at key, refers to.
+func (d *Decoder) resolveService(key string, value []byte) ([]byte, error) {
+	if d.ServiceResolver == nil {
+		return nil, fmt.Errorf("unable to resolve service for %s: no ServiceResolver", key)
+	}
+	q, err := parseServiceQuery(value)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve service for %s: %s", key, err)
+	}
+	addr, err := d.ServiceResolver(q)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve service %s for %s: %s", value, key, err)
+	}
+	return []byte(addr), nil
+}
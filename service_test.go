@@ -0,0 +1,140 @@
+package decoder
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbService struct {
+	Cache    string            `decoder:",service"`
+	DB       *net.TCPAddr      `decoder:",service"`
+	Backends map[string]string `decoder:",service"`
+	Name     string
+}
+
+func TestServiceModifier(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/backends/a", Value: []byte("api")},
+		{Key: prefix + "/cache", Value: []byte("redis")},
+		{Key: prefix + "/db", Value: []byte("postgres")},
+		{Key: prefix + "/name", Value: []byte("redis")},
+	}
+	addrs := map[string]string{"api": "10.0.0.3:80", "redis": "10.0.0.1:6379", "postgres": "10.0.0.2:5432"}
+	d := &Decoder{ServiceResolver: func(q ServiceQuery) (string, error) {
+		if addr, ok := addrs[q.Name]; ok {
+			return addr, nil
+		}
+		return "", errors.New("unknown service")
+	}}
+	ts := &tbService{}
+	if err := d.Unmarshal(prefix, kvs, ts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ts.Cache != "10.0.0.1:6379" || ts.DB.String() != "10.0.0.2:5432" || ts.Backends["a"] != "10.0.0.3:80" {
+		t.Errorf("unexpected addresses: %+v", ts)
+	}
+	if ts.Name != "redis" {
+		t.Errorf("expected fields without the modifier to be left alone, got %q", ts.Name)
+	}
+
+	if err := Unmarshal(prefix, kvs, &tbService{}); err == nil {
+		t.Error("expected error without a ServiceResolver")
+	}
+	kvs[1].Value = []byte("memcached")
+	if err := d.Unmarshal(prefix, kvs, &tbService{}); err == nil {
+		t.Error("expected error for a service that can't be resolved")
+	}
+
+	bad := &struct {
+		Port int `decoder:",service"`
+	}{}
+	if err := d.Unmarshal(prefix, kvs, bad); err == nil {
+		t.Error("expected error for service on an int")
+	}
+}
+
+func TestHealthServiceResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/health/service/redis":
+			if r.URL.Query().Get("passing") != "1" || r.URL.Query().Get("tag") != "primary" {
+				t.Errorf("unexpected query %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`[{"Node": {"Address": "10.0.0.1"}, "Service": {"Port": 6379}}]`))
+		case "/v1/health/connect/redis":
+			w.Write([]byte(`[{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "10.0.0.9", "Port": 21000}}]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer srv.Close()
+	client, err := consulapi.NewClient(&consulapi.Config{Address: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resolve := HealthServiceResolver(client)
+
+	if addr, err := resolve(ServiceQuery{Name: "redis", Tag: "primary"}); err != nil || addr != "10.0.0.1:6379" {
+		t.Errorf("expected 10.0.0.1:6379, got %q, %v", addr, err)
+	}
+	if addr, err := resolve(ServiceQuery{Name: "redis", Connect: true}); err != nil || addr != "10.0.0.9:21000" {
+		t.Errorf("expected 10.0.0.9:21000, got %q, %v", addr, err)
+	}
+	if _, err := resolve(ServiceQuery{Name: "missing"}); err == nil {
+		t.Error("expected error for a service without instances")
+	}
+}
+
+func TestServiceURL(t *testing.T) {
+	var got []ServiceQuery
+	d := &Decoder{ServiceResolver: func(q ServiceQuery) (string, error) {
+		got = append(got, q)
+		return "10.0.0.1:6379", nil
+	}}
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/cache", Value: []byte("consul-service://redis?tag=primary&dc=east")},
+		{Key: prefix + "/db", Value: []byte("consul-service://postgres?connect=true")},
+		{Key: prefix + "/name", Value: []byte("consul-service://web")},
+	}
+	ts := &tbService{}
+	if err := d.Unmarshal(prefix, kvs, ts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []ServiceQuery{
+		{Name: "redis", Tag: "primary", Datacenter: "east"},
+		{Name: "postgres", Connect: true},
+		{Name: "web"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected queries %+v, got %+v", want, got)
+	}
+	if ts.Name != "10.0.0.1:6379" {
+		t.Errorf("expected values of any field to be resolved, got %q", ts.Name)
+	}
+
+	// Without a resolver, the values are left as they are.
+	ts = &tbService{}
+	if err := Unmarshal(prefix, kvs[2:], ts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ts.Name != "consul-service://web" {
+		t.Errorf("expected value to be left alone, got %q", ts.Name)
+	}
+
+	for _, value := range []string{
+		"consul-service://redis?region=east",
+		"consul-service://redis?connect=maybe",
+		"consul-service:///redis",
+	} {
+		kvs := consulapi.KVPairs{{Key: prefix + "/cache", Value: []byte(value)}}
+		if err := d.Unmarshal(prefix, kvs, &tbService{}); err == nil {
+			t.Errorf("expected error for %s", value)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package decoder
+
+import (
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// SessionMode - how keys held by a session are treated, such as the lock
+// and leader keys written under a shared prefix by `consul lock` and by
+// leader election, which come and go with the sessions holding them.
+type SessionMode int
+
+const (
+	// SessionDecode - the historical behavior.  Keys held by a session are
+	// decoded like any other.
+	SessionDecode SessionMode = iota
+	// SessionWarn - keys held by a session are decoded, with a warning
+	// naming the session added to the Report.
+	SessionWarn
+	// SessionSkip - keys held by a session are skipped, as if they weren't
+	// there, so they don't turn up in maps and slices.
+	SessionSkip
+)
+
+// sessionPairs returns kvps without the pairs below pathPrefix which are
+// held by a session, if the decoder skips them, warning about them if it
+// warns.  Pairs outside of pathPrefix are kept, so that they're dealt with
+// as usual.
+func (d *Decoder) sessionPairs(state *decodeState, pathPrefix string, kvps api.KVPairs) api.KVPairs {
+	if d.Sessions == SessionDecode {
+		return kvps
+	}
+	var kept api.KVPairs
+	for i, kvp := range kvps {
+		held := kvp.Session != "" && !strings.HasSuffix(kvp.Key, "/")
+		if held {
+			held = pathPrefix == ""
+			if !held {
+				_, held = d.cutPrefix(kvp.Key, pathPrefix)
+			}
+		}
+		switch {
+		case !held:
+		case d.Sessions == SessionWarn:
+			state.report.warn(kvp.Key, "held by session %s", kvp.Session)
+		default:
+			if kept == nil {
+				kept = make(api.KVPairs, i, len(kvps)-1)
+				copy(kept, kvps[:i])
+			}
+			continue
+		}
+		if kept != nil {
+			kept = append(kept, kvp)
+		}
+	}
+	if kept == nil {
+		return kvps
+	}
+	return kept
+}
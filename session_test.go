@@ -0,0 +1,46 @@
+package decoder
+
+import (
+	"reflect"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+func TestSessions(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/workers/a", Value: []byte("1")},
+		{Key: prefix + "/workers/b", Value: []byte("2")},
+		{Key: prefix + "/workers/leader", Value: []byte("a"), Session: "abc"},
+	}
+	type workers struct {
+		Workers map[string]string
+	}
+
+	for _, tc := range []struct {
+		mode     SessionMode
+		want     map[string]string
+		warnings int
+	}{
+		{SessionDecode, map[string]string{"a": "1", "b": "2", "leader": "a"}, 0},
+		{SessionWarn, map[string]string{"a": "1", "b": "2", "leader": "a"}, 1},
+		{SessionSkip, map[string]string{"a": "1", "b": "2"}, 0},
+	} {
+		d := &Decoder{Sessions: tc.mode}
+		w := &workers{}
+		report, err := d.UnmarshalReport(prefix, kvs, w)
+		if err != nil {
+			t.Fatalf("%d: unexpected error: %s", tc.mode, err)
+		}
+		if !reflect.DeepEqual(w.Workers, tc.want) {
+			t.Errorf("%d: expected %v, got %v", tc.mode, tc.want, w.Workers)
+		}
+		if len(report.Warnings) != tc.warnings {
+			t.Errorf("%d: expected %d warnings, got %v", tc.mode, tc.warnings, report.Warnings)
+		}
+	}
+
+	if kvs[2].Session != "abc" || len(kvs) != 3 {
+		t.Error("expected the pairs passed in to be left alone")
+	}
+}
@@ -0,0 +1,102 @@
+package decoder
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// SliceIndexMode - how the keys below a slice, other than one read from a
+// single csv or ssv value, place its elements.
+type SliceIndexMode int
+
+const (
+	// SliceIndexOrder - the historical behavior.  Elements are appended in
+	// the order of their keys, whatever those are, so "list/10" comes
+	// before "list/2".
+	SliceIndexOrder SliceIndexMode = iota
+	// SliceIndexFill - the keys are the indices of the elements, as in
+	// "list/0", "list/1" and "list/10", and indices without a key are left
+	// as zero values.
+	SliceIndexFill
+	// SliceIndexStrict - as SliceIndexFill, but indices without a key fail
+	// the decode.
+	SliceIndexStrict
+)
+
+// maxSliceIndex is the largest index allowed by the SliceIndex modes, so
+// that a stray key can't allocate a huge slice.
+const maxSliceIndex = 1<<16 - 1
+
+// indexedSlice records the elements of a slice set by index, for
+// SliceIndexStrict.
+type indexedSlice struct {
+	// folder is the key of the slice, for errors.
+	folder string
+	// start is the length of the slice before it was decoded into, as the
+	// elements already there under MergeAppend aren't gaps.
+	start int
+	set   []bool
+}
+
+// setIndex sets the element of sfield, the slice field tfm, that pair is
+// decoded into to st, growing the slice as needed.
+func (d *Decoder) setIndex(state *decodeState, tfm *tFieldMeta, pair *api.KVPair, prefix string, sfield, st reflect.Value) error {
+	trimpath := path.Join(prefix, tfm.fieldName) + "/"
+	rest, _ := d.cutPrefix(pair.Key, trimpath)
+	first, _, _ := strings.Cut(rest, "/")
+	idx, err := strconv.Atoi(first)
+	if err != nil || idx < 0 || first != strconv.Itoa(idx) {
+		return fmt.Errorf("key %s: %q is not a slice index", pair.Key, first)
+	}
+	if idx > maxSliceIndex {
+		return fmt.Errorf("key %s: slice index %d is above %d", pair.Key, idx, maxSliceIndex)
+	}
+
+	tk := touchedKey{ptr: sfield.Addr().Pointer(), typ: sfield.Type()}
+	is := state.indexed[tk]
+	if is == nil {
+		if state.indexed == nil {
+			state.indexed = make(map[touchedKey]*indexedSlice)
+		}
+		folder := strings.TrimSuffix(pair.Key[:len(pair.Key)-len(rest)], "/")
+		is = &indexedSlice{folder: folder, start: sfield.Len()}
+		state.indexed[tk] = is
+	}
+	if n := idx + 1 - sfield.Len(); n > 0 {
+		sfield.Set(reflect.AppendSlice(sfield, reflect.MakeSlice(sfield.Type(), n, n)))
+	}
+	sfield.Index(idx).Set(st)
+	for len(is.set) <= idx {
+		is.set = append(is.set, false)
+	}
+	is.set[idx] = true
+	return nil
+}
+
+// checkIndices fails the decode under SliceIndexStrict if any of the slices
+// set by index were left with gaps.  This can only be known once all of
+// their keys have been read, as "list/10" sorts before "list/2".
+func (d *Decoder) checkIndices(state *decodeState) error {
+	if d.SliceIndex != SliceIndexStrict || len(state.indexed) == 0 {
+		return nil
+	}
+	slices := make([]*indexedSlice, 0, len(state.indexed))
+	for _, is := range state.indexed {
+		slices = append(slices, is)
+	}
+	sort.Slice(slices, func(i, j int) bool { return slices[i].folder < slices[j].folder })
+	for _, is := range slices {
+		for i := is.start; i < len(is.set); i++ {
+			if !is.set[i] {
+				return fmt.Errorf("%s: no element at index %d", is.folder, i)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package decoder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbSliceIndex struct {
+	List  []string
+	Hosts []struct {
+		Addr string
+	}
+}
+
+func TestSliceIndex(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/list/0", Value: []byte("a")},
+		{Key: prefix + "/list/10", Value: []byte("c")},
+		{Key: prefix + "/list/2", Value: []byte("b")},
+		{Key: prefix + "/hosts/1/addr", Value: []byte("y")},
+		{Key: prefix + "/hosts/0/addr", Value: []byte("x")},
+	}
+
+	ts := &tbSliceIndex{}
+	if err := Unmarshal(prefix, kvs, ts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := []string{"a", "c", "b"}; !reflect.DeepEqual(ts.List, want) {
+		t.Errorf("expected key order %v, got %v", want, ts.List)
+	}
+
+	d := &Decoder{SliceIndex: SliceIndexFill}
+	ts = &tbSliceIndex{}
+	if err := d.Unmarshal(prefix, kvs, ts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := make([]string, 11)
+	want[0], want[2], want[10] = "a", "b", "c"
+	if !reflect.DeepEqual(ts.List, want) {
+		t.Errorf("expected %q, got %q", want, ts.List)
+	}
+	if len(ts.Hosts) != 2 || ts.Hosts[0].Addr != "x" || ts.Hosts[1].Addr != "y" {
+		t.Errorf("unexpected hosts: %+v", ts.Hosts)
+	}
+
+	// Decoding again replaces the elements at the same indices.
+	if err := d.Unmarshal(prefix, kvs[:1], ts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(ts.List) != 11 || ts.List[0] != "a" {
+		t.Errorf("expected the element to be replaced, got %q", ts.List)
+	}
+
+	d = &Decoder{SliceIndex: SliceIndexStrict}
+	err := d.Unmarshal(prefix, kvs, &tbSliceIndex{})
+	if err == nil || !strings.Contains(err.Error(), "list: no element at index 1") {
+		t.Errorf("expected a gap error, got %v", err)
+	}
+	ts = &tbSliceIndex{}
+	if err := d.Unmarshal(prefix, append(kvs[:1:1], kvs[3:]...), ts); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	for _, key := range []string{"/list/a", "/list/-1", "/list/01", "/list/99999999"} {
+		kvs := consulapi.KVPairs{{Key: prefix + key, Value: []byte("a")}}
+		if err := d.Unmarshal(prefix, kvs, &tbSliceIndex{}); err == nil {
+			t.Errorf("%s: expected error", key)
+		}
+	}
+}
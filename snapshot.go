@@ -0,0 +1,91 @@
+package decoder
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// NoSnapshotErr - returned by SnapshotStore.Load when nothing has been saved.
+var NoSnapshotErr = errors.New("no snapshot saved")
+
+// SnapshotStore - keeps the pairs of the last configuration that was decoded
+// successfully, so that a service can boot from it when consul can't be
+// reached.  The pairs are kept rather than the decoded struct, so they're
+// decoded the same way as pairs fetched from consul.  Implementations must be
+// safe for concurrent use.
+type SnapshotStore interface {
+	// Save replaces the snapshot with kvps.
+	Save(kvps api.KVPairs) error
+	// Load returns the pairs last saved, or NoSnapshotErr.
+	Load() (api.KVPairs, error)
+}
+
+// FileSnapshotStore - a SnapshotStore keeping the pairs in a file, in the
+// format of `consul kv export`.  The file is replaced atomically, by renaming
+// a temporary file written beside it, so a crash while saving leaves the
+// previous snapshot in place.  It is created readable only by its owner, as
+// configuration often holds secrets.
+type FileSnapshotStore struct {
+	// Path is the file the pairs are kept in.
+	Path string
+}
+
+// Save - writes kvps to the file.
+func (s *FileSnapshotStore) Save(kvps api.KVPairs) error {
+	var buf bytes.Buffer
+	if err := WriteExport(&buf, kvps); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.Path)
+}
+
+// Load - reads the pairs from the file, returning NoSnapshotErr if it
+// doesn't exist.
+func (s *FileSnapshotStore) Load() (api.KVPairs, error) {
+	f, err := os.Open(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, NoSnapshotErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadExport(f)
+}
+
+// UnmarshalSnapshot - uses the default decoder to decode the pairs saved in
+// store at pathPrefix into v.
+func UnmarshalSnapshot(pathPrefix string, store SnapshotStore, v interface{}) error {
+	return defaultDecoder.UnmarshalSnapshot(pathPrefix, store, v)
+}
+
+// UnmarshalSnapshot - this is the UnmarshalSnapshot method on a custom
+// decoder.  Same as above otherwise.
+func (d *Decoder) UnmarshalSnapshot(pathPrefix string, store SnapshotStore, v interface{}) error {
+	kvps, err := store.Load()
+	if err != nil {
+		return err
+	}
+	return d.Unmarshal(pathPrefix, kvps, v)
+}
@@ -0,0 +1,51 @@
+package decoder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+func TestFileSnapshotStore(t *testing.T) {
+	store := &FileSnapshotStore{Path: filepath.Join(t.TempDir(), "config.json")}
+	ts := &TestStruct{}
+	if err := UnmarshalSnapshot(prefix, store, ts); err != NoSnapshotErr {
+		t.Fatalf("expected NoSnapshotErr, got %v", err)
+	}
+
+	for _, value := range []string{"first", "second"} {
+		kvs := consulapi.KVPairs{
+			{Key: prefix + "/field1", Value: []byte(value), Flags: 7},
+		}
+		if err := store.Save(kvs); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	kvs, err := store.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(kvs) != 1 || string(kvs[0].Value) != "second" || kvs[0].Flags != 7 {
+		t.Errorf("unexpected pairs loaded: %+v", kvs)
+	}
+	if err := UnmarshalSnapshot(prefix, store, ts); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ts.Field1 != "second" {
+		t.Errorf("expected field1 to be second, got %q", ts.Field1)
+	}
+
+	fi, err := os.Stat(store.Path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		t.Errorf("expected snapshot to be private, got mode %s", fi.Mode())
+	}
+	matches, _ := filepath.Glob(store.Path + ".*")
+	if len(matches) != 0 {
+		t.Errorf("expected no temporary files left, got %v", matches)
+	}
+}
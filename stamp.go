@@ -0,0 +1,103 @@
+package decoder
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// parseStamp fills in the locator of tfm, a field of type t with the
+// decodedat or lastindex modifier, checking that t suits the modifier.
+func parseStamp(tfm *tFieldMeta, t reflect.Type) error {
+	loc := &tfm.locators[0]
+	for t.Kind() == reflect.Ptr {
+		loc.ptrCt++
+		t = t.Elem()
+	}
+	loc.ttype = t
+	switch {
+	case tfm.stamp == tagDecodedAt && t != timeType:
+		return fmt.Errorf("%s: decodedat may only be used with time.Time", tfm.fieldName)
+	case tfm.stamp == tagLastIndex && t.Kind() != reflect.Uint64:
+		return fmt.Errorf("%s: lastindex may only be used with uint64", tfm.fieldName)
+	case tfm.stamp == tagExists && t.Kind() != reflect.Bool:
+		return fmt.Errorf("%s: exists may only be used with bool", tfm.fieldName)
+	}
+	return nil
+}
+
+// nestedStamps returns copies of stamps, the stamps of a nested struct,
+// located from the struct holding it by locators.  parent is the field of
+// the nested struct, whose folder holds the keys of exists fields, or nil
+// for embedded structs, whose fields are promoted.
+func nestedStamps(parent *tFieldMeta, locators []tFieldLocator, stamps []*tFieldMeta) []*tFieldMeta {
+	nested := make([]*tFieldMeta, 0, len(stamps))
+	for _, stfm := range stamps {
+		cp := &tFieldMeta{}
+		*cp = *stfm
+		cp.locators = append(append([]tFieldLocator(nil), locators...), stfm.locators...)
+		if parent != nil {
+			cp.fieldName = path.Join(parent.fieldName, stfm.fieldName)
+			cp.name = path.Join(parent.name, stfm.name)
+		}
+		nested = append(nested, cp)
+	}
+	return nested
+}
+
+// setStamps sets the fields of val with the decodedat, lastindex and exists
+// modifiers, to when the decode started, the highest ModifyIndex of the
+// pairs in kvps below pathPrefix, and whether the key of the field, or any
+// key below it, is in kvps respectively.
+func (d *Decoder) setStamps(state *decodeState, pathPrefix string, kvps api.KVPairs, val reflect.Value, stamps []*tFieldMeta) {
+	var lastIndex uint64
+	for _, kvp := range kvps {
+		if _, ok := d.cutPrefix(kvp.Key, pathPrefix); ok && kvp.ModifyIndex > lastIndex {
+			lastIndex = kvp.ModifyIndex
+		}
+	}
+	for _, tfm := range stamps {
+		fv := val
+		for _, loc := range tfm.locators {
+			fv = fv.Field(loc.ind)
+			for i := uint8(0); i < loc.ptrCt; i++ {
+				if fv.IsNil() {
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+		}
+		switch tfm.stamp {
+		case tagDecodedAt:
+			fv.Set(reflect.ValueOf(state.start))
+		case tagLastIndex:
+			fv.SetUint(lastIndex)
+		case tagExists:
+			fv.SetBool(d.keyExists(pathPrefix, kvps, tfm.fieldName))
+		}
+	}
+}
+
+// keyExists returns true if name, or a folder of that name, is below
+// pathPrefix in kvps.
+func (d *Decoder) keyExists(pathPrefix string, kvps api.KVPairs, name string) bool {
+	for _, kvp := range kvps {
+		rest, ok := d.cutPrefix(kvp.Key, pathPrefix)
+		if !ok {
+			continue
+		}
+		if !d.CaseSensitive {
+			rest = strings.ToLower(rest)
+		}
+		if rest == name || strings.HasPrefix(rest, name+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package decoder
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbStampSvc struct {
+	Host      string
+	LastIndex uint64 `decoder:",lastindex"`
+}
+
+type tbStampMeta struct {
+	DecodedAt *time.Time `decoder:",decodedat"`
+}
+
+type tbStamped struct {
+	Name      string
+	DecodedAt time.Time `decoder:",decodedat"`
+	LastIndex uint64    `decoder:",lastindex"`
+	Meta      *tbStampMeta
+	Services  map[string]tbStampSvc
+}
+
+func TestStamps(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/decodedat", Value: []byte("ignored"), ModifyIndex: 3},
+		{Key: prefix + "/name", Value: []byte("svc"), ModifyIndex: 7},
+		{Key: prefix + "/services/a/host", Value: []byte("a.local"), ModifyIndex: 12},
+		{Key: prefix + "/services/b/host", Value: []byte("b.local"), ModifyIndex: 5},
+		{Key: "other/name", Value: []byte("other"), ModifyIndex: 99},
+	}
+
+	before := time.Now()
+	cfg := &tbStamped{}
+	if err := (&Decoder{OutsidePrefix: IssueIgnore}).Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.DecodedAt.Before(before) || cfg.DecodedAt.After(time.Now()) {
+		t.Errorf("unexpected decodedat: %s", cfg.DecodedAt)
+	}
+	if cfg.Meta == nil || cfg.Meta.DecodedAt == nil || !cfg.Meta.DecodedAt.Equal(cfg.DecodedAt) {
+		t.Errorf("unexpected nested decodedat: %+v", cfg.Meta)
+	}
+	if cfg.LastIndex != 12 {
+		t.Errorf("expected last index 12, got %d", cfg.LastIndex)
+	}
+	if cfg.Services["a"].LastIndex != 12 || cfg.Services["b"].LastIndex != 5 {
+		t.Errorf("unexpected last index of services: %+v", cfg.Services)
+	}
+
+	// Stamps don't contribute to the hash.
+	h1, _ := Hash(&tbStamped{Name: "svc"})
+	h2, _ := Hash(&tbStamped{Name: "svc", DecodedAt: time.Now(), LastIndex: 1})
+	if h1 != h2 {
+		t.Error("expected stamps not to change the hash")
+	}
+
+	type tbBadDecodedAt struct {
+		At string `decoder:",decodedat"`
+	}
+	type tbBadLastIndex struct {
+		Index int `decoder:",lastindex"`
+	}
+	for _, v := range []interface{}{&tbBadDecodedAt{}, &tbBadLastIndex{}} {
+		if err := Unmarshal(prefix, kvs, v); err == nil || !strings.Contains(err.Error(), "may only be used with") {
+			t.Errorf("expected type error for %T, got %v", v, err)
+		}
+	}
+}
+
+type tbExistsFeatures struct {
+	NewCheckout bool  `decoder:"new-checkout,exists"`
+	DarkMode    *bool `decoder:"dark-mode,exists"`
+}
+
+type tbExists struct {
+	Name     string
+	Features tbExistsFeatures
+	Beta     bool `decoder:",exists"`
+}
+
+type tbBadExists struct {
+	Name string `decoder:",exists"`
+}
+
+func TestExists(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/beta/", Value: nil},
+		{Key: prefix + "/features/New-Checkout", Value: []byte("false")},
+		{Key: prefix + "/name", Value: []byte("svc")},
+	}
+	cfg := &tbExists{}
+	if err := Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !cfg.Features.NewCheckout || !cfg.Beta {
+		t.Errorf("expected flags whose keys exist to be set: %+v", cfg)
+	}
+	if cfg.Features.DarkMode == nil || *cfg.Features.DarkMode {
+		t.Errorf("expected dark-mode to be false, got %v", cfg.Features.DarkMode)
+	}
+	h1, _ := Hash(cfg)
+
+	// Deleting the key clears the flag on the next decode.
+	if err := Unmarshal(prefix, kvs[2:], cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Features.NewCheckout || cfg.Beta {
+		t.Errorf("expected flags without keys to be cleared: %+v", cfg)
+	}
+	if h2, _ := Hash(cfg); h1 == h2 {
+		t.Error("expected exists fields to change the hash")
+	}
+
+	if err := Unmarshal(prefix, kvs, &tbBadExists{}); err == nil || !strings.Contains(err.Error(), "exists") {
+		t.Errorf("expected error for exists on a string, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package decoder
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// collectionValue returns the map or slice v points to, if it is a non-nil
+// pointer to one.
+func collectionValue(v interface{}) (reflect.Value, bool) {
+	valp := reflect.ValueOf(v)
+	if valp.Kind() != reflect.Ptr || valp.IsNil() {
+		return reflect.Value{}, false
+	}
+	val := valp.Elem()
+	if val.Kind() != reflect.Map && val.Kind() != reflect.Slice {
+		return reflect.Value{}, false
+	}
+	return val, true
+}
+
+// wrapperType returns a struct with t as its only field.  A map or slice
+// passed to Unmarshal is decoded as the field of such a struct, which is
+// found at the prefix itself rather than in a folder below it.
+func wrapperType(t reflect.Type) reflect.Type {
+	return reflect.StructOf([]reflect.StructField{{Name: "Value", Type: t}})
+}
+
+// collectionMeta returns the metadata of the wrapper for t, with the field
+// keyed by the empty string, so that its folder is the prefix.
+func (c *TypeCache) collectionMeta(d *Decoder, t reflect.Type) (*tMeta, bool, error) {
+	return c.meta(d.cacheKey(t, true), true, func() (*tMeta, error) {
+		wm, err := d.parseStruct(wrapperType(t))
+		if err != nil {
+			return nil, err
+		}
+		if len(wm.tFieldsMetaMap) != 1 {
+			return nil, fmt.Errorf("unable to decode into %s", t)
+		}
+		tm := &tMeta{tFieldsMetaMap: make(map[string]*tFieldMeta, 1), unknown: wm.unknown}
+		for _, tfm := range wm.tFieldsMetaMap {
+			tfmcp := &tFieldMeta{}
+			*tfmcp = *tfm
+			tfmcp.fieldName = ""
+			tm.tFieldsMetaMap[""] = tfmcp
+		}
+		return tm, nil
+	})
+}
+
+// unmarshalCollection decodes the keys below pathPrefix into target, a
+// map or slice, as if they were the keys of a map or slice field.
+func (d *Decoder) unmarshalCollection(state *decodeState, pathPrefix string, kvps api.KVPairs, target reflect.Value) error {
+	meta, hit, err := d.typeCache().collectionMeta(d, target.Type())
+	if err != nil {
+		return err
+	}
+	if hit {
+		state.report.Stats.CacheHits++
+	} else {
+		state.report.Stats.CacheMisses++
+	}
+	if err := d.checkModifiers(state, meta); err != nil {
+		return err
+	}
+
+	wrapper := reflect.New(wrapperType(target.Type())).Elem()
+	wrapper.Field(0).Set(target)
+	err = d.decodePairs(state, pathPrefix, kvps, wrapper, meta)
+	target.Set(wrapper.Field(0))
+	return err
+}
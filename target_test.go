@@ -0,0 +1,68 @@
+package decoder
+
+import (
+	"strings"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbTargetItem struct {
+	Host string
+	Port int
+}
+
+func TestUnmarshalCollectionTargets(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/a", Value: []byte("1")},
+		{Key: prefix + "/b", Value: []byte("2")},
+	}
+	m := map[string]string{"kept": "yes"}
+	if err := Unmarshal(prefix, kvs, &m); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(m) != 3 || m["a"] != "1" || m["b"] != "2" || m["kept"] != "yes" {
+		t.Errorf("unexpected map: %v", m)
+	}
+
+	var list []int
+	if err := Unmarshal(prefix, kvs, &list); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(list) != 2 || list[1] != 2 {
+		t.Errorf("unexpected list: %v", list)
+	}
+
+	kvs = consulapi.KVPairs{
+		{Key: prefix + "/db/host", Value: []byte("db.local")},
+		{Key: prefix + "/db/port", Value: []byte("5432")},
+		{Key: prefix + "/cache/host", Value: []byte("cache.local")},
+	}
+	var services map[string]*tbTargetItem
+	if err := Unmarshal(prefix, kvs, &services); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(services) != 2 || services["db"].Port != 5432 || services["cache"].Host != "cache.local" {
+		t.Errorf("unexpected services: %v", services)
+	}
+
+	var items []tbTargetItem
+	if err := Unmarshal(prefix, kvs, &items); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(items) != 2 || items[0].Host != "cache.local" || items[1].Port != 5432 {
+		t.Errorf("unexpected items: %v", items)
+	}
+
+	var notAllowed string
+	err := Unmarshal(prefix, kvs, &notAllowed)
+	if err != InvalidValueErr {
+		t.Errorf("expected InvalidValueErr, got %v", err)
+	}
+
+	var bad map[string]map[string]string
+	err = Unmarshal(prefix, kvs, &bad)
+	if err == nil || !strings.Contains(err.Error(), "maps to maps") {
+		t.Errorf("expected maps to maps error, got %v", err)
+	}
+}
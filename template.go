@@ -0,0 +1,213 @@
+package decoder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// templateFuncs are the consul-template functions understood when the
+// decoder's Templates is set.  Each is given its arguments, followed by the
+// result of the previous command of the pipeline, if there is one.  Only
+// these are supported, rather than text/template, so that a value can't do
+// anything more than read the environment.
+var templateFuncs = map[string]func(args []string) (string, error){
+	// env "NAME" gives the value of the environment variable NAME.
+	"env": func(args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("env takes 1 argument, got %d", len(args))
+		}
+		return os.Getenv(args[0]), nil
+	},
+	// envOrDefault "NAME" "value" gives value if NAME is unset or empty.
+	"envOrDefault": func(args []string) (string, error) {
+		if len(args) != 2 {
+			return "", fmt.Errorf("envOrDefault takes 2 arguments, got %d", len(args))
+		}
+		if v := os.Getenv(args[0]); v != "" {
+			return v, nil
+		}
+		return args[1], nil
+	},
+	// default "value" gives value if the result piped to it is empty.
+	"default": func(args []string) (string, error) {
+		if len(args) != 2 {
+			return "", fmt.Errorf("default takes 1 argument and a piped value, got %d", len(args))
+		}
+		if args[1] != "" {
+			return args[1], nil
+		}
+		return args[0], nil
+	},
+}
+
+// expandTemplate returns value with each of its {{ }} actions replaced by
+// the result of its pipeline, as in {{ env "PORT" | default "8080" }}.
+func expandTemplate(value []byte) ([]byte, error) {
+	if !bytes.Contains(value, []byte("{{")) {
+		return value, nil
+	}
+	var b bytes.Buffer
+	s := string(value)
+	for {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			b.WriteString(s)
+			return b.Bytes(), nil
+		}
+		end := templateActionLen(s[start:])
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated action: %s", s[start:])
+		}
+		result, err := templatePipeline(s[start+2 : start+end])
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(s[:start])
+		b.WriteString(result)
+		s = s[start+end+2:]
+	}
+}
+
+// templatePipeline returns the result of the commands of an action,
+// separated by "|".
+func templatePipeline(action string) (string, error) {
+	tokens, err := templateTokens(action)
+	if err != nil {
+		return "", err
+	}
+	var result string
+	piped := false
+	for len(tokens) > 0 {
+		var cmd []templateToken
+		i := 0
+		for i < len(tokens) && !tokens[i].pipe {
+			i++
+		}
+		cmd, tokens = tokens[:i], tokens[i:]
+		if len(tokens) > 0 {
+			tokens = tokens[1:]
+			if len(tokens) == 0 {
+				return "", fmt.Errorf("missing command after | in {{%s}}", action)
+			}
+		}
+		if len(cmd) == 0 {
+			return "", fmt.Errorf("empty command in {{%s}}", action)
+		}
+		if result, err = templateCommand(cmd, result, piped); err != nil {
+			return "", fmt.Errorf("%s in {{%s}}", err, action)
+		}
+		piped = true
+	}
+	if !piped {
+		return "", fmt.Errorf("empty action {{%s}}", action)
+	}
+	return result, nil
+}
+
+// templateCommand returns the result of cmd, a function and its arguments,
+// or a lone string, given the result of the previous command if piped.
+func templateCommand(cmd []templateToken, in string, piped bool) (string, error) {
+	if cmd[0].str {
+		if len(cmd) > 1 || piped {
+			return "", fmt.Errorf("unexpected string %q", cmd[0].text)
+		}
+		return cmd[0].text, nil
+	}
+	fn, ok := templateFuncs[cmd[0].text]
+	if !ok {
+		return "", fmt.Errorf("unsupported function %q", cmd[0].text)
+	}
+	args := make([]string, 0, len(cmd))
+	for _, t := range cmd[1:] {
+		if !t.str {
+			return "", fmt.Errorf("expected a string argument to %s, got %s", cmd[0].text, t.text)
+		}
+		args = append(args, t.text)
+	}
+	if piped {
+		args = append(args, in)
+	}
+	return fn(args)
+}
+
+// templateToken is a function name, a string or a "|" in an action.
+type templateToken struct {
+	text      string
+	str, pipe bool
+}
+
+// templateTokens splits an action into its tokens.
+func templateTokens(action string) ([]templateToken, error) {
+	var tokens []templateToken
+	s := action
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			return tokens, nil
+		}
+		switch c := s[0]; {
+		case c == '|':
+			tokens = append(tokens, templateToken{text: "|", pipe: true})
+			s = s[1:]
+		case c == '"' || c == '`':
+			n := templateStringLen(s)
+			if n < 0 {
+				return nil, fmt.Errorf("unterminated string in {{%s}}", action)
+			}
+			text, err := strconv.Unquote(s[:n])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s in {{%s}}", s[:n], action)
+			}
+			tokens = append(tokens, templateToken{text: text, str: true})
+			s = s[n:]
+		default:
+			n := strings.IndexAny(s, " \t\r\n|\"`")
+			if n < 0 {
+				n = len(s)
+			}
+			tokens = append(tokens, templateToken{text: s[:n]})
+			s = s[n:]
+		}
+	}
+}
+
+// templateActionLen returns the index of the "}}" ending the action at the
+// start of s, skipping over any quoted strings, which may hold "}}"
+// themselves, or -1 if it isn't terminated.
+func templateActionLen(s string) int {
+	for i := 2; i < len(s); i++ {
+		switch s[i] {
+		case '"', '`':
+			n := templateStringLen(s[i:])
+			if n < 0 {
+				return -1
+			}
+			i += n - 1
+		case '}':
+			if strings.HasPrefix(s[i:], "}}") {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// templateStringLen returns the length of the quoted string at the start of
+// s, or -1 if it isn't terminated.
+func templateStringLen(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return -1
+}
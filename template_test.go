@@ -0,0 +1,65 @@
+package decoder
+
+import (
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbTemplates struct {
+	Port  int
+	Host  string `decoder:",trim"`
+	URL   string
+	Plain string
+}
+
+func TestTemplates(t *testing.T) {
+	t.Setenv("TB_TEMPLATE_HOST", " db.local ")
+	t.Setenv("TB_TEMPLATE_EMPTY", "")
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/port", Value: []byte(`{{ env "TB_TEMPLATE_PORT" | default "8080" }}`)},
+		{Key: prefix + "/host", Value: []byte(`{{env "TB_TEMPLATE_HOST"}}`)},
+		{Key: prefix + "/url", Value: []byte(`http://{{ envOrDefault "TB_TEMPLATE_EMPTY" "localhost" }}:{{ "80" }}/`)},
+		{Key: prefix + "/plain", Value: []byte(`{{ env "TB_TEMPLATE_HOST" }}`)},
+	}
+
+	tt := &tbTemplates{}
+	if err := Unmarshal(prefix, kvs[3:], tt); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tt.Plain != `{{ env "TB_TEMPLATE_HOST" }}` {
+		t.Errorf("expected templates to be left alone by default, got %q", tt.Plain)
+	}
+
+	d := &Decoder{Templates: true}
+	tt = &tbTemplates{}
+	if err := d.Unmarshal(prefix, kvs, tt); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tt.Port != 8080 || tt.Host != "db.local" || tt.URL != "http://localhost:80/" {
+		t.Errorf("unexpected values: %+v", tt)
+	}
+
+	kvs = consulapi.KVPairs{{Key: prefix + "/plain", Value: []byte(`a{{ env "TB_TEMPLATE_EMPTY" | default "}}" }}b{{ "{{" }}`)}}
+	tt = &tbTemplates{}
+	if err := d.Unmarshal(prefix, kvs, tt); err != nil || tt.Plain != "a}}b{{" {
+		t.Errorf("expected quoted braces to be left to the strings, got %q, %v", tt.Plain, err)
+	}
+
+	for _, value := range []string{
+		`{{ env "A"`,
+		`{{ }}`,
+		`{{ printf "%d" 1 }}`,
+		`{{ env "A" "B" }}`,
+		`{{ env "A" | }}`,
+		`{{ env "A" | "B" }}`,
+		`{{ env A }}`,
+		`{{ default "x" }}`,
+		`{{ env "A }}`,
+	} {
+		kvs := consulapi.KVPairs{{Key: prefix + "/plain", Value: []byte(value)}}
+		if err := d.Unmarshal(prefix, kvs, &tbTemplates{}); err == nil {
+			t.Errorf("%s: expected error", value)
+		}
+	}
+}
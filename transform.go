@@ -0,0 +1,153 @@
+package decoder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// TransformFunc - transforms the raw bytes of a value before they are
+// interpreted, such as by decoding or decompressing them.
+type TransformFunc func(value []byte) ([]byte, error)
+
+// builtinTransformers are available to every decoder.  gzip is applied by
+// the decoder, as what it decompresses to is bounded by its MaxValueSize.
+var builtinTransformers = map[string]TransformFunc{
+	"base64": decodeBase64,
+	"gzip":   nil,
+	"hex":    decodeHex,
+}
+
+// RegisterTransformer - makes fn available as a tag modifier named name,
+// so that `decoder:"cert,name"` causes fn to be applied to the value of the
+// cert key before it is interpreted.  When a field has several transformer
+// modifiers, they are applied left to right.  Transformers must be
+// registered before the decoder is first used.  ReservedNameErr is returned
+// for the names of the built in modifiers and transformers, such as json or
+// gzip, which can't be replaced.
+func (d *Decoder) RegisterTransformer(name string, fn TransformFunc) error {
+	if isReservedModifier(name) {
+		return fmt.Errorf("transformer %s: %w", name, ReservedNameErr)
+	}
+	reg := d.registry()
+	reg.lck.Lock()
+	if reg.transformers == nil {
+		reg.transformers = make(map[string]TransformFunc)
+	}
+	reg.transformers[name] = fn
+	reg.generation++
+	reg.lck.Unlock()
+
+	// The metadata gathered before now is of no more use.  This is done
+	// once the lock is released, as the registry is read while the cache
+	// is locked by parsing.
+	d.typeCache().evict(reg.id)
+	return nil
+}
+
+// transformer returns the transformer registered as name, if any.
+func (d *Decoder) transformer(name string) (TransformFunc, bool) {
+	reg := d.registry()
+	reg.lck.RLock()
+	fn, ok := reg.transformers[name]
+	reg.lck.RUnlock()
+	if ok {
+		return fn, true
+	}
+	fn, ok = builtinTransformers[name]
+	return fn, ok
+}
+
+// ModifierFunc - decodes value into v, which is a pointer to a new value of
+// the type of the field, with any pointers removed.  This is the same
+// contract as json.Unmarshal.
+type ModifierFunc func(value []byte, v interface{}) error
+
+// RegisterModifier - makes fn available as a tag modifier named name, so
+// that fields tagged `decoder:"key,name"` are decoded by fn, rather than
+// being interpreted by the decoder.  Such fields are treated as a single
+// value, like fields with the json modifier, whatever their type.  This
+// allows applications to support formats such as PEM or JWKS.  Modifiers
+// must be registered before the decoder is first used.  ReservedNameErr is
+// returned for the names of the built in modifiers and transformers.
+func (d *Decoder) RegisterModifier(name string, fn ModifierFunc) error {
+	if isReservedModifier(name) {
+		return fmt.Errorf("modifier %s: %w", name, ReservedNameErr)
+	}
+	reg := d.registry()
+	reg.lck.Lock()
+	if reg.modifiers == nil {
+		reg.modifiers = make(map[string]ModifierFunc)
+	}
+	reg.modifiers[name] = fn
+	reg.generation++
+	reg.lck.Unlock()
+
+	// As for RegisterTransformer.
+	d.typeCache().evict(reg.id)
+	return nil
+}
+
+// modifier returns the modifier registered as name, if any.
+func (d *Decoder) modifier(name string) (ModifierFunc, bool) {
+	reg := d.registry()
+	reg.lck.RLock()
+	defer reg.lck.RUnlock()
+	fn, ok := reg.modifiers[name]
+	return fn, ok
+}
+
+// namedTransform is a transformer along with the name it was given in the
+// struct tag, for error messages.  fn is nil for the encrypted and gzip
+// modifiers, as the Decryptor and MaxValueSize belong to the decoder doing
+// the decode.
+type namedTransform struct {
+	name string
+	fn   TransformFunc
+}
+
+func decodeBase64(value []byte) ([]byte, error) {
+	b := make([]byte, base64.StdEncoding.DecodedLen(len(value)))
+	n, err := base64.StdEncoding.Decode(b, value)
+	if err != nil {
+		return nil, err
+	}
+	return b[:n], nil
+}
+
+// gunzip decompresses value, failing if it decompresses to more than limit
+// bytes, unless limit is zero.
+func gunzip(value []byte, limit int) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(value))
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return io.ReadAll(zr)
+	}
+	b, err := io.ReadAll(io.LimitReader(zr, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > limit {
+		return nil, fmt.Errorf("%w: decompresses to more than %d bytes", LimitExceededErr, limit)
+	}
+	return b, nil
+}
+
+// gunzip is gunzip bounded by the MaxValueSize of the decoder.
+func (d *Decoder) gunzip(value []byte) ([]byte, error) {
+	return gunzip(value, d.MaxValueSize)
+}
+
+func decodeHex(value []byte) ([]byte, error) {
+	b := make([]byte, hex.DecodedLen(len(value)))
+	n, err := hex.Decode(b, value)
+	if err != nil {
+		return nil, err
+	}
+	return b[:n], nil
+}
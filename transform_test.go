@@ -0,0 +1,179 @@
+package decoder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	consulapi "github.com/hashicorp/consul/api"
+)
+
+type tbTransform struct {
+	Cert    []byte   `decoder:"cert,base64,gzip"`
+	Hex     string   `decoder:",hex"`
+	Reverse []string `decoder:",reverse,csv"`
+	Upper   string   `decoder:",upper,trim"`
+}
+
+func TestTransformers(t *testing.T) {
+	var zipped bytes.Buffer
+	zw := gzip.NewWriter(&zipped)
+	_, _ = zw.Write([]byte("certificate"))
+	_ = zw.Close()
+
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/cert", Value: []byte(base64.StdEncoding.EncodeToString(zipped.Bytes()))},
+		{Key: prefix + "/hex", Value: []byte("6869")},
+		{Key: prefix + "/reverse", Value: []byte("c,b,a")},
+		{Key: prefix + "/upper", Value: []byte(" upper ")},
+	}
+
+	dec := &Decoder{}
+	dec.RegisterTransformer("reverse", func(value []byte) ([]byte, error) {
+		reversed := make([]byte, len(value))
+		for i, b := range value {
+			reversed[len(value)-1-i] = b
+		}
+		return reversed, nil
+	})
+	dec.RegisterTransformer("upper", func(value []byte) ([]byte, error) {
+		return bytes.ToUpper(value), nil
+	})
+
+	cfg := &tbTransform{}
+	if err := dec.Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(cfg.Cert) != "certificate" || cfg.Hex != "hi" || strings.Join(cfg.Reverse, "") != "abc" || cfg.Upper != "UPPER" {
+		t.Errorf("unexpected result: %+v", cfg)
+	}
+
+	// What values decode to is bounded by MaxValueSize, as they are.
+	limited := &Decoder{MaxValueSize: 100}
+	kvs = consulapi.KVPairs{{Key: prefix + "/hex", Value: []byte("6869")}}
+	if err := limited.Unmarshal(prefix, kvs, &tbTransform{}); err != nil {
+		t.Errorf("unexpected error within MaxValueSize: %s", err)
+	}
+	zipped.Reset()
+	zw = gzip.NewWriter(&zipped)
+	_, _ = zw.Write(bytes.Repeat([]byte("a"), 1000))
+	_ = zw.Close()
+	kvs = consulapi.KVPairs{{Key: prefix + "/cert", Value: []byte(base64.StdEncoding.EncodeToString(zipped.Bytes()))}}
+	if err := limited.Unmarshal(prefix, kvs, &tbTransform{}); !errors.Is(err, LimitExceededErr) {
+		t.Errorf("expected LimitExceededErr, got %v", err)
+	}
+
+	kvs = consulapi.KVPairs{{Key: prefix + "/cert", Value: []byte("bm90IHppcHBlZA==")}}
+	err := dec.Unmarshal(prefix, kvs, &tbTransform{})
+	if err == nil || !strings.Contains(err.Error(), "unable to apply gzip") {
+		t.Errorf("expected gzip error, got %v", err)
+	}
+
+	for _, name := range []string{"json", "gzip", "base64", "hex"} {
+		if err := dec.RegisterTransformer(name, nil); !errors.Is(err, ReservedNameErr) {
+			t.Errorf("%s: expected ReservedNameErr, got %v", name, err)
+		}
+	}
+}
+
+type tbTransformOwn struct {
+	Name string `decoder:",mangle"`
+}
+
+func TestTransformersPerDecoder(t *testing.T) {
+	kvs := consulapi.KVPairs{{Key: prefix + "/name", Value: []byte("Name")}}
+
+	upper, lower := &Decoder{}, &Decoder{}
+	upper.RegisterTransformer("mangle", func(value []byte) ([]byte, error) {
+		return bytes.ToUpper(value), nil
+	})
+	lower.RegisterTransformer("mangle", func(value []byte) ([]byte, error) {
+		return bytes.ToLower(value), nil
+	})
+
+	for _, tc := range []struct {
+		dec  *Decoder
+		want string
+	}{{upper, "NAME"}, {lower, "name"}} {
+		cfg := &tbTransformOwn{}
+		if err := tc.dec.Unmarshal(prefix, kvs, cfg); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if cfg.Name != tc.want {
+			t.Errorf("expected %s, got %s", tc.want, cfg.Name)
+		}
+	}
+
+}
+
+func TestDecoderNoCopy(t *testing.T) {
+	// go vet reports copies of types holding a sync.Locker, which is how
+	// copies of a Decoder are caught.
+	field, ok := reflect.TypeOf(Decoder{}).FieldByName("reg")
+	if !ok {
+		t.Fatal("expected the registry field")
+	}
+	locker := reflect.TypeOf((*sync.Locker)(nil)).Elem()
+	found := false
+	for i := 0; i < field.Type.NumField(); i++ {
+		if reflect.PtrTo(field.Type.Field(i).Type).Implements(locker) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected %s to be reported by go vet when copied", field.Type)
+	}
+}
+
+type tbModifier struct {
+	Pairs map[string]string `decoder:",pairs"`
+	Shout string            `decoder:",shout"`
+	Ptr   *[]string         `decoder:"ptr,pairs2"`
+}
+
+func TestRegisterModifier(t *testing.T) {
+	kvs := consulapi.KVPairs{
+		{Key: prefix + "/pairs", Value: []byte("a=1;b=2")},
+		{Key: prefix + "/shout", Value: []byte("quiet")},
+		{Key: prefix + "/ptr", Value: []byte("x;y")},
+	}
+
+	dec := &Decoder{}
+	dec.RegisterModifier("pairs", func(value []byte, v interface{}) error {
+		m := make(map[string]string)
+		for _, kv := range strings.Split(string(value), ";") {
+			k, val, _ := strings.Cut(kv, "=")
+			m[k] = val
+		}
+		*v.(*map[string]string) = m
+		return nil
+	})
+	dec.RegisterModifier("shout", func(value []byte, v interface{}) error {
+		*v.(*string) = strings.ToUpper(string(value)) + "!"
+		return nil
+	})
+	dec.RegisterModifier("pairs2", func(value []byte, v interface{}) error {
+		*v.(*[]string) = strings.Split(string(value), ";")
+		return nil
+	})
+
+	cfg := &tbModifier{}
+	if err := dec.Unmarshal(prefix, kvs, cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.Pairs["a"] != "1" || cfg.Pairs["b"] != "2" || cfg.Shout != "QUIET!" ||
+		cfg.Ptr == nil || strings.Join(*cfg.Ptr, ",") != "x,y" {
+		t.Errorf("unexpected result: %+v", cfg)
+	}
+
+	for _, name := range []string{"csv", "gzip"} {
+		if err := dec.RegisterModifier(name, nil); !errors.Is(err, ReservedNameErr) {
+			t.Errorf("%s: expected ReservedNameErr, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package decoder
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/fs"
+	"net"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// specialTypes are the types from other packages which aren't decoded
+// according to their kind.  Types are matched exactly, so those defined in
+// terms of them, as in `type Timeout time.Duration`, are decoded according
+// to their kind like any other defined type.  Other types are recognized by
+// their kind alone, whatever their name or package, so `type Port int` is an
+// int and `type Hosts []string` is a slice of string.
+var specialTypes = map[reflect.Type]computedType{
+	reflect.TypeOf(time.Duration(0)):    typeDuration,
+	reflect.TypeOf(time.Time{}):         typeTime,
+	reflect.TypeOf(json.Number("")):     typeJSONNumber,
+	reflect.TypeOf(fs.FileMode(0)):      typeFileMode,
+	reflect.TypeOf(net.IP{}):            typeNetIP,
+	reflect.TypeOf(net.IPMask{}):        typeNetMask,
+	reflect.TypeOf(net.HardwareAddr{}):  typeHardwareAddr,
+	reflect.TypeOf(net.TCPAddr{}):       typeTCPAddr,
+	reflect.TypeOf(net.UDPAddr{}):       typeUDPAddr,
+	reflect.TypeOf(x509.Certificate{}):  typeCertificate,
+	reflect.TypeOf(tls.Certificate{}):   typeTLSCertificate,
+	reflect.TypeOf(rsa.PublicKey{}):     typePublicKey,
+	reflect.TypeOf(ecdsa.PublicKey{}):   typePublicKey,
+	reflect.TypeOf(ed25519.PublicKey{}): typePublicKey,
+}
+
+// specialType returns the computedType of t if it is one of specialTypes.
+func specialType(t reflect.Type) (computedType, bool) {
+	ct, ok := specialTypes[t]
+	return ct, ok
+}
+
+// isJSONNumber returns true if data is a number as json has them, which is
+// what a json.Number is expected to hold.
+func isJSONNumber(data []byte) bool {
+	if len(data) == 0 || (data[0] != '-' && (data[0] < '0' || data[0] > '9')) {
+		return false
+	}
+	return json.Valid(data)
+}
+
+// inferValue returns data as whatever it looks like for Decoder.InferTypes:
+// a bool for "true" or "false", an int64 or float64 for json numbers, and
+// otherwise a string.
+func inferValue(data []byte) interface{} {
+	s := string(data)
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if isJSONNumber(data) {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
@@ -0,0 +1,28 @@
+package decoder
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// UnmarshalValues - uses the default decoder to decode values, each the value
+// of the key at the same position in keys, at pathPrefix into v.
+func UnmarshalValues(pathPrefix string, keys []string, values [][]byte, v interface{}) error {
+	return defaultDecoder.UnmarshalValues(pathPrefix, keys, values, v)
+}
+
+// UnmarshalValues - this is the UnmarshalValues method on a custom decoder.
+// It depends on nothing but its arguments, which makes it the entry point of
+// choice for fuzzing, and for trees that don't come from consul.  keys and
+// values must be of the same length.
+func (d *Decoder) UnmarshalValues(pathPrefix string, keys []string, values [][]byte, v interface{}) error {
+	if len(keys) != len(values) {
+		return fmt.Errorf("got %d keys but %d values", len(keys), len(values))
+	}
+	kvps := make(api.KVPairs, len(keys))
+	for i, key := range keys {
+		kvps[i] = &api.KVPair{Key: key, Value: values[i]}
+	}
+	return d.Unmarshal(pathPrefix, kvps, v)
+}